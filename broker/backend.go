@@ -2,35 +2,155 @@ package broker
 
 import (
 	"errors"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/session"
 	"github.com/256dpi/gomqtt/topic"
+	"github.com/256dpi/gomqtt/transport"
 )
 
+// SessionStorage persists the packets of a session, e.g. a session.FileSession.
+type SessionStorage interface {
+	Session
+
+	// Reset should completely reset the storage.
+	Reset() error
+}
+
 type memorySession struct {
-	*session.MemorySession
+	SessionStorage
 
 	subscriptions  *topic.Tree
 	storedQueue    chan *packet.Message
 	temporaryQueue chan *packet.Message
 	activeClient   *Client
+
+	sharedMutex    sync.Mutex
+	sharedQueued   map[*packet.Message]string
+	sharedPackets  map[packet.ID]string
+	sharedDequeued map[*packet.Message]string
 }
 
 func newMemorySession(backlog int) *memorySession {
 	return &memorySession{
-		MemorySession:  session.NewMemorySession(),
+		SessionStorage: session.NewMemorySession(),
 		subscriptions:  topic.NewStandardTree(),
 		storedQueue:    make(chan *packet.Message, backlog),
 		temporaryQueue: make(chan *packet.Message, backlog),
+		sharedQueued:   make(map[*packet.Message]string),
+		sharedPackets:  make(map[packet.ID]string),
+		sharedDequeued: make(map[*packet.Message]string),
+	}
+}
+
+func (s *memorySession) SavePacket(dir session.Direction, pkt packet.Generic) error {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// forget shared packet that is overwritten
+	if dir == session.Outgoing {
+		if id, ok := packet.GetID(pkt); ok {
+			delete(s.sharedPackets, id)
+		}
+	}
+
+	return s.SessionStorage.SavePacket(dir, pkt)
+}
+
+func (s *memorySession) SaveMessagePacket(msg *packet.Message, publish *packet.Publish) error {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// track publish packet of a dequeued shared message
+	share, ok := s.sharedDequeued[msg]
+	delete(s.sharedDequeued, msg)
+	if ok {
+		s.sharedPackets[publish.ID] = share
+	} else {
+		delete(s.sharedPackets, publish.ID)
 	}
+
+	return s.SessionStorage.SavePacket(session.Outgoing, publish)
+}
+
+func (s *memorySession) DeletePacket(dir session.Direction, id packet.ID) error {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// forget shared packet
+	if dir == session.Outgoing {
+		delete(s.sharedPackets, id)
+	}
+
+	return s.SessionStorage.DeletePacket(dir, id)
+}
+
+func (s *memorySession) Reset() error {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// forget shared packets
+	s.sharedPackets = make(map[packet.ID]string)
+	s.sharedDequeued = make(map[*packet.Message]string)
+
+	return s.SessionStorage.Reset()
+}
+
+func (s *memorySession) AllocateID() (packet.ID, error) {
+	// use allocator of storage if available
+	if allocator, ok := s.SessionStorage.(AllocatingSession); ok {
+		return allocator.AllocateID()
+	}
+
+	return s.NextID(), nil
+}
+
+func (s *memorySession) queueShared(msg *packet.Message, share string) {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// track message
+	s.sharedQueued[msg] = share
+}
+
+func (s *memorySession) dequeue(msg *packet.Message) *packet.Message {
+	// acquire mutex
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	// get share
+	share, ok := s.sharedQueued[msg]
+	delete(s.sharedQueued, msg)
+
+	// remember share of qos > 0 messages until the packet is saved
+	if ok && msg.QOS > 0 {
+		s.sharedDequeued[msg] = share
+	}
+
+	// qos of shared messages has already been applied
+	if ok {
+		return msg
+	}
+
+	return s.applyQOS(msg)
 }
 
 func (s *memorySession) lookupSubscription(topic string) *packet.Subscription {
-	// find subscription
-	value := s.subscriptions.MatchFirst(topic)
+	// find subscription with the highest qos
+	value := s.subscriptions.MatchReduce(topic, nil, func(result, value interface{}) interface{} {
+		if result == nil || value.(*packet.Subscription).QOS > result.(*packet.Subscription).QOS {
+			return value
+		}
+		return result
+	})
 	if value != nil {
 		return value.(*packet.Subscription)
 	}
@@ -38,6 +158,17 @@ func (s *memorySession) lookupSubscription(topic string) *packet.Subscription {
 	return nil
 }
 
+func (s *memorySession) lookupSubscriptions(topic string) []*packet.Subscription {
+	// find all subscriptions
+	values := s.subscriptions.Match(topic)
+	subs := make([]*packet.Subscription, 0, len(values))
+	for _, value := range values {
+		subs = append(subs, value.(*packet.Subscription))
+	}
+
+	return subs
+}
+
 func (s *memorySession) applyQOS(msg *packet.Message) *packet.Message {
 	// get subscription
 	sub := s.lookupSubscription(msg.Topic)
@@ -52,7 +183,18 @@ func (s *memorySession) applyQOS(msg *packet.Message) *packet.Message {
 	return msg
 }
 
+func (s *memorySession) queueLength() int {
+	return len(s.storedQueue) + len(s.temporaryQueue)
+}
+
 func (s *memorySession) reuse() {
+	// forget shared messages of the temporary queue
+	s.sharedMutex.Lock()
+	for len(s.temporaryQueue) > 0 {
+		delete(s.sharedQueued, <-s.temporaryQueue)
+	}
+	s.sharedMutex.Unlock()
+
 	// reset temporary queue
 	s.temporaryQueue = make(chan *packet.Message, cap(s.temporaryQueue))
 }
@@ -68,6 +210,19 @@ var ErrClosing = errors.New("closing")
 // in time.
 var ErrKillTimeout = errors.New("kill timeout")
 
+// SharedStrategy defines how a member of a shared subscription group is selected
+// to receive a message.
+type SharedStrategy int
+
+const (
+	// RoundRobin selects the members of a group in turns.
+	RoundRobin SharedStrategy = iota
+
+	// LeastQueued selects the member of a group with the fewest queued
+	// messages.
+	LeastQueued
+)
+
 // A MemoryBackend stores everything in memory.
 type MemoryBackend struct {
 	// The size of the session queue.
@@ -84,9 +239,38 @@ type MemoryBackend struct {
 	ClientInflightMessages   int
 	ClientTokenTimeout       time.Duration
 
+	// The rate limits applied to the connection of every client during setup.
+	// Limits of zero are not applied. See transport.Conn for details.
+	ClientReadRate   int64
+	ClientWriteRate  int64
+	ClientPacketRate int64
+
 	// A map of username and passwords that grant read and write access.
 	Credentials map[string]string
 
+	// PeerAuthenticator may be set to authenticate clients that are connected
+	// using a unix domain socket by the credentials of the peer process. If it
+	// returns true the client is accepted regardless of the supplied username
+	// and password.
+	PeerAuthenticator func(*transport.PeerCredentials) bool
+
+	// The strategy used to select the member of a shared subscription group
+	// that receives a message.
+	SharedStrategy SharedStrategy
+
+	// If enabled, a copy of a message is queued for every matching
+	// subscription of a session using the QOS of the subscription. Otherwise,
+	// a single message is queued using the highest QOS of all matching
+	// subscriptions.
+	PerSubscriptionDelivery bool
+
+	// SessionStorage may be set to create the storage for the packets of
+	// stored sessions. A session with a storage that already holds packets,
+	// e.g. after a restart, is reported as resumed. The storage is reset and
+	// closed if it implements io.Closer when the stored session is removed.
+	// Temporary sessions are always stored in memory.
+	SessionStorage func(id string) (SessionStorage, error)
+
 	// The Logger callback handles incoming log events.
 	Logger func(LogEvent, *Client, packet.Generic, *packet.Message, error)
 
@@ -94,6 +278,8 @@ type MemoryBackend struct {
 	storedSessions    map[string]*memorySession
 	temporarySessions map[*Client]*memorySession
 	retainedMessages  *topic.Tree
+	sharedSessions    *topic.Tree
+	sharedCounters    map[string]int
 	globalMutex       sync.Mutex
 	setupMutex        sync.Mutex
 	closing           bool
@@ -108,11 +294,13 @@ func NewMemoryBackend() *MemoryBackend {
 		storedSessions:    make(map[string]*memorySession),
 		temporarySessions: make(map[*Client]*memorySession),
 		retainedMessages:  topic.NewStandardTree(),
+		sharedSessions:    topic.NewStandardTree(),
+		sharedCounters:    make(map[string]int),
 	}
 }
 
 // Authenticate will authenticates a clients credentials.
-func (m *MemoryBackend) Authenticate(_ *Client, user, password string) (bool, error) {
+func (m *MemoryBackend) Authenticate(client *Client, user, password string) (bool, error) {
 	// acquire global mutex
 	m.globalMutex.Lock()
 	defer m.globalMutex.Unlock()
@@ -122,6 +310,14 @@ func (m *MemoryBackend) Authenticate(_ *Client, user, password string) (bool, er
 		return false, ErrClosing
 	}
 
+	// check peer credentials
+	if m.PeerAuthenticator != nil && client != nil {
+		creds, err := client.PeerCredentials()
+		if err == nil && m.PeerAuthenticator(creds) {
+			return true, nil
+		}
+	}
+
 	// allow all if there are no credentials
 	if m.Credentials == nil {
 		return true, nil
@@ -156,6 +352,18 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	client.ParallelSubscribes = m.ClientParallelSubscribes
 	client.InflightMessages = m.ClientInflightMessages
 	client.TokenTimeout = m.ClientTokenTimeout
+	client.ReleasePackets = true
+
+	// apply connection rate limits
+	if m.ClientReadRate > 0 {
+		client.Conn().SetReadRate(m.ClientReadRate)
+	}
+	if m.ClientWriteRate > 0 {
+		client.Conn().SetWriteRate(m.ClientWriteRate)
+	}
+	if m.ClientPacketRate > 0 {
+		client.Conn().SetPacketRate(m.ClientPacketRate)
+	}
 
 	// return a new temporary session if id is zero
 	if len(id) == 0 {
@@ -183,8 +391,11 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 
 	// kill existing client if session is taken
 	if ok && existingSession.activeClient != nil {
+		// get client, the session is released concurrently on termination
+		existingClient := existingSession.activeClient
+
 		// close client
-		existingSession.activeClient.Close()
+		existingClient.Close()
 
 		// release global mutex to allow publish and termination, but leave the
 		// setup mutex to prevent setups
@@ -193,7 +404,7 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 		// wait for client to close
 		var err error
 		select {
-		case <-existingSession.activeClient.Closed():
+		case <-existingClient.Closed():
 			// continue
 		case <-time.After(m.KillTimeout):
 			err = ErrKillTimeout
@@ -211,8 +422,17 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	// delete any stored session and return a temporary session if a clean
 	// session is requested
 	if clean {
-		// delete any stored session
-		delete(m.storedSessions, id)
+		// delete any stored session and its shared subscriptions
+		if storedSession, ok := m.storedSessions[id]; ok {
+			m.sharedSessions.Clear(storedSession)
+			delete(m.storedSessions, id)
+
+			// release storage
+			err := m.releaseStorage(storedSession)
+			if err != nil {
+				return nil, false, err
+			}
+		}
 
 		// create new session
 		sess := newMemorySession(m.SessionQueueSize)
@@ -247,6 +467,28 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	// otherwise create fresh session
 	storedSession = newMemorySession(m.SessionQueueSize)
 
+	// create storage if available
+	if m.SessionStorage != nil {
+		storage, err := m.SessionStorage(id)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// set storage
+		storedSession.SessionStorage = storage
+	}
+
+	// check if the storage already holds packets that will be resent
+	resumed := false
+	for _, dir := range []session.Direction{session.Incoming, session.Outgoing} {
+		pkts, err := storedSession.AllPackets(dir)
+		if err != nil {
+			return nil, false, err
+		} else if len(pkts) > 0 {
+			resumed = true
+		}
+	}
+
 	// set active client
 	storedSession.activeClient = client
 
@@ -256,7 +498,7 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	// save client
 	m.activeClients[id] = client
 
-	return storedSession, false, nil
+	return storedSession, resumed, nil
 }
 
 // Restore is not needed at the moment.
@@ -275,9 +517,17 @@ func (m *MemoryBackend) Subscribe(client *Client, subs []packet.Subscription, ac
 
 	// save subscription
 	for _, sub := range subs {
+		sub := sub
 		sess.subscriptions.Set(sub.Topic, &sub)
 	}
 
+	// add session to shared subscription groups
+	for _, sub := range subs {
+		if group, filter, ok := topic.SplitShare(sub.Topic); ok {
+			m.sharedSessions.AddShared(group, filter, sess)
+		}
+	}
+
 	// call ack if provided
 	if ack != nil {
 		ack()
@@ -285,6 +535,11 @@ func (m *MemoryBackend) Subscribe(client *Client, subs []packet.Subscription, ac
 
 	// handle all subscriptions
 	for _, sub := range subs {
+		// retained messages are not sent to shared subscriptions
+		if _, _, ok := topic.SplitShare(sub.Topic); ok {
+			continue
+		}
+
 		// get retained messages
 		values := m.retainedMessages.Search(sub.Topic)
 
@@ -304,12 +559,21 @@ func (m *MemoryBackend) Subscribe(client *Client, subs []packet.Subscription, ac
 
 // Unsubscribe will delete the subscription.
 func (m *MemoryBackend) Unsubscribe(client *Client, topics []string, ack Ack) error {
+	// acquire global mutex
+	m.globalMutex.Lock()
+	defer m.globalMutex.Unlock()
+
 	// get session
 	sess := client.Session().(*memorySession)
 
 	// delete subscriptions
 	for _, t := range topics {
 		sess.subscriptions.Empty(t)
+
+		// remove session from shared subscription group
+		if group, filter, ok := topic.SplitShare(t); ok {
+			m.sharedSessions.RemoveShared(group, filter, sess)
+		}
 	}
 
 	// call ack if provided
@@ -334,7 +598,7 @@ func (m *MemoryBackend) Publish(client *Client, msg *packet.Message, ack Ack) er
 	// check retain flag
 	if msg.Retain {
 		if len(msg.Payload) > 0 {
-			// retain message
+			// retain a copy as the client will release the packet
 			m.retainedMessages.Set(msg.Topic, msg.Copy())
 		} else {
 			// clear already retained message
@@ -342,64 +606,40 @@ func (m *MemoryBackend) Publish(client *Client, msg *packet.Message, ack Ack) er
 		}
 	}
 
-	// reset retained flag
-	msg.Retain = false
-
-	// use temporary queue by default
-	queue := func(s *memorySession) chan *packet.Message {
-		return s.temporaryQueue
-	}
-
-	// use stored queue if qos > 0
-	if msg.QOS > 0 {
-		queue = func(s *memorySession) chan *packet.Message {
-			return s.storedQueue
-		}
-	}
+	// the message is only copied once it is queued
+	pub := &publication{msg: msg}
 
 	// add message to temporary sessions
 	for _, sess := range m.temporarySessions {
-		if sub := sess.lookupSubscription(msg.Topic); sub != nil {
-			if sess.activeClient == client {
-				// detect deadlock when adding to own queue
-				select {
-				case queue(sess) <- msg:
-				default:
-					return ErrQueueFull
-				}
-			} else {
-				// wait for room since client is online
-				select {
-				case queue(sess) <- msg:
-				case <-sess.activeClient.Closing():
-				}
-			}
+		err := m.deliver(client, sess, pub)
+		if err != nil {
+			return err
 		}
 	}
 
 	// add message to stored sessions
 	for _, sess := range m.storedSessions {
-		if sub := sess.lookupSubscription(msg.Topic); sub != nil {
-			if sess.activeClient == client {
-				// detect deadlock when adding to own queue
-				select {
-				case queue(sess) <- msg:
-				default:
-					return ErrQueueFull
-				}
-			} else if sess.activeClient != nil {
-				// wait for room since client is online
-				select {
-				case queue(sess) <- msg:
-				case <-sess.activeClient.Closing():
-				}
-			} else {
-				// ignore message if offline queue is full
-				select {
-				case queue(sess) <- msg:
-				default:
-				}
-			}
+		err := m.deliver(client, sess, pub)
+		if err != nil {
+			return err
+		}
+	}
+
+	// add message to one member of every matching shared subscription group
+	for _, share := range m.sharedSessions.MatchShared(msg.Topic) {
+		// get name
+		name := topic.SharePrefix + "/" + share.Group + "/" + share.Filter
+
+		// select member
+		sess := m.selectMember(name, share.Values, nil)
+		if sess == nil {
+			continue
+		}
+
+		// queue message
+		err := m.enqueueShared(client, sess, msg, name)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -424,14 +664,33 @@ func (m *MemoryBackend) Dequeue(client *Client) (*packet.Message, Ack, error) {
 	// get next message from queue
 	select {
 	case msg := <-sess.temporaryQueue:
-		return sess.applyQOS(msg), nil, nil
+		return sess.dequeue(msg), nil, nil
 	case msg := <-sess.storedQueue:
-		return sess.applyQOS(msg), nil, nil
+		return sess.dequeue(msg), nil, nil
 	case <-client.Closing():
 		return nil, nil, nil
 	}
 }
 
+// TryDequeue will get the next message from the temporary or stored queue if
+// one is immediately available.
+func (m *MemoryBackend) TryDequeue(client *Client) (*packet.Message, Ack, error) {
+	// mutex locking not needed
+
+	// get session
+	sess := client.Session().(*memorySession)
+
+	// get next message from queue if available
+	select {
+	case msg := <-sess.temporaryQueue:
+		return sess.dequeue(msg), nil, nil
+	case msg := <-sess.storedQueue:
+		return sess.dequeue(msg), nil, nil
+	default:
+		return nil, nil, nil
+	}
+}
+
 // Terminate will disassociate the session from the client.
 func (m *MemoryBackend) Terminate(client *Client) error {
 	// acquire global mutex
@@ -444,10 +703,19 @@ func (m *MemoryBackend) Terminate(client *Client) error {
 	// release session if available
 	if sess != nil {
 		sess.activeClient = nil
+
+		// hand over pending shared messages to other group members
+		err := m.redistribute(sess)
+		if err != nil {
+			return err
+		}
 	}
 
-	// remove any temporary session
-	delete(m.temporarySessions, client)
+	// remove any temporary session and its shared subscriptions
+	if _, ok := m.temporarySessions[client]; ok {
+		m.sharedSessions.Clear(sess)
+		delete(m.temporarySessions, client)
+	}
 
 	// remove any saved client
 	delete(m.activeClients, client.ID())
@@ -455,6 +723,238 @@ func (m *MemoryBackend) Terminate(client *Client) error {
 	return nil
 }
 
+// resets and closes the storage of a removed stored session
+func (m *MemoryBackend) releaseStorage(sess *memorySession) error {
+	// reset storage
+	err := sess.Reset()
+	if err != nil {
+		return err
+	}
+
+	// close storage if possible
+	if closer, ok := sess.SessionStorage.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// adds a message to the queue of a session if it holds matching subscriptions
+func (m *MemoryBackend) deliver(client *Client, sess *memorySession, pub *publication) error {
+	// queue a single message if not delivered per subscription
+	if !m.PerSubscriptionDelivery {
+		if sub := sess.lookupSubscription(pub.msg.Topic); sub != nil {
+			return m.enqueue(client, sess, pub.queued())
+		}
+
+		return nil
+	}
+
+	// queue a copy for every subscription
+	for _, sub := range sess.lookupSubscriptions(pub.msg.Topic) {
+		// respect maximum qos
+		cpy := pub.queued()
+		if cpy.QOS > sub.QOS {
+			cpy = cpy.Copy()
+			cpy.QOS = sub.QOS
+		}
+
+		// queue message
+		err := m.enqueue(client, sess, cpy)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// adds a message to the queue of a session
+func (m *MemoryBackend) enqueue(client *Client, sess *memorySession, msg *packet.Message) error {
+	// use temporary queue by default
+	queue := sess.temporaryQueue
+
+	// use stored queue if qos > 0
+	if msg.QOS > 0 {
+		queue = sess.storedQueue
+	}
+
+	if sess.activeClient == client {
+		// detect deadlock when adding to own queue
+		select {
+		case queue <- msg:
+		default:
+			return ErrQueueFull
+		}
+	} else if sess.activeClient != nil {
+		// wait for room since client is online
+		select {
+		case queue <- msg:
+		case <-sess.activeClient.Closing():
+		}
+	} else {
+		// ignore message if offline queue is full
+		select {
+		case queue <- msg:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// adds a message of a shared subscription to the queue of a session
+func (m *MemoryBackend) enqueueShared(client *Client, sess *memorySession, msg *packet.Message, name string) error {
+	// respect maximum qos of the shared subscription
+	msg = msg.Copy()
+	msg.Retain = false
+	if values := sess.subscriptions.Get(name); len(values) > 0 {
+		if sub := values[0].(*packet.Subscription); msg.QOS > sub.QOS {
+			msg.QOS = sub.QOS
+		}
+	}
+
+	// track message
+	sess.queueShared(msg, name)
+
+	return m.enqueue(client, sess, msg)
+}
+
+// selects the member of a shared subscription group that receives the next
+// message, online members are preferred
+func (m *MemoryBackend) selectMember(name string, members []interface{}, exclude *memorySession) *memorySession {
+	// collect candidates
+	var online, offline []*memorySession
+	for _, member := range members {
+		sess := member.(*memorySession)
+		if sess == exclude {
+			continue
+		} else if sess.activeClient != nil {
+			online = append(online, sess)
+		} else {
+			offline = append(offline, sess)
+		}
+	}
+
+	// prefer online members
+	candidates := online
+	if len(candidates) == 0 {
+		candidates = offline
+	}
+
+	// check candidates
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// select member with the fewest queued messages
+	if m.SharedStrategy == LeastQueued {
+		selected := candidates[0]
+		for _, sess := range candidates[1:] {
+			if sess.queueLength() < selected.queueLength() {
+				selected = sess
+			}
+		}
+
+		return selected
+	}
+
+	// otherwise select members in turns
+	counter := m.sharedCounters[name]
+	m.sharedCounters[name] = counter + 1
+
+	return candidates[counter%len(candidates)]
+}
+
+// hands over unacknowledged and queued messages of shared subscriptions to
+// other members of the group
+func (m *MemoryBackend) redistribute(sess *memorySession) error {
+	// collect unacknowledged publish packets and forget dequeued messages that
+	// have not been saved
+	sess.sharedMutex.Lock()
+	pending := make(map[packet.ID]string, len(sess.sharedPackets))
+	for id, name := range sess.sharedPackets {
+		pending[id] = name
+	}
+	sess.sharedDequeued = make(map[*packet.Message]string)
+	sess.sharedMutex.Unlock()
+
+	// hand over unacknowledged messages
+	for id, name := range pending {
+		// get packet
+		pkt, err := sess.LookupPacket(session.Outgoing, id)
+		if err != nil {
+			return err
+		}
+
+		// get publish
+		publish, ok := pkt.(*packet.Publish)
+		if !ok {
+			continue
+		}
+
+		// hand over message
+		if !m.handOver(sess, &publish.Message, name) {
+			continue
+		}
+
+		// delete packet
+		err = sess.DeletePacket(session.Outgoing, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// hand over queued messages
+	for _, queue := range []chan *packet.Message{sess.temporaryQueue, sess.storedQueue} {
+		for i, n := 0, len(queue); i < n; i++ {
+			// get message
+			msg := <-queue
+
+			// get share
+			sess.sharedMutex.Lock()
+			name, ok := sess.sharedQueued[msg]
+			delete(sess.sharedQueued, msg)
+			sess.sharedMutex.Unlock()
+
+			// put back message if not shared or not handed over
+			if !ok || !m.handOver(sess, msg, name) {
+				if ok {
+					sess.queueShared(msg, name)
+				}
+
+				queue <- msg
+			}
+		}
+	}
+
+	return nil
+}
+
+// hands over a message to another online member of a shared subscription
+// group
+func (m *MemoryBackend) handOver(sess *memorySession, msg *packet.Message, name string) bool {
+	// get group and filter
+	group, filter, ok := topic.SplitShare(name)
+	if !ok {
+		return false
+	}
+
+	// select member
+	member := m.selectMember(name, m.sharedSessions.GetShared(group, filter), sess)
+	if member == nil || member.activeClient == nil {
+		return false
+	}
+
+	// queue message
+	err := m.enqueueShared(nil, member, msg, name)
+	if err != nil {
+		return false
+	}
+
+	return true
+}
+
 // Log will call the associated logger.
 func (m *MemoryBackend) Log(event LogEvent, client *Client, pkt packet.Generic, msg *packet.Message, err error) {
 	// call logger if available
@@ -512,3 +1012,21 @@ func (m *MemoryBackend) Close(timeout time.Duration) bool {
 
 	return true
 }
+
+// a publication holds a published message that is only valid until Publish
+// returns, it is copied once when it is first queued
+type publication struct {
+	msg    *packet.Message
+	copied *packet.Message
+}
+
+// returns the copy of the message that is queued
+func (p *publication) queued() *packet.Message {
+	// copy message
+	if p.copied == nil {
+		p.copied = p.msg.Copy()
+		p.copied.Retain = false
+	}
+
+	return p.copied
+}
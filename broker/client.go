@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"crypto/tls"
 	"errors"
 	"sync"
 	"sync/atomic"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/session"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 
 	"gopkg.in/tomb.v2"
@@ -73,10 +75,33 @@ type Session interface {
 	// not return an error if no packet with the specified id does exists.
 	DeletePacket(session.Direction, packet.ID) error
 
-	// AllPackets should return all packets currently saved in the session.
+	// AllPackets should return all packets currently saved in the session in
+	// the order they have been saved. Overwritten packets should retain their
+	// position.
 	AllPackets(session.Direction) ([]packet.Generic, error)
 }
 
+// An AllocatingSession is an optional extension of a Session that allocates
+// packet ids that are not in use by stored outgoing packets.
+type AllocatingSession interface {
+	Session
+
+	// AllocateID should return the next id for outgoing packets that is not
+	// in use or an error if all ids are in use.
+	AllocateID() (packet.ID, error)
+}
+
+// A MessageSession is an optional extension of a Session that is informed
+// about the dequeued message an outgoing Publish packet has been prepared
+// from, e.g. to track which subscription the message has been queued for.
+type MessageSession interface {
+	Session
+
+	// SaveMessagePacket should store the outgoing Publish packet that has
+	// been prepared from the dequeued message like SavePacket.
+	SaveMessagePacket(msg *packet.Message, publish *packet.Publish) error
+}
+
 // Ack is executed by the Backend or Client to signal either that a message will
 // be delivered under the selected qos level and is therefore safe to be deleted
 // from either queue or the successful handling of subscriptions.
@@ -168,6 +193,19 @@ type Backend interface {
 	Log(event LogEvent, client *Client, pkt packet.Generic, msg *packet.Message, err error)
 }
 
+// A BatchBackend is an optional extension of a Backend that allows the Client
+// to drain multiple queued messages at once and send them using a single
+// vectored write.
+type BatchBackend interface {
+	Backend
+
+	// TryDequeue is called by the Client after Dequeue has returned a message
+	// to obtain further messages that are immediately available. It must not
+	// block and return no message and no error if the queue is currently
+	// empty. Otherwise, the same rules as for Dequeue apply.
+	TryDequeue(client *Client) (*packet.Message, Ack, error)
+}
+
 // ErrUnexpectedPacket is returned when an unexpected packet is received.
 var ErrUnexpectedPacket = errors.New("unexpected packet")
 
@@ -235,12 +273,34 @@ type Client struct {
 	// Disconnect packets are not provided to the callback.
 	PacketCallback func(packet.Generic) error
 
+	// ReleasePackets may be set during Setup to receive packets from the
+	// packet pool and return them once they have been processed. Packets
+	// passed to the PacketCallback and Log must then not be retained.
+	// Published messages are only valid until Publish returns and the
+	// provided ack has been called, the backend must copy messages it keeps.
+	ReleasePackets bool
+
+	// ValidationProfile is used to validate received packets against the
+	// profile before they are passed to the PacketCallback and processed. It
+	// is initialized from the engine and validates the Connect packet before
+	// Authenticate is called. It may be changed during Setup to validate
+	// subsequent packets. Clients that send invalid packets are closed with a
+	// ClientError.
+	ValidationProfile *packet.Profile
+
+	// Rewriter may be set during Setup to rewrite the topics of published
+	// messages, subscriptions and the will message before they are passed to
+	// the backend. The topics of dequeued messages are reversed before they
+	// are sent to the client.
+	Rewriter *topic.Rewriter
+
 	// Ref can be used by the backend to attach a custom object to the client.
 	Ref interface{}
 
 	backend         Backend
 	conn            transport.Conn
 	id              string
+	version         byte
 	will            *packet.Message
 	session         Session
 	ackQueue        chan packet.Generic
@@ -253,12 +313,18 @@ type Client struct {
 
 // NewClient takes over a connection and returns a Client.
 func NewClient(backend Backend, conn transport.Conn) *Client {
+	return newClient(backend, conn, nil)
+}
+
+// create and start a client with an initial validation profile
+func newClient(backend Backend, conn transport.Conn, profile *packet.Profile) *Client {
 	// create client
 	c := &Client{
-		state:   clientConnecting,
-		backend: backend,
-		conn:    conn,
-		closed:  make(chan struct{}),
+		state:             clientConnecting,
+		backend:           backend,
+		conn:              conn,
+		ValidationProfile: profile,
+		closed:            make(chan struct{}),
 	}
 
 	// start processor
@@ -288,11 +354,33 @@ func (c *Client) ID() string {
 }
 
 // Conn returns the client's underlying connection. Calls to SetReadLimit,
-// LocalAddr and RemoteAddr are safe.
+// SetReadRate, SetWriteRate, SetPacketRate, LocalAddr and RemoteAddr are safe.
 func (c *Client) Conn() transport.Conn {
 	return c.conn
 }
 
+// TLSState returns the state of the client's TLS connection. It will return
+// false if the client is not connected using TLS. Backends may use the peer
+// certificates to authenticate clients.
+func (c *Client) TLSState() (*tls.ConnectionState, bool) {
+	return c.conn.TLSState()
+}
+
+// PeerCredentials returns the credentials of the peer process if the client is
+// connected using a unix domain socket. It will return
+// transport.ErrNoPeerCredentials if the connection does not provide them.
+func (c *Client) PeerCredentials() (*transport.PeerCredentials, error) {
+	// check connection
+	conn, ok := c.conn.(interface {
+		PeerCredentials() (*transport.PeerCredentials, error)
+	})
+	if !ok {
+		return nil, transport.ErrNoPeerCredentials
+	}
+
+	return conn.PeerCredentials()
+}
+
 // Close will immediately close the client.
 func (c *Client) Close() {
 	_ = c.conn.Close()
@@ -353,6 +441,14 @@ func (c *Client) processor() error {
 
 		c.backend.Log(PacketReceived, c, pkt, nil, nil)
 
+		// validate packet
+		if c.ValidationProfile != nil {
+			err = c.ValidationProfile.Validate(pkt, c.version)
+			if err != nil {
+				return c.die(ClientError, err)
+			}
+		}
+
 		// call callback
 		if c.PacketCallback != nil && pkt.Type() != packet.DISCONNECT {
 			err = c.PacketCallback(pkt)
@@ -366,11 +462,26 @@ func (c *Client) processor() error {
 		if err != nil {
 			return err // error has already been handled
 		}
+
+		// release processed acknowledgement packets
+		if c.ReleasePackets {
+			switch pkt.(type) {
+			case *packet.Puback, *packet.Pubcomp, *packet.Pubrec, *packet.Pubrel, *packet.Pingreq:
+				packet.Release(pkt)
+			}
+		}
 	}
 }
 
 // message dequeuer
 func (c *Client) dequeuer() error {
+	// check batch support
+	batchBackend, batching := c.backend.(BatchBackend)
+
+	// prepare batch
+	var pkts []packet.Generic
+	var msgs []*packet.Message
+
 	for {
 		// acquire dequeue token, try fast path first
 		select {
@@ -395,48 +506,120 @@ func (c *Client) dequeuer() error {
 			return tomb.ErrDying
 		}
 
-		c.backend.Log(MessageDequeued, c, nil, msg, nil)
-
 		// prepare publish packet
-		publish := packet.NewPublish()
-		publish.Message = *msg
-
-		// set packet id
-		if publish.Message.QOS > 0 {
-			publish.ID = c.session.NextID()
+		publish, err := c.preparePublish(msg, ack)
+		if err != nil {
+			return err // error has already been handled
 		}
 
-		// store packet if at least qos 1
-		if publish.Message.QOS > 0 {
-			err := c.session.SavePacket(session.Outgoing, publish)
+		// reset batch
+		pkts = append(pkts[:0], publish)
+		msgs = append(msgs[:0], msg)
+
+		// drain immediately available messages while tokens are available
+		for batching && c.tryDequeueToken() {
+			// request next message
+			msg, ack, err := batchBackend.TryDequeue(c)
 			if err != nil {
-				return c.die(SessionError, err)
+				return c.die(BackendError, err)
+			} else if msg == nil {
+				c.putDequeueToken()
+				break
 			}
-		}
 
-		// acknowledge message
-		if ack != nil {
-			ack()
+			// prepare publish packet
+			publish, err := c.preparePublish(msg, ack)
+			if err != nil {
+				return err // error has already been handled
+			}
 
-			c.backend.Log(MessageAcknowledged, c, nil, msg, nil)
+			// add to batch
+			pkts = append(pkts, publish)
+			msgs = append(msgs, msg)
 		}
 
-		// send packet
-		err = c.send(publish, true)
+		// send packets
+		if len(pkts) == 1 {
+			err = c.send(pkts[0], true)
+		} else {
+			err = c.sendBatch(pkts)
+		}
 		if err != nil {
 			return c.die(TransportError, err)
 		}
 
-		// immediately put back dequeue token for qos 0 messages
-		if publish.Message.QOS == 0 {
-			select {
-			case c.dequeueTokens <- struct{}{}:
-			default:
-				// continue if full for some reason
+		for i, msg := range msgs {
+			// immediately put back dequeue token for qos 0 messages
+			if msg.QOS == 0 {
+				c.putDequeueToken()
 			}
+
+			c.backend.Log(MessageForwarded, c, nil, msg, nil)
+
+			// clear references
+			pkts[i] = nil
+			msgs[i] = nil
+		}
+	}
+}
+
+// prepares a publish packet for a dequeued message
+func (c *Client) preparePublish(msg *packet.Message, ack Ack) (*packet.Publish, error) {
+	c.backend.Log(MessageDequeued, c, nil, msg, nil)
+
+	// prepare publish packet
+	publish := packet.NewPublish()
+	publish.Message = *msg
+
+	// reverse topic
+	if c.Rewriter != nil {
+		publish.Message.Topic = c.Rewriter.Reverse(publish.Message.Topic)
+	}
+
+	// set packet id
+	if publish.Message.QOS > 0 {
+		id, err := c.nextID()
+		if err != nil {
+			return nil, c.die(SessionError, err)
 		}
 
-		c.backend.Log(MessageForwarded, c, nil, msg, nil)
+		publish.ID = id
+	}
+
+	// store packet if at least qos 1
+	if publish.Message.QOS > 0 {
+		err := c.savePublish(msg, publish)
+		if err != nil {
+			return nil, c.die(SessionError, err)
+		}
+	}
+
+	// acknowledge message
+	if ack != nil {
+		ack()
+
+		c.backend.Log(MessageAcknowledged, c, nil, msg, nil)
+	}
+
+	return publish, nil
+}
+
+// acquires a dequeue token if immediately available
+func (c *Client) tryDequeueToken() bool {
+	select {
+	case <-c.dequeueTokens:
+		return true
+	default:
+		return false
+	}
+}
+
+// puts back a dequeue token
+func (c *Client) putDequeueToken() {
+	select {
+	case c.dequeueTokens <- struct{}{}:
+	default:
+		// continue if full for some reason
 	}
 }
 
@@ -484,8 +667,17 @@ func (c *Client) acker() error {
 
 // handle an incoming Connect packet
 func (c *Client) processConnect(pkt *packet.Connect) error {
-	// save id
+	// save id and version
 	c.id = pkt.ClientID
+	c.version = pkt.Version
+
+	// validate connect
+	if c.ValidationProfile != nil {
+		err := c.ValidationProfile.Validate(pkt, c.version)
+		if err != nil {
+			return c.die(ClientError, err)
+		}
+	}
 
 	// authenticate
 	ok, err := c.backend.Authenticate(c, pkt.Username, pkt.Password)
@@ -546,6 +738,9 @@ func (c *Client) processConnect(pkt *packet.Connect) error {
 	// assign session
 	c.session = s
 
+	// receive pooled packets if they are released
+	c.conn.SetPooled(c.ReleasePackets)
+
 	// set default parallel publishes
 	if c.ParallelPublishes <= 0 {
 		c.ParallelPublishes = 10
@@ -590,6 +785,11 @@ func (c *Client) processConnect(pkt *packet.Connect) error {
 	// save will if present
 	if pkt.Will != nil {
 		c.will = pkt.Will
+
+		// rewrite topic
+		if c.Rewriter != nil {
+			c.will.Topic = c.Rewriter.Rewrite(c.will.Topic)
+		}
 	}
 
 	// send connack
@@ -710,6 +910,13 @@ func (c *Client) processSubscribe(pkt *packet.Subscribe) error {
 		suback.ReturnCodes[i] = subscription.QOS
 	}
 
+	// rewrite filters
+	if c.Rewriter != nil {
+		for i, subscription := range pkt.Subscriptions {
+			pkt.Subscriptions[i].Topic = c.rewriteFilter(subscription.Topic)
+		}
+	}
+
 	// prepare ack
 	var once sync.Once
 	ack := func() {
@@ -749,6 +956,7 @@ func (c *Client) processUnsubscribe(pkt *packet.Unsubscribe) error {
 
 	// prepare unsuback packet
 	unsuback := packet.NewUnsuback()
+	unsuback.ReasonCodes = make([]packet.ReasonCode, len(pkt.Topics))
 	unsuback.ID = pkt.ID
 
 	// prepare ack
@@ -762,6 +970,13 @@ func (c *Client) processUnsubscribe(pkt *packet.Unsubscribe) error {
 		})
 	}
 
+	// rewrite filters
+	if c.Rewriter != nil {
+		for i, filter := range pkt.Topics {
+			pkt.Topics[i] = c.rewriteFilter(filter)
+		}
+	}
+
 	// unsubscribe topics
 	err := c.backend.Unsubscribe(c, pkt.Topics, ack)
 	if err != nil {
@@ -773,6 +988,11 @@ func (c *Client) processUnsubscribe(pkt *packet.Unsubscribe) error {
 
 // handle an incoming publish packet
 func (c *Client) processPublish(publish *packet.Publish) error {
+	// rewrite topic
+	if c.Rewriter != nil {
+		publish.Message.Topic = c.Rewriter.Rewrite(publish.Message.Topic)
+	}
+
 	// handle qos 0 flow
 	if publish.Message.QOS == 0 {
 		// publish message
@@ -783,6 +1003,11 @@ func (c *Client) processPublish(publish *packet.Publish) error {
 
 		c.backend.Log(MessagePublished, c, nil, &publish.Message, nil)
 
+		// release packet
+		if c.ReleasePackets {
+			packet.Release(publish)
+		}
+
 		return nil
 	}
 
@@ -807,6 +1032,14 @@ func (c *Client) processPublish(publish *packet.Publish) error {
 		puback := packet.NewPuback()
 		puback.ID = publish.ID
 
+		// prepare release once published and acknowledged
+		refs := int32(2)
+		release := func() {
+			if c.ReleasePackets && atomic.AddInt32(&refs, -1) == 0 {
+				packet.Release(publish)
+			}
+		}
+
 		// prepare ack
 		var once sync.Once
 		ack := func() {
@@ -818,6 +1051,9 @@ func (c *Client) processPublish(publish *packet.Publish) error {
 				case c.ackQueue <- puback:
 				case <-c.tomb.Dying():
 				}
+
+				// release packet
+				release()
 			})
 		}
 
@@ -828,6 +1064,11 @@ func (c *Client) processPublish(publish *packet.Publish) error {
 		}
 
 		c.backend.Log(MessagePublished, c, nil, &publish.Message, nil)
+
+		// release packet, it must not be used afterwards
+		release()
+
+		return nil
 	}
 
 	// handle qos 2 flow
@@ -861,11 +1102,7 @@ func (c *Client) processPubackAndPubcomp(id packet.ID) error {
 	}
 
 	// put back dequeue token
-	select {
-	case c.dequeueTokens <- struct{}{}:
-	default:
-		// continue if full for some reason
-	}
+	c.putDequeueToken()
 
 	return nil
 }
@@ -961,6 +1198,36 @@ func (c *Client) processDisconnect() error {
 
 /* helpers */
 
+// get the next packet id for an outgoing packet
+func (c *Client) nextID() (packet.ID, error) {
+	// use allocator if available
+	if allocator, ok := c.session.(AllocatingSession); ok {
+		return allocator.AllocateID()
+	}
+
+	return c.session.NextID(), nil
+}
+
+// saves an outgoing publish packet prepared from a dequeued message
+func (c *Client) savePublish(msg *packet.Message, publish *packet.Publish) error {
+	// pass message if supported
+	if messageSession, ok := c.session.(MessageSession); ok {
+		return messageSession.SaveMessagePacket(msg, publish)
+	}
+
+	return c.session.SavePacket(session.Outgoing, publish)
+}
+
+// rewrite a filter while retaining a shared subscription prefix
+func (c *Client) rewriteFilter(filter string) string {
+	// rewrite filter of shared subscriptions
+	if group, f, ok := topic.SplitShare(filter); ok {
+		return topic.SharePrefix + "/" + group + "/" + c.Rewriter.Rewrite(f)
+	}
+
+	return c.Rewriter.Rewrite(filter)
+}
+
 // send a packet
 func (c *Client) send(pkt packet.Generic, async bool) error {
 	// send packet
@@ -974,6 +1241,22 @@ func (c *Client) send(pkt packet.Generic, async bool) error {
 	return nil
 }
 
+// send multiple packets at once
+func (c *Client) sendBatch(pkts []packet.Generic) error {
+	// send packets
+	err := c.conn.SendBatch(pkts)
+	if err != nil {
+		return err
+	}
+
+	// log packets
+	for _, pkt := range pkts {
+		c.backend.Log(PacketSent, c, pkt, nil, nil)
+	}
+
+	return nil
+}
+
 /* error handling and logging */
 
 // used for closing and cleaning up from internal goroutines
@@ -1,11 +1,16 @@
 package broker
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/256dpi/gomqtt/client"
 	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 	"github.com/256dpi/gomqtt/transport/flow"
 
@@ -16,6 +21,20 @@ type testMemoryBackend struct {
 	MemoryBackend
 
 	packets []packet.Generic
+
+	published int32
+
+	rewriters map[string]*topic.Rewriter
+
+	batchedMessages int32
+
+	tlsState *tls.ConnectionState
+}
+
+func (b *testMemoryBackend) Authenticate(client *Client, user, password string) (bool, error) {
+	b.tlsState, _ = client.TLSState()
+
+	return b.MemoryBackend.Authenticate(client, user, password)
 }
 
 func (b *testMemoryBackend) Setup(client *Client, id string, clean bool) (Session, bool, error) {
@@ -24,9 +43,26 @@ func (b *testMemoryBackend) Setup(client *Client, id string, clean bool) (Sessio
 		return nil
 	}
 
+	client.Rewriter = b.rewriters[id]
+
 	return b.MemoryBackend.Setup(client, id, clean)
 }
 
+func (b *testMemoryBackend) Publish(client *Client, msg *packet.Message, ack Ack) error {
+	atomic.AddInt32(&b.published, 1)
+
+	return b.MemoryBackend.Publish(client, msg, ack)
+}
+
+func (b *testMemoryBackend) TryDequeue(client *Client) (*packet.Message, Ack, error) {
+	msg, ack, err := b.MemoryBackend.TryDequeue(client)
+	if msg != nil {
+		atomic.AddInt32(&b.batchedMessages, 1)
+	}
+
+	return msg, ack, err
+}
+
 func TestClientMaximumKeepAlive(t *testing.T) {
 	backend := &testMemoryBackend{
 		MemoryBackend: *NewMemoryBackend(),
@@ -93,6 +129,246 @@ func TestClientPacketCallback(t *testing.T) {
 	assert.Equal(t, packet.PUBLISH, backend.packets[1].Type())
 }
 
+func TestClientValidationProfile(t *testing.T) {
+	backend := &testMemoryBackend{
+		MemoryBackend: *NewMemoryBackend(),
+	}
+
+	errs := make(chan error, 1)
+	backend.Logger = func(event LogEvent, client *Client, pkt packet.Generic, msg *packet.Message, err error) {
+		if event == ClientError {
+			errs <- err
+		}
+	}
+
+	engine := NewEngine(backend)
+	engine.ValidationProfile = packet.StrictProfile
+
+	port, quit, done := Run(engine, "tcp")
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	f := flow.New().
+		Send(packet.NewConnect()).
+		Receive(packet.NewConnack()).
+		Send(&packet.Publish{Message: packet.Message{Topic: "vp/+"}}).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	assert.Error(t, <-errs)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+
+	assert.Empty(t, backend.packets)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&backend.published))
+}
+
+func TestClientValidationProfileConnect(t *testing.T) {
+	backend := &testMemoryBackend{
+		MemoryBackend: *NewMemoryBackend(),
+	}
+
+	errs := make(chan error, 1)
+	backend.Logger = func(event LogEvent, client *Client, pkt packet.Generic, msg *packet.Message, err error) {
+		if event == ClientError {
+			errs <- err
+		}
+	}
+
+	engine := NewEngine(backend)
+	engine.ValidationProfile = packet.StrictProfile
+
+	port, quit, done := Run(engine, "tcp")
+
+	options := client.NewConfig("tcp://localhost:" + port)
+	options.ClientID = "vp"
+	options.CleanSession = false
+
+	client1 := client.New()
+
+	cf, err := client1.Connect(options)
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	connect := packet.NewConnect()
+	connect.ClientID = "vp"
+	connect.Will = &packet.Message{Topic: "vp/+"}
+
+	f := flow.New().
+		Send(connect).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	assert.Error(t, <-errs)
+
+	pf, err := client1.Publish("vp", nil, 1, false)
+	assert.NoError(t, err)
+	assert.NoError(t, pf.Wait(10*time.Second))
+
+	err = client1.Disconnect()
+	assert.NoError(t, err)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+}
+
+func TestClientRewriter(t *testing.T) {
+	tenant, err := topic.NewRewriter(topic.MountPoint("tenant"))
+	assert.NoError(t, err)
+
+	backend := &testMemoryBackend{
+		MemoryBackend: *NewMemoryBackend(),
+		rewriters: map[string]*topic.Rewriter{
+			"tenant": tenant,
+		},
+	}
+
+	port, quit, done := Run(NewEngine(backend), "tcp")
+
+	wait1 := make(chan struct{})
+	wait2 := make(chan struct{})
+
+	client1 := client.New()
+	client1.Callback = func(msg *packet.Message, err error) error {
+		assert.NoError(t, err)
+		assert.Equal(t, "rw/foo", msg.Topic)
+		close(wait1)
+		return nil
+	}
+
+	options := client.NewConfig("tcp://localhost:" + port)
+	options.ClientID = "tenant"
+
+	cf, err := client1.Connect(options)
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	client2 := client.New()
+	client2.Callback = func(msg *packet.Message, err error) error {
+		assert.NoError(t, err)
+		assert.Equal(t, "tenant/rw/foo", msg.Topic)
+		close(wait2)
+		return nil
+	}
+
+	cf, err = client2.Connect(client.NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	sf, err := client1.Subscribe("rw/+", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sf.Wait(10*time.Second))
+
+	sf, err = client2.Subscribe("tenant/rw/#", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sf.Wait(10*time.Second))
+
+	pf, err := client1.Publish("rw/foo", nil, 1, false)
+	assert.NoError(t, err)
+	assert.NoError(t, pf.Wait(10*time.Second))
+
+	safeReceive(wait1)
+	safeReceive(wait2)
+
+	err = client1.Disconnect()
+	assert.NoError(t, err)
+
+	err = client2.Disconnect()
+	assert.NoError(t, err)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+}
+
+func TestClientDequeueBatch(t *testing.T) {
+	backend := &testMemoryBackend{
+		MemoryBackend: *NewMemoryBackend(),
+	}
+
+	port, quit, done := Run(NewEngine(backend), "tcp")
+
+	connect := packet.NewConnect()
+	connect.ClientID = "batch"
+	connect.CleanSession = false
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	f := flow.New().
+		Send(connect).
+		Receive(packet.NewConnack()).
+		Send(&packet.Subscribe{Subscriptions: []packet.Subscription{{Topic: "batch", QOS: 1}}, ID: 1}).
+		Receive(&packet.Suback{ID: 1, ReturnCodes: []packet.QOS{1}}).
+		Send(packet.NewDisconnect()).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	client1 := client.New()
+
+	cf, err := client1.Connect(client.NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	for i := 0; i < 3; i++ {
+		pf, err := client1.Publish("batch", []byte{byte(i)}, 1, false)
+		assert.NoError(t, err)
+		assert.NoError(t, pf.Wait(10*time.Second))
+	}
+
+	err = client1.Disconnect()
+	assert.NoError(t, err)
+
+	conn, err = transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	f = flow.New().
+		Send(connect).
+		Receive(&packet.Connack{SessionPresent: true}).
+		Receive(
+			&packet.Publish{Message: packet.Message{Topic: "batch", Payload: []byte{0}, QOS: 1}, ID: 1},
+			&packet.Publish{Message: packet.Message{Topic: "batch", Payload: []byte{1}, QOS: 1}, ID: 2},
+			&packet.Publish{Message: packet.Message{Topic: "batch", Payload: []byte{2}, QOS: 1}, ID: 3},
+		).
+		Send(&packet.Puback{ID: 1}, &packet.Puback{ID: 2}, &packet.Puback{ID: 3}).
+		Send(packet.NewDisconnect()).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&backend.batchedMessages))
+}
+
 func TestClientTokenTimeoutPublish(t *testing.T) {
 	backend := &testMemoryBackend{
 		MemoryBackend: *NewMemoryBackend(),
@@ -176,3 +452,56 @@ func TestClientTokenTimeoutDequeue(t *testing.T) {
 
 	safeReceive(done)
 }
+
+func TestClientTLSState(t *testing.T) {
+	crt, err := tls.LoadX509KeyPair("../example.crt", "../example.key")
+	assert.NoError(t, err)
+
+	pem, err := ioutil.ReadFile("../example.crt")
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(pem))
+
+	server, err := transport.CreateSecureNetServer("localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{crt},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	assert.NoError(t, err)
+
+	backend := &testMemoryBackend{
+		MemoryBackend: *NewMemoryBackend(),
+	}
+
+	engine := NewEngine(backend)
+	engine.Accept(server)
+
+	dialer := transport.NewDialer(transport.DialConfig{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{crt},
+			RootCAs:      pool,
+		},
+	})
+
+	conn, err := dialer.Dial("tls://" + server.Addr().String())
+	assert.NoError(t, err)
+
+	f := flow.New().
+		Send(packet.NewConnect()).
+		Receive(packet.NewConnack()).
+		Send(packet.NewDisconnect()).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, backend.tlsState)
+	assert.Equal(t, "localhost", backend.tlsState.PeerCertificates[0].Subject.CommonName)
+	assert.Equal(t, []string{"localhost"}, backend.tlsState.PeerCertificates[0].DNSNames)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	engine.Close()
+}
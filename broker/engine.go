@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/transport"
 
 	"gopkg.in/tomb.v2"
@@ -19,6 +20,15 @@ type Engine struct {
 	// ReadLimit defines the initial read limit.
 	ReadLimit int64
 
+	// ReadPolicy defines the initial read policy that limits packets per type
+	// and the topics they carry.
+	ReadPolicy *packet.ReadPolicy
+
+	// ValidationProfile defines the initial validation profile of clients.
+	// It is used to validate the Connect packet before the client is
+	// authenticated.
+	ValidationProfile *packet.Profile
+
 	// MaxWriteDelay defines the initial max write delay.
 	MaxWriteDelay time.Duration
 
@@ -92,6 +102,11 @@ func (e *Engine) Handle(conn transport.Conn) bool {
 	// set default read limit
 	conn.SetReadLimit(e.ReadLimit)
 
+	// set initial read policy
+	if e.ReadPolicy != nil {
+		conn.SetReadPolicy(e.ReadPolicy)
+	}
+
 	// set initial max write delay
 	conn.SetMaxWriteDelay(e.MaxWriteDelay)
 
@@ -99,7 +114,7 @@ func (e *Engine) Handle(conn transport.Conn) bool {
 	conn.SetReadTimeout(e.ConnectTimeout)
 
 	// handle client
-	NewClient(e.Backend, conn)
+	newClient(e.Backend, conn, e.ValidationProfile)
 
 	return true
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/256dpi/gomqtt/client"
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/transport"
+	"github.com/256dpi/gomqtt/transport/flow"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,3 +52,29 @@ func TestDefaultReadLimit(t *testing.T) {
 	close(quit)
 	safeReceive(done)
 }
+
+func TestReadPolicy(t *testing.T) {
+	engine := NewEngine(NewMemoryBackend())
+	engine.ReadPolicy = &packet.ReadPolicy{
+		MaxTopicFilters: 1,
+	}
+
+	port, quit, done := Run(engine, "tcp")
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	f := flow.New().
+		Send(packet.NewConnect()).
+		Receive(packet.NewConnack()).
+		Send(&packet.Subscribe{Subscriptions: []packet.Subscription{{Topic: "a"}}, ID: 1}).
+		Receive(&packet.Suback{ID: 1, ReturnCodes: []packet.QOS{0}}).
+		Send(&packet.Subscribe{Subscriptions: []packet.Subscription{{Topic: "a"}, {Topic: "b"}}, ID: 2}).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	close(quit)
+	safeReceive(done)
+}
@@ -0,0 +1,442 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/session"
+	"github.com/256dpi/gomqtt/topic"
+)
+
+// ErrSessionNotFound is returned if a stored session does not exist.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionActive is returned if a session is used by a connected client.
+var ErrSessionActive = errors.New("session active")
+
+// ErrNotPortable is returned if the storage of a session does not support
+// export and import.
+var ErrNotPortable = errors.New("storage not portable")
+
+// ErrInvalidExport is returned if an exported session is malformed.
+var ErrInvalidExport = errors.New("invalid export")
+
+// A PortableStorage is a SessionStorage that supports export and import, e.g.
+// a session.MemorySession or session.FileSession.
+type PortableStorage interface {
+	SessionStorage
+
+	// Export should write the id counter position and all packets of the
+	// storage in order to the writer.
+	Export(w io.Writer) error
+
+	// Import should replace the id counter position and all packets of the
+	// storage with the data read from the reader.
+	Import(r io.Reader) error
+}
+
+// ExportSession will write the stored session with the specified id including
+// its packets, subscriptions and offline queue to the writer. The shared
+// subscription groups of queued and unacknowledged messages are retained.
+// Offline QOS 0 messages are not exported as they are discarded anyway when
+// the session is resumed. Subscriptions and queued messages are written as
+// plain records that do not carry packet ids. The session must not be used by
+// a connected client.
+func (m *MemoryBackend) ExportSession(id string, w io.Writer) error {
+	// acquire global mutex
+	m.globalMutex.Lock()
+	defer m.globalMutex.Unlock()
+
+	// get session
+	sess, ok := m.storedSessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	} else if sess.activeClient != nil {
+		return ErrSessionActive
+	}
+
+	// get storage
+	storage, ok := sess.SessionStorage.(PortableStorage)
+	if !ok {
+		return ErrNotPortable
+	}
+
+	// export storage
+	var buf bytes.Buffer
+	err := storage.Export(&buf)
+	if err != nil {
+		return err
+	}
+
+	// prepare data
+	data := appendBytes(nil, buf.Bytes())
+
+	// add subscriptions
+	subs := sess.subscriptions.All()
+	data = appendUvarint(data, uint64(len(subs)))
+	for _, value := range subs {
+		data = appendSubscription(data, value.(*packet.Subscription))
+	}
+
+	// collect queued messages and put them back
+	var msgs []*packet.Message
+	for len(sess.storedQueue) > 0 {
+		msgs = append(msgs, <-sess.storedQueue)
+	}
+	for _, msg := range msgs {
+		sess.storedQueue <- msg
+	}
+
+	// get shared subscriptions of queued messages and unacknowledged packets
+	sess.sharedMutex.Lock()
+	shares := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		shares = append(shares, sess.sharedQueued[msg])
+	}
+	sharedIDs := make([]int, 0, len(sess.sharedPackets))
+	for id := range sess.sharedPackets {
+		sharedIDs = append(sharedIDs, int(id))
+	}
+	sort.Ints(sharedIDs)
+	sharedNames := make([]string, 0, len(sharedIDs))
+	for _, id := range sharedIDs {
+		sharedNames = append(sharedNames, sess.sharedPackets[packet.ID(id)])
+	}
+	sess.sharedMutex.Unlock()
+
+	// add messages
+	data = appendUvarint(data, uint64(len(msgs)))
+	for i, msg := range msgs {
+		// add message
+		data = appendMessage(data, msg)
+
+		// add shared subscription
+		data = appendBytes(data, []byte(shares[i]))
+	}
+
+	// add shared subscriptions of unacknowledged packets
+	data = appendUvarint(data, uint64(len(sharedIDs)))
+	for i, id := range sharedIDs {
+		data = append(data, byte(id>>8), byte(id))
+		data = appendBytes(data, []byte(sharedNames[i]))
+	}
+
+	// write data
+	_, err = w.Write(data)
+
+	return err
+}
+
+// ImportSession will read a session that has been written using ExportSession
+// from the reader and store it using the specified id. An existing stored
+// session is replaced if it is not used by a connected client.
+func (m *MemoryBackend) ImportSession(id string, r io.Reader) error {
+	// read data
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// read storage
+	storageData, data, err := readBytes(data)
+	if err != nil {
+		return err
+	}
+
+	// read subscription count
+	count, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	// read subscriptions
+	subs := make([]*packet.Subscription, 0, count)
+	for i := 0; i < count; i++ {
+		var sub *packet.Subscription
+		sub, data, err = readSubscription(data)
+		if err != nil {
+			return err
+		}
+
+		subs = append(subs, sub)
+	}
+
+	// read message count
+	count, data, err = readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	// read messages
+	msgs := make([]*packet.Message, 0, count)
+	shares := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		// read message
+		var msg *packet.Message
+		msg, data, err = readMessage(data)
+		if err != nil {
+			return err
+		}
+
+		// read shared subscription
+		var share []byte
+		share, data, err = readBytes(data)
+		if err != nil {
+			return err
+		}
+
+		msgs = append(msgs, msg)
+		shares = append(shares, string(share))
+	}
+
+	// read shared packet count
+	count, data, err = readUvarint(data)
+	if err != nil {
+		return err
+	}
+
+	// read shared subscriptions of unacknowledged packets
+	sharedPackets := make(map[packet.ID]string, count)
+	for i := 0; i < count; i++ {
+		// read id
+		if len(data) < 2 {
+			return ErrInvalidExport
+		}
+		id := packet.ID(binary.BigEndian.Uint16(data))
+		data = data[2:]
+
+		// read shared subscription
+		var share []byte
+		share, data, err = readBytes(data)
+		if err != nil {
+			return err
+		}
+
+		sharedPackets[id] = string(share)
+	}
+
+	// check remaining data
+	if len(data) > 0 {
+		return ErrInvalidExport
+	}
+
+	// validate storage before an existing session is replaced
+	err = session.NewMemorySession().Import(bytes.NewReader(storageData))
+	if err != nil {
+		return err
+	}
+
+	// acquire global mutex
+	m.globalMutex.Lock()
+	defer m.globalMutex.Unlock()
+
+	// return error if closing
+	if m.closing {
+		return ErrClosing
+	}
+
+	// check active clients
+	if _, ok := m.activeClients[id]; ok {
+		return ErrSessionActive
+	}
+
+	// create session
+	sess := newMemorySession(m.SessionQueueSize)
+
+	// get existing session
+	existingSession, replace := m.storedSessions[id]
+
+	// reuse storage of an existing session, otherwise create storage if
+	// available
+	if replace {
+		sess.SessionStorage = existingSession.SessionStorage
+	} else if m.SessionStorage != nil {
+		storage, err := m.SessionStorage(id)
+		if err != nil {
+			return err
+		}
+
+		// set storage
+		sess.SessionStorage = storage
+	}
+
+	// import storage, a reused storage is only changed if the import succeeds
+	storage, ok := sess.SessionStorage.(PortableStorage)
+	if !ok {
+		err = ErrNotPortable
+	} else {
+		err = storage.Import(bytes.NewReader(storageData))
+	}
+	if err != nil {
+		// close created storage
+		if closer, ok := sess.SessionStorage.(io.Closer); ok && !replace {
+			_ = closer.Close()
+		}
+
+		return err
+	}
+
+	// remove existing session
+	if replace {
+		m.sharedSessions.Clear(existingSession)
+		delete(m.storedSessions, id)
+	}
+
+	// add subscriptions
+	for _, sub := range subs {
+		sess.subscriptions.Set(sub.Topic, sub)
+
+		// add session to shared subscription group
+		if group, filter, ok := topic.SplitShare(sub.Topic); ok {
+			m.sharedSessions.AddShared(group, filter, sess)
+		}
+	}
+
+	// queue messages, ignore messages if the queue is full
+	for i, msg := range msgs {
+		select {
+		case sess.storedQueue <- msg:
+			if shares[i] != "" {
+				sess.queueShared(msg, shares[i])
+			}
+		default:
+		}
+	}
+
+	// track shared subscriptions of unacknowledged packets
+	sess.sharedPackets = sharedPackets
+
+	// save session
+	m.storedSessions[id] = sess
+
+	return nil
+}
+
+func appendUvarint(buf []byte, n uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], n)]...)
+}
+
+func appendBytes(buf []byte, data []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendSubscription(buf []byte, sub *packet.Subscription) []byte {
+	// prepare flags
+	flags := byte(sub.QOS) | sub.RetainHandling<<4
+	if sub.NoLocal {
+		flags |= 0x04
+	}
+	if sub.RetainAsPublished {
+		flags |= 0x08
+	}
+
+	// add topic and flags
+	buf = appendBytes(buf, []byte(sub.Topic))
+	return append(buf, flags)
+}
+
+func appendMessage(buf []byte, msg *packet.Message) []byte {
+	// prepare flags
+	flags := byte(msg.QOS)
+	if msg.Retain {
+		flags |= 0x04
+	}
+
+	// add topic, payload and flags
+	buf = appendBytes(buf, []byte(msg.Topic))
+	buf = appendBytes(buf, msg.Payload)
+	return append(buf, flags)
+}
+
+func readUvarint(data []byte) (int, []byte, error) {
+	// read uvarint
+	n, l := binary.Uvarint(data)
+	if l <= 0 || n > uint64(len(data)) {
+		return 0, nil, ErrInvalidExport
+	}
+
+	return int(n), data[l:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	// read length
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// check length
+	if len(data) < n {
+		return nil, nil, ErrInvalidExport
+	}
+
+	return data[:n], data[n:], nil
+}
+
+func readSubscription(data []byte) (*packet.Subscription, []byte, error) {
+	// read topic
+	topic, data, err := readBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// read flags
+	if len(data) < 1 {
+		return nil, nil, ErrInvalidExport
+	}
+	flags := data[0]
+
+	// check flags
+	sub := &packet.Subscription{
+		Topic:             string(topic),
+		QOS:               packet.QOS(flags & 0x03),
+		NoLocal:           flags&0x04 != 0,
+		RetainAsPublished: flags&0x08 != 0,
+		RetainHandling:    flags >> 4 & 0x03,
+	}
+	if !sub.QOS.Successful() || sub.RetainHandling > 2 || flags&0xC0 != 0 {
+		return nil, nil, ErrInvalidExport
+	}
+
+	return sub, data[1:], nil
+}
+
+func readMessage(data []byte) (*packet.Message, []byte, error) {
+	// read topic
+	topic, data, err := readBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// read payload
+	payload, data, err := readBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// read flags
+	if len(data) < 1 {
+		return nil, nil, ErrInvalidExport
+	}
+	flags := data[0]
+
+	// check flags
+	msg := &packet.Message{
+		Topic:   string(topic),
+		Payload: append([]byte(nil), payload...),
+		QOS:     packet.QOS(flags & 0x03),
+		Retain:  flags&0x04 != 0,
+	}
+	if !msg.QOS.Successful() || flags&0xF8 != 0 {
+		return nil, nil, ErrInvalidExport
+	}
+
+	return msg, data[1:], nil
+}
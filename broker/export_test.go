@@ -0,0 +1,164 @@
+package broker
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/session"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBackendExportImportSession(t *testing.T) {
+	backend1 := NewMemoryBackend()
+
+	sess := newMemorySession(10)
+	sess.subscriptions.Set("foo/#", &packet.Subscription{Topic: "foo/#", QOS: 1, RetainAsPublished: true, RetainHandling: 2})
+	sess.subscriptions.Set("$share/g/bar", &packet.Subscription{Topic: "$share/g/bar", QOS: 2, NoLocal: true})
+	sess.storedQueue <- &packet.Message{Topic: "foo/1", Payload: []byte("1"), QOS: 1, Retain: true}
+	sess.storedQueue <- &packet.Message{Topic: "foo/2", Payload: []byte("2"), QOS: 0}
+	sharedMsg := &packet.Message{Topic: "bar", Payload: []byte("3"), QOS: 2}
+	sess.storedQueue <- sharedMsg
+	sess.queueShared(sharedMsg, "$share/g/bar")
+	sess.temporaryQueue <- &packet.Message{Topic: "foo/4", Payload: []byte("4")}
+	backend1.storedSessions["c1"] = sess
+
+	dequeued := &packet.Message{Topic: "foo/0", QOS: 2}
+	sess.queueShared(dequeued, "$share/g/foo")
+	assert.Equal(t, dequeued, sess.dequeue(dequeued))
+
+	publish := packet.NewPublish()
+	publish.ID = sess.NextID()
+	publish.Message = *dequeued
+
+	err := sess.SaveMessagePacket(dequeued, publish)
+	assert.NoError(t, err)
+
+	err = sess.SavePacket(session.Outgoing, &packet.Pubrel{ID: sess.NextID()})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = backend1.ExportSession("c1", &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(sess.storedQueue))
+
+	backend2 := NewMemoryBackend()
+
+	err = backend2.ImportSession("c1", &buf)
+	assert.NoError(t, err)
+
+	imported := backend2.storedSessions["c1"]
+	assert.NotNil(t, imported)
+
+	assert.Equal(t, []interface{}{&packet.Subscription{Topic: "foo/#", QOS: 1, RetainAsPublished: true, RetainHandling: 2}}, imported.subscriptions.Get("foo/#"))
+	assert.Equal(t, []interface{}{&packet.Subscription{Topic: "$share/g/bar", QOS: 2, NoLocal: true}}, imported.subscriptions.Get("$share/g/bar"))
+	assert.Equal(t, []topicShare{{"g", "bar"}}, sharesOf(backend2, "bar"))
+
+	pkts, err := imported.AllPackets(session.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []packet.Generic{publish, &packet.Pubrel{ID: 2}}, pkts)
+	assert.Equal(t, packet.ID(3), imported.NextID())
+
+	assert.Equal(t, 3, len(imported.storedQueue))
+	assert.Equal(t, 0, len(imported.temporaryQueue))
+	assert.Equal(t, &packet.Message{Topic: "foo/1", Payload: []byte("1"), QOS: 1, Retain: true}, <-imported.storedQueue)
+	assert.Equal(t, &packet.Message{Topic: "foo/2", Payload: []byte("2"), QOS: 0}, <-imported.storedQueue)
+
+	msg := <-imported.storedQueue
+	assert.Equal(t, sharedMsg, msg)
+	assert.Equal(t, map[*packet.Message]string{msg: "$share/g/bar"}, imported.sharedQueued)
+	assert.Equal(t, map[packet.ID]string{1: "$share/g/foo"}, imported.sharedPackets)
+}
+
+type nonPortableStorage struct {
+	SessionStorage
+}
+
+func TestMemoryBackendImportSessionFailure(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	var buf bytes.Buffer
+	backend.storedSessions["c1"] = newMemorySession(10)
+	err := backend.ExportSession("c1", &buf)
+	assert.NoError(t, err)
+
+	existing := newMemorySession(10)
+	existing.SessionStorage = nonPortableStorage{session.NewMemorySession()}
+	existing.subscriptions.Set("$share/g/foo", &packet.Subscription{Topic: "$share/g/foo"})
+	backend.storedSessions["c2"] = existing
+	backend.sharedSessions.AddShared("g", "foo", existing)
+
+	err = existing.SavePacket(session.Outgoing, &packet.Pubrel{ID: 1})
+	assert.NoError(t, err)
+
+	err = backend.ImportSession("c2", bytes.NewReader(buf.Bytes()))
+	assert.Equal(t, ErrNotPortable, err)
+	assert.Equal(t, existing, backend.storedSessions["c2"])
+	assert.Equal(t, []topicShare{{"g", "foo"}}, sharesOf(backend, "foo"))
+
+	pkts, err := existing.AllPackets(session.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []packet.Generic{&packet.Pubrel{ID: 1}}, pkts)
+
+	backend.SessionStorage = func(id string) (SessionStorage, error) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	err = backend.ImportSession("c3", bytes.NewReader(buf.Bytes()))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.NotContains(t, backend.storedSessions, "c3")
+}
+
+type topicShare struct {
+	group  string
+	filter string
+}
+
+func sharesOf(backend *MemoryBackend, topic string) []topicShare {
+	var list []topicShare
+	for _, share := range backend.sharedSessions.MatchShared(topic) {
+		list = append(list, topicShare{share.Group, share.Filter})
+	}
+
+	return list
+}
+
+func TestMemoryBackendExportImportSessionErrors(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	err := backend.ExportSession("c1", &bytes.Buffer{})
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	sess := newMemorySession(10)
+	sess.activeClient = &Client{}
+	backend.storedSessions["c1"] = sess
+
+	err = backend.ExportSession("c1", &bytes.Buffer{})
+	assert.Equal(t, ErrSessionActive, err)
+
+	sess.activeClient = nil
+
+	var buf bytes.Buffer
+	err = backend.ExportSession("c1", &buf)
+	assert.NoError(t, err)
+
+	for i := 0; i < buf.Len(); i++ {
+		err = backend.ImportSession("c2", bytes.NewReader(buf.Bytes()[:i]))
+		assert.Error(t, err, i)
+	}
+
+	err = backend.ImportSession("c2", bytes.NewReader([]byte{1, 0, 1, 1, 'a', 0xFF, 0}))
+	assert.Equal(t, ErrInvalidExport, err)
+
+	err = backend.ImportSession("c2", bytes.NewReader([]byte{1, 0, 0, 1, 1, 'a', 0, 0x03, 0}))
+	assert.Equal(t, ErrInvalidExport, err)
+
+	backend.activeClients["c2"] = &Client{}
+
+	err = backend.ImportSession("c2", bytes.NewReader(buf.Bytes()))
+	assert.Equal(t, ErrSessionActive, err)
+
+	assert.NotContains(t, backend.storedSessions, "c2")
+}
@@ -71,13 +71,26 @@ type Session interface {
 	// return an error if no packet with the specified id does exists.
 	DeletePacket(session.Direction, packet.ID) error
 
-	// AllPackets will return all packets currently saved in the session.
+	// AllPackets will return all packets currently saved in the session in the
+	// order they have been saved. Overwritten packets retain their position.
 	AllPackets(session.Direction) ([]packet.Generic, error)
 
 	// Reset will completely reset the session.
 	Reset() error
 }
 
+// An AllocatingSession is an optional extension of a Session that allocates
+// packet ids that are not in use by stored outgoing packets. If the session
+// implements it, the client will fail publishes, subscribes and unsubscribes
+// with the returned error instead of blocking in NextID.
+type AllocatingSession interface {
+	Session
+
+	// AllocateID should return the next id for outgoing packets that is not
+	// in use or an error if all ids are in use.
+	AllocateID() (packet.ID, error)
+}
+
 // A Client connects to a broker and handles the transmission of packets. It will
 // automatically send PingreqPackets to keep the connection alive. Outgoing
 // publish related packets will be stored in session and resent when the
@@ -105,6 +118,12 @@ type Client struct {
 	// inside the callback will deadlock the client.
 	Callback func(msg *packet.Message, err error) error
 
+	// If ReleaseMessages is set to true, received packets are acquired from
+	// the packet pool and messages with a QOS of 0 or 1 are returned to the
+	// pool once the callback returns. The message and its payload must then
+	// not be used after the callback returns.
+	ReleaseMessages bool
+
 	// The logger that is used to log low level information about packets
 	// that have been successfully sent and received and details about the
 	// automatic keep alive handler.
@@ -113,6 +132,7 @@ type Client struct {
 	config        *Config
 	conn          transport.Conn
 	clean         bool
+	version       byte
 	keepAlive     time.Duration
 	tracker       *Tracker
 	futureStore   *future.Store
@@ -189,6 +209,9 @@ func (c *Client) Connect(config *Config) (ConnectFuture, error) {
 	// set max write delay
 	c.conn.SetMaxWriteDelay(c.config.MaxWriteDelay)
 
+	// receive pooled packets if messages are released
+	c.conn.SetPooled(c.ReleaseMessages)
+
 	// set to connecting as from this point the client cannot be reused
 	atomic.StoreUint32(&c.state, clientConnecting)
 
@@ -212,6 +235,9 @@ func (c *Client) Connect(config *Config) (ConnectFuture, error) {
 	connect.KeepAlive = uint16(keepAlive.Seconds())
 	connect.CleanSession = config.CleanSession
 
+	// save version
+	c.version = connect.Version
+
 	// check for credentials
 	if urlParts.User != nil {
 		connect.Username = urlParts.User.Username()
@@ -270,7 +296,12 @@ func (c *Client) PublishMessage(msg *packet.Message) (GenericFuture, error) {
 
 	// set packet id
 	if msg.QOS > 0 {
-		publish.ID = c.Session.NextID()
+		id, err := c.nextID()
+		if err != nil {
+			return nil, err
+		}
+
+		publish.ID = id
 	}
 
 	// create future
@@ -324,9 +355,15 @@ func (c *Client) SubscribeMultiple(subscriptions []packet.Subscription) (Subscri
 		return nil, ErrClientNotConnected
 	}
 
+	// get packet id
+	id, err := c.nextID()
+	if err != nil {
+		return nil, err
+	}
+
 	// allocate subscribe packet
 	subscribe := packet.NewSubscribe()
-	subscribe.ID = c.Session.NextID()
+	subscribe.ID = id
 	subscribe.Subscriptions = subscriptions
 
 	// create future
@@ -336,7 +373,7 @@ func (c *Client) SubscribeMultiple(subscriptions []packet.Subscription) (Subscri
 	c.futureStore.Put(subscribe.ID, subFuture)
 
 	// send packet
-	err := c.send(subscribe, true)
+	err = c.send(subscribe, true)
 	if err != nil {
 		return nil, c.cleanup(err, false, false)
 	}
@@ -367,10 +404,16 @@ func (c *Client) UnsubscribeMultiple(topics []string) (GenericFuture, error) {
 		return nil, ErrClientNotConnected
 	}
 
+	// get packet id
+	id, err := c.nextID()
+	if err != nil {
+		return nil, err
+	}
+
 	// allocate unsubscribe packet
 	unsubscribe := packet.NewUnsubscribe()
 	unsubscribe.Topics = topics
-	unsubscribe.ID = c.Session.NextID()
+	unsubscribe.ID = id
 
 	// create future
 	unsubscribeFuture := future.New()
@@ -379,7 +422,7 @@ func (c *Client) UnsubscribeMultiple(topics []string) (GenericFuture, error) {
 	c.futureStore.Put(unsubscribe.ID, unsubscribeFuture)
 
 	// send packet
-	err := c.send(unsubscribe, true)
+	err = c.send(unsubscribe, true)
 	if err != nil {
 		return nil, c.cleanup(err, false, false)
 	}
@@ -461,6 +504,14 @@ func (c *Client) processor() error {
 			c.Logger(fmt.Sprintf("Received: %s", pkt.String()))
 		}
 
+		// validate packet
+		if c.config.ValidationProfile != nil {
+			err = c.config.ValidationProfile.Validate(pkt, c.version)
+			if err != nil {
+				return c.die(err, true)
+			}
+		}
+
 		// check if first
 		if first {
 			// get connack
@@ -501,6 +552,18 @@ func (c *Client) processor() error {
 		if err != nil {
 			return err // error has already been cleaned
 		}
+
+		// release processed packets if pooled
+		if c.ReleaseMessages {
+			switch typedPkt := pkt.(type) {
+			case *packet.Unsuback, *packet.Pingresp, *packet.Puback, *packet.Pubcomp, *packet.Pubrec, *packet.Pubrel:
+				packet.Release(pkt)
+			case *packet.Publish:
+				if typedPkt.Message.QOS <= 1 {
+					packet.Release(pkt)
+				}
+			}
+		}
 	}
 }
 
@@ -781,6 +844,16 @@ func (c *Client) pinger() error {
 
 /* helpers */
 
+// get the next packet id for an outgoing packet
+func (c *Client) nextID() (packet.ID, error) {
+	// use allocator if available
+	if allocator, ok := c.Session.(AllocatingSession); ok {
+		return allocator.AllocateID()
+	}
+
+	return c.Session.NextID(), nil
+}
+
 // sends packet and updates lastSend
 func (c *Client) send(pkt packet.Generic, async bool) error {
 	// reset keep alive tracker
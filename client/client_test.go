@@ -3,6 +3,7 @@ package client
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -252,6 +253,37 @@ func TestClientExpectedConnack(t *testing.T) {
 	safeReceive(wait)
 }
 
+func TestClientValidationProfile(t *testing.T) {
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Send(&packet.Publish{Message: packet.Message{Topic: "test/+"}}).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	wait := make(chan struct{})
+
+	c := New()
+	c.Callback = func(msg *packet.Message, err error) error {
+		assert.Nil(t, msg)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "MQTT-3.3.2-2")
+		close(wait)
+		return nil
+	}
+
+	config := NewConfig("tcp://localhost:" + port)
+	config.ValidationProfile = packet.LenientProfile
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	safeReceive(done)
+	safeReceive(wait)
+}
+
 func TestClientKeepAlive(t *testing.T) {
 	connect := connectPacket()
 	connect.KeepAlive = 0
@@ -610,6 +642,47 @@ func TestClientUnsubscribe(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestClientNoFreeID(t *testing.T) {
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	c := New()
+	c.Callback = errorCallback(t)
+
+	connectFuture, err := c.Connect(NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	for i := 1; i <= math.MaxUint16; i++ {
+		pubrel := packet.NewPubrel()
+		pubrel.ID = packet.ID(i)
+		err = c.Session.SavePacket(session.Outgoing, pubrel)
+		assert.NoError(t, err)
+	}
+
+	publishFuture, err := c.Publish("test", []byte("test"), 1, false)
+	assert.Equal(t, session.ErrNoFreeID, err)
+	assert.Nil(t, publishFuture)
+
+	subscribeFuture, err := c.Subscribe("test", 0)
+	assert.Equal(t, session.ErrNoFreeID, err)
+	assert.Nil(t, subscribeFuture)
+
+	unsubscribeFuture, err := c.Unsubscribe("test")
+	assert.Equal(t, session.ErrNoFreeID, err)
+	assert.Nil(t, unsubscribeFuture)
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
 func TestClientHardDisconnect(t *testing.T) {
 	connect := connectPacket()
 	connect.ClientID = "test"
@@ -35,6 +35,10 @@ type Config struct {
 	// ValidateSubs will cause the client to fail if subscriptions failed.
 	ValidateSubs bool
 
+	// ValidationProfile can be set to validate received packets against the
+	// profile. The client will fail if an invalid packet is received.
+	ValidationProfile *packet.Profile
+
 	// ReadLimit defines the maximum size of a packet that can be received.
 	ReadLimit int64
 
@@ -0,0 +1,137 @@
+package packet
+
+import "fmt"
+
+// An Auth packet is sent from the client to the server or from the server to
+// the client as part of an extended authentication exchange (MQTT 5 only).
+type Auth struct {
+	// The reason code of the packet.
+	ReasonCode ReasonCode
+
+	// The properties of the packet.
+	Properties Properties
+}
+
+var _ Generic = (*Auth)(nil)
+
+// NewAuth creates a new Auth packet.
+func NewAuth() *Auth {
+	return &Auth{}
+}
+
+// Type returns the packets type.
+func (ap *Auth) Type() Type {
+	return AUTH
+}
+
+// Len returns the byte length of the encoded packet.
+func (ap *Auth) Len(_ byte) int {
+	ml := ap.len()
+	return headerLen(ml) + ml
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (ap *Auth) Decode(version byte, src []byte) (int, error) {
+	// check version
+	if version != Version5 {
+		return 0, makeError(ap.Type(), "packet not supported by protocol version %d", version)
+	}
+
+	// decode header
+	hl, _, rl, err := headerDecode(src, AUTH)
+	total := hl
+	if err != nil {
+		return total, err
+	}
+
+	// reset reason code and properties
+	ap.ReasonCode = ReasonSuccess
+	ap.Properties = Properties{}
+
+	// read reason code if present
+	if rl > 0 {
+		ap.ReasonCode = ReasonCode(src[total])
+		total++
+
+		// check reason code
+		if !ap.ReasonCode.Allowed(AUTH) {
+			return total, makeError(ap.Type(), "invalid reason code (%d)", ap.ReasonCode)
+		}
+	}
+
+	// read properties if present
+	if rl > 1 {
+		n, err := ap.Properties.decode(src[total:hl+rl], ap.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (ap *Auth) Encode(version byte, dst []byte) (int, error) {
+	// check version
+	if version != Version5 {
+		return 0, makeError(ap.Type(), "packet not supported by protocol version %d", version)
+	}
+
+	// check reason code
+	if !ap.ReasonCode.Allowed(AUTH) {
+		return 0, makeError(ap.Type(), "invalid reason code (%d)", ap.ReasonCode)
+	}
+
+	// encode header
+	total, err := headerEncode(dst, 0, ap.len(), ap.Len(version), AUTH)
+	if err != nil {
+		return total, err
+	}
+
+	// write reason code and properties if present
+	if !ap.Properties.Empty() {
+		// write reason code
+		dst[total] = byte(ap.ReasonCode)
+		total++
+
+		// write properties
+		n, err := ap.Properties.encode(dst[total:], ap.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	} else if ap.ReasonCode != ReasonSuccess {
+		// write reason code
+		dst[total] = byte(ap.ReasonCode)
+		total++
+	}
+
+	return total, nil
+}
+
+// String returns a string representation of the packet.
+func (ap *Auth) String() string {
+	// prepare properties
+	properties := ""
+	if !ap.Properties.Empty() {
+		properties = " Properties=" + ap.Properties.String()
+	}
+
+	return fmt.Sprintf("<Auth ReasonCode=%d%s>", ap.ReasonCode, properties)
+}
+
+// Returns the payload length.
+func (ap *Auth) len() int {
+	// add reason code and properties if present
+	if !ap.Properties.Empty() {
+		return 1 + propertiesLen(&ap.Properties)
+	} else if ap.ReasonCode != ReasonSuccess {
+		return 1
+	}
+
+	return 0
+}
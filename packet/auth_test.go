@@ -0,0 +1,127 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthInterface(t *testing.T) {
+	pkt := NewAuth()
+	pkt.ReasonCode = ReasonContinueAuthentication
+	pkt.Properties.AuthenticationMethod = "SCRAM-SHA-1"
+
+	assert.Equal(t, pkt.Type(), AUTH)
+	assert.Equal(t, "<Auth ReasonCode=24 Properties=<Properties AuthenticationMethod=\"SCRAM-SHA-1\">>", pkt.String())
+}
+
+func TestAuthDecode(t *testing.T) {
+	pktBytes := []byte{
+		byte(AUTH << 4),
+		0,
+	}
+
+	pkt := NewAuth()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, ReasonSuccess, pkt.ReasonCode)
+	assert.True(t, pkt.Properties.Empty())
+}
+
+func TestAuthDecodeError1(t *testing.T) {
+	pktBytes := []byte{
+		byte(AUTH << 4),
+		0,
+	}
+
+	pkt := NewAuth()
+	_, err := pkt.Decode(Version311, pktBytes) // < unsupported version
+
+	assert.Error(t, err)
+}
+
+func TestAuthDecodeError2(t *testing.T) {
+	pktBytes := []byte{
+		byte(AUTH << 4),
+		1,
+		0x87, // < invalid reason code
+	}
+
+	pkt := NewAuth()
+	_, err := pkt.Decode(Version5, pktBytes)
+
+	assert.Error(t, err)
+}
+
+func TestAuthDecodeError3(t *testing.T) {
+	pktBytes := []byte{
+		byte(AUTH << 4),
+		3,
+		0x18,                     // reason code
+		1,                        // properties length
+		byte(PropertyTopicAlias), // < not allowed
+	}
+
+	pkt := NewAuth()
+	_, err := pkt.Decode(Version5, pktBytes)
+
+	assert.Error(t, err)
+}
+
+func TestAuthEncodeError1(t *testing.T) {
+	pkt := NewAuth()
+
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst) // < unsupported version
+
+	assert.Error(t, err)
+}
+
+func TestAuthEncodeError2(t *testing.T) {
+	pkt := NewAuth()
+	pkt.ReasonCode = ReasonNotAuthorized // < invalid reason code
+
+	dst := make([]byte, pkt.Len(Version5))
+	_, err := pkt.Encode(Version5, dst)
+
+	assert.Error(t, err)
+}
+
+func TestAuthEqualDecodeEncode(t *testing.T) {
+	pktBytes := []byte{
+		byte(AUTH << 4),
+		9,
+		0x18, // reason code
+		7,    // properties length
+		byte(PropertyAuthenticationData),
+		0, // data MSB
+		4, // data LSB
+		1, 2, 3, 4,
+	}
+
+	pkt := NewAuth()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, ReasonContinueAuthentication, pkt.ReasonCode)
+	assert.Equal(t, []byte{1, 2, 3, 4}, pkt.Properties.AuthenticationData)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	pkt.Properties = Properties{}
+
+	dst = make([]byte, pkt.Len(Version5))
+	n3, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n3)
+	assert.Equal(t, []byte{byte(AUTH << 4), 1, 0x18}, dst[:n3])
+}
@@ -0,0 +1,90 @@
+// Package capture implements a file format to record and replay MQTT packet
+// conversations.
+//
+// A capture starts with a header that consists of the magic bytes "MQCP", the
+// format version and the start time as unix nanoseconds (8 bytes, big-endian).
+// It is followed by a record for every packet. A record consists of a flags
+// byte that holds the direction in the upper and the protocol version in the
+// lower four bits, the time passed since the previous record (or the start) in
+// nanoseconds as an unsigned varint and the encoded packet.
+//
+// Publish packets with a streamed payload are recorded without the payload
+// and set the stream flag (0x40) of the flags byte. Their record contains the
+// payload length as an unsigned varint before the encoded packet. The payload
+// follows in payload records that set the payload flag (0x80) and the
+// direction of the packet. A payload record consists of the flags byte, the
+// time delta, the length of the chunk as an unsigned varint and the chunk.
+// Other records may be interleaved with the payload records of a packet.
+package capture
+
+import (
+	"errors"
+	"time"
+)
+
+// the magic bytes that start a capture
+var magic = [4]byte{'M', 'Q', 'C', 'P'}
+
+// the current format version
+const formatVersion = 1
+
+// the length of the header
+const headerLen = len(magic) + 1 + 8
+
+// ErrInvalidHeader is returned by the Player if the capture does not start
+// with a valid header.
+var ErrInvalidHeader = errors.New("invalid header")
+
+// ErrUnsupportedFormat is returned by the Player if the capture uses an
+// unsupported format version.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// ErrInvalidRecord is returned by the Player if a record is malformed.
+var ErrInvalidRecord = errors.New("invalid record")
+
+// the flags of streamed packet and payload records
+const (
+	streamFlag  = 0x40
+	payloadFlag = 0x80
+)
+
+// the maximum length of a streamed payload
+const maxPayloadLength = 268435455
+
+// Direction defines the direction of a recorded packet.
+type Direction byte
+
+const (
+	// Sent marks packets that have been sent on the connection.
+	Sent Direction = 1
+
+	// Received marks packets that have been received from the connection.
+	Received Direction = 2
+)
+
+// Valid returns whether the direction is valid.
+func (d Direction) Valid() bool {
+	return d == Sent || d == Received
+}
+
+// String returns a string representation of the direction.
+func (d Direction) String() string {
+	switch d {
+	case Sent:
+		return "Sent"
+	case Received:
+		return "Received"
+	}
+
+	return "Unknown"
+}
+
+// returns the nanoseconds passed between two times
+func elapsed(from, to time.Time) uint64 {
+	// handle clocks going backwards
+	if to.Before(from) {
+		return 0
+	}
+
+	return uint64(to.Sub(from))
+}
@@ -0,0 +1,246 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// A Player reads the records of a capture.
+type Player struct {
+	reader  *bufio.Reader
+	decoder *packet.Decoder
+	time    time.Time
+	pending []record
+}
+
+// a record is a packet or a chunk of a streamed payload read from a capture
+type record struct {
+	time      time.Time
+	direction Direction
+	packet    packet.Generic
+	streamed  bool
+	length    int
+	chunk     []byte
+	isChunk   bool
+}
+
+// NewPlayer reads the capture header from the specified reader and returns a
+// Player that reads the subsequent records.
+func NewPlayer(reader io.Reader) (*Player, error) {
+	// prepare buffered reader
+	br := bufio.NewReader(reader)
+
+	// read header
+	header := make([]byte, headerLen)
+	_, err := io.ReadFull(br, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrInvalidHeader
+	} else if err != nil {
+		return nil, err
+	}
+
+	// check magic
+	if !bytes.Equal(header[:len(magic)], magic[:]) {
+		return nil, ErrInvalidHeader
+	}
+
+	// check format version
+	if header[len(magic)] != formatVersion {
+		return nil, ErrUnsupportedFormat
+	}
+
+	// get start time
+	start := time.Unix(0, int64(binary.BigEndian.Uint64(header[len(magic)+1:])))
+
+	return &Player{
+		reader: br,
+		// the decoder shares the buffered reader as bufio.NewReader returns
+		// the passed reader if it already is a large enough buffered reader
+		decoder: packet.NewDecoder(br),
+		time:    start,
+	}, nil
+}
+
+// Next reads the next record and returns the time the packet has been
+// recorded, its direction and the packet. The payload of streamed packets is
+// collected from the subsequent payload records. It returns io.EOF if no
+// records are left.
+func (p *Player) Next() (time.Time, Direction, packet.Generic, error) {
+	// get pending or next record
+	var rec record
+	if len(p.pending) > 0 {
+		rec = p.pending[0]
+		p.pending = p.pending[1:]
+	} else {
+		var err error
+		rec, err = p.read()
+		if err != nil {
+			return time.Time{}, 0, nil, err
+		}
+	}
+
+	// payload records must follow a streamed packet
+	if rec.isChunk {
+		return time.Time{}, 0, nil, ErrInvalidRecord
+	}
+
+	// collect streamed payload
+	if rec.streamed {
+		payload, err := p.collect(rec.direction, rec.length)
+		if err != nil {
+			return time.Time{}, 0, nil, err
+		}
+
+		rec.packet.(*packet.Publish).Message.Payload = payload
+	}
+
+	return rec.time, rec.direction, rec.packet, nil
+}
+
+// collects the payload of a streamed packet, other records are kept pending
+func (p *Player) collect(direction Direction, length int) ([]byte, error) {
+	payload := make([]byte, 0)
+	for len(payload) < length {
+		// get pending payload record
+		var rec record
+		found := false
+		for i, pending := range p.pending {
+			if pending.isChunk && pending.direction == direction {
+				rec = pending
+				p.pending = append(p.pending[:i], p.pending[i+1:]...)
+				found = true
+				break
+			}
+		}
+
+		// otherwise read next record
+		if !found {
+			var err error
+			rec, err = p.read()
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			}
+
+			// keep other records pending
+			if !rec.isChunk || rec.direction != direction {
+				p.pending = append(p.pending, rec)
+				continue
+			}
+		}
+
+		// check length
+		if len(payload)+len(rec.chunk) > length {
+			return nil, ErrInvalidRecord
+		}
+
+		// add chunk
+		payload = append(payload, rec.chunk...)
+	}
+
+	return payload, nil
+}
+
+// reads the next record
+func (p *Player) read() (record, error) {
+	// read flags
+	flags, err := p.reader.ReadByte()
+	if err != nil {
+		return record{}, err
+	}
+
+	// check direction
+	direction := Direction(flags >> 4 & 0x3)
+	if !direction.Valid() {
+		return record{}, ErrInvalidRecord
+	}
+
+	// read time delta
+	delta, err := p.readUvarint()
+	if err != nil {
+		return record{}, err
+	}
+
+	// advance time
+	p.time = p.time.Add(time.Duration(delta))
+
+	// prepare record
+	rec := record{
+		time:      p.time,
+		direction: direction,
+	}
+
+	// read payload record
+	if flags&payloadFlag != 0 {
+		// read length
+		length, err := p.readUvarint()
+		if err != nil {
+			return record{}, err
+		} else if length > maxPayloadLength {
+			return record{}, ErrInvalidRecord
+		}
+
+		// read chunk
+		var chunk bytes.Buffer
+		_, err = io.CopyN(&chunk, p.reader, int64(length))
+		if err == io.EOF {
+			return record{}, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return record{}, err
+		}
+
+		rec.chunk = chunk.Bytes()
+		rec.isChunk = true
+
+		return rec, nil
+	}
+
+	// read payload length of streamed packets
+	if flags&streamFlag != 0 {
+		length, err := p.readUvarint()
+		if err != nil {
+			return record{}, err
+		} else if length > maxPayloadLength {
+			return record{}, ErrInvalidRecord
+		}
+
+		rec.streamed = true
+		rec.length = int(length)
+	}
+
+	// set version
+	p.decoder.SetVersion(flags & 0x0F)
+
+	// read packet
+	pkt, err := p.decoder.Read()
+	if err == io.EOF {
+		return record{}, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return record{}, err
+	}
+
+	// check streamed packet
+	if _, ok := pkt.(*packet.Publish); rec.streamed && !ok {
+		return record{}, ErrInvalidRecord
+	}
+
+	rec.packet = pkt
+
+	return rec, nil
+}
+
+// reads an unsigned varint that must be present
+func (p *Player) readUvarint() (uint64, error) {
+	n, err := binary.ReadUvarint(p.reader)
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,77 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func captureHeader() []byte {
+	return []byte{'M', 'Q', 'C', 'P', 1, 0, 0, 0, 0, 0, 0, 0, 0}
+}
+
+func TestPlayerInvalidHeader(t *testing.T) {
+	_, err := NewPlayer(bytes.NewReader([]byte{'M', 'Q'}))
+	assert.Equal(t, ErrInvalidHeader, err)
+
+	_, err = NewPlayer(bytes.NewReader([]byte{'F', 'O', 'O', 'O', 1, 0, 0, 0, 0, 0, 0, 0, 0}))
+	assert.Equal(t, ErrInvalidHeader, err)
+}
+
+func TestPlayerUnsupportedFormat(t *testing.T) {
+	header := captureHeader()
+	header[4] = 2
+
+	_, err := NewPlayer(bytes.NewReader(header))
+	assert.Equal(t, ErrUnsupportedFormat, err)
+}
+
+func TestPlayerInvalidRecord(t *testing.T) {
+	data := append(captureHeader(), 0x34, 0, 0xC0, 0)
+
+	player, err := NewPlayer(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, ErrInvalidRecord, err)
+}
+
+func TestPlayerUnexpectedEOF(t *testing.T) {
+	data := append(captureHeader(), 0x14, 1, 0xC0)
+
+	player, err := NewPlayer(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestPlayerTime(t *testing.T) {
+	data := append(captureHeader(), 0x14, 10, 0xC0, 0, 0x24, 5, 0xD0, 0)
+
+	player, err := NewPlayer(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	ts, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), ts.UnixNano())
+	assert.Equal(t, Sent, dir)
+	assert.Equal(t, "<Pingreq>", pkt.String())
+
+	ts, dir, pkt, err = player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), ts.UnixNano())
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, "<Pingresp>", pkt.String())
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDirectionString(t *testing.T) {
+	assert.Equal(t, "Sent", Sent.String())
+	assert.Equal(t, "Received", Received.String())
+	assert.Equal(t, "Unknown", Direction(0).String())
+}
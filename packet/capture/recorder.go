@@ -0,0 +1,310 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+)
+
+// A Recorder wraps a transport.Conn and writes every packet that is sent or
+// received on the connection to a capture.
+type Recorder struct {
+	transport.Conn
+
+	writer    io.Writer
+	encoder   *packet.Encoder
+	buffer    bytes.Buffer
+	last      time.Time
+	receiving *payloadRecorder
+	mutex     sync.Mutex
+}
+
+// NewRecorder wraps the connection and writes the capture header to the
+// specified writer.
+func NewRecorder(conn transport.Conn, writer io.Writer) (*Recorder, error) {
+	// create recorder
+	r := &Recorder{
+		Conn:   conn,
+		writer: writer,
+		last:   time.Now(),
+	}
+
+	// create encoder
+	r.encoder = packet.NewEncoder(&r.buffer)
+
+	// prepare header
+	header := make([]byte, headerLen)
+	copy(header, magic[:])
+	header[len(magic)] = formatVersion
+	binary.BigEndian.PutUint64(header[len(magic)+1:], uint64(r.last.UnixNano()))
+
+	// write header
+	_, err := writer.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Send will send the packet on the underlying connection and record it if it
+// has been sent successfully. Publish packets with a streamed payload are
+// recorded before they are sent and their payload is recorded while it is
+// sent.
+func (r *Recorder) Send(pkt packet.Generic, async bool) error {
+	// record streamed packet before sending
+	if isStreamed(pkt) {
+		err := r.recordStream(Sent, pkt.(*packet.Publish))
+		if err != nil {
+			return err
+		}
+
+		return r.Conn.Send(pkt, async)
+	}
+
+	// send packet
+	err := r.Conn.Send(pkt, async)
+	if err != nil {
+		return err
+	}
+
+	return r.record(Sent, pkt, -1)
+}
+
+// SendBatch will send the packets on the underlying connection and record them
+// if they have been sent successfully. If the batch contains packets with a
+// streamed payload, all packets are recorded before they are sent.
+func (r *Recorder) SendBatch(pkts []packet.Generic) error {
+	// check for streamed packets
+	streamed := false
+	for _, pkt := range pkts {
+		if isStreamed(pkt) {
+			streamed = true
+		}
+	}
+
+	// record packets before sending if streamed
+	if streamed {
+		for _, pkt := range pkts {
+			err := r.recordPacket(Sent, pkt)
+			if err != nil {
+				return err
+			}
+		}
+
+		return r.Conn.SendBatch(pkts)
+	}
+
+	// send packets
+	err := r.Conn.SendBatch(pkts)
+	if err != nil {
+		return err
+	}
+
+	// record packets
+	for _, pkt := range pkts {
+		err = r.record(Sent, pkt, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Receive will receive a packet from the underlying connection and record it.
+// Streamed payloads are recorded while they are read. Unread parts of a
+// previously streamed payload are recorded before the next packet is
+// received.
+func (r *Recorder) Receive() (packet.Generic, error) {
+	// record the rest of a previously streamed payload
+	if r.receiving != nil {
+		_, err := io.Copy(ioutil.Discard, r.receiving)
+		r.receiving = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// receive packet
+	pkt, err := r.Conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	// record packet
+	err = r.recordPacket(Received, pkt)
+	if err != nil {
+		return nil, err
+	}
+
+	// remember streamed payload
+	if publish, ok := pkt.(*packet.Publish); ok && publish.PayloadReader != nil {
+		r.receiving = publish.PayloadReader.(*payloadRecorder)
+	}
+
+	return pkt, nil
+}
+
+// records a packet or the header of a streamed packet
+func (r *Recorder) recordPacket(direction Direction, pkt packet.Generic) error {
+	// record streamed packet
+	if isStreamed(pkt) {
+		return r.recordStream(direction, pkt.(*packet.Publish))
+	}
+
+	return r.record(direction, pkt, -1)
+}
+
+// records the header of a streamed packet and replaces the payload reader with
+// a reader that records the payload
+func (r *Recorder) recordStream(direction Direction, publish *packet.Publish) error {
+	// prepare header copy
+	header := packet.NewPublish()
+	header.Message = publish.Message
+	header.Message.Payload = nil
+	header.Dup = publish.Dup
+	header.ID = publish.ID
+	header.Properties = publish.Properties
+
+	// record header
+	err := r.record(direction, header, publish.PayloadLength)
+	if err != nil {
+		return err
+	}
+
+	// replace reader
+	publish.PayloadReader = &payloadRecorder{
+		recorder:  r,
+		direction: direction,
+		reader:    publish.PayloadReader,
+		remaining: publish.PayloadLength,
+	}
+
+	return nil
+}
+
+// writes a single record, a non-negative payload length marks the header of
+// a streamed packet
+func (r *Recorder) record(direction Direction, pkt packet.Generic, length int) error {
+	// acquire mutex
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// reset buffer
+	r.buffer.Reset()
+
+	// write flags placeholder and time delta
+	r.writeHeader()
+
+	// write payload length of streamed packets
+	streamed := length >= 0
+	if streamed {
+		var buf [binary.MaxVarintLen64]byte
+		r.buffer.Write(buf[:binary.PutUvarint(buf[:], uint64(length))])
+	}
+
+	// encode packet (sets version from connect packets)
+	err := r.encoder.Write(pkt, false)
+	if err != nil {
+		return err
+	}
+
+	// set flags
+	flags := byte(direction)<<4 | r.encoder.Version()&0x0F
+	if streamed {
+		flags |= streamFlag
+	}
+	r.buffer.Bytes()[0] = flags
+
+	return r.flush()
+}
+
+// writes a payload record
+func (r *Recorder) recordPayload(direction Direction, chunk []byte) error {
+	// acquire mutex
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// reset buffer
+	r.buffer.Reset()
+
+	// write flags placeholder and time delta
+	r.writeHeader()
+
+	// write chunk
+	var buf [binary.MaxVarintLen64]byte
+	r.buffer.Write(buf[:binary.PutUvarint(buf[:], uint64(len(chunk)))])
+	r.buffer.Write(chunk)
+
+	// set flags
+	r.buffer.Bytes()[0] = byte(direction)<<4 | payloadFlag
+
+	return r.flush()
+}
+
+// writes the flags placeholder and the time delta to the buffer
+func (r *Recorder) writeHeader() {
+	// get time
+	now := time.Now()
+
+	// write header
+	var header [1 + binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[1:], elapsed(r.last, now))
+	r.buffer.Write(header[:1+n])
+
+	// update time
+	r.last = now
+}
+
+// writes the buffered record
+func (r *Recorder) flush() error {
+	_, err := r.writer.Write(r.buffer.Bytes())
+	return err
+}
+
+// a payloadRecorder records a streamed payload while it is read
+type payloadRecorder struct {
+	recorder  *Recorder
+	direction Direction
+	reader    io.Reader
+	remaining int
+}
+
+func (p *payloadRecorder) Read(buf []byte) (int, error) {
+	// check remaining
+	if p.remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	// limit read
+	if len(buf) > p.remaining {
+		buf = buf[:p.remaining]
+	}
+
+	// read chunk
+	n, err := p.reader.Read(buf)
+	if n > 0 {
+		p.remaining -= n
+
+		// record chunk
+		rErr := p.recorder.recordPayload(p.direction, buf[:n])
+		if rErr != nil {
+			return n, rErr
+		}
+	}
+
+	return n, err
+}
+
+// returns whether the packet is a publish packet with a streamed payload
+func isStreamed(pkt packet.Generic) bool {
+	publish, ok := pkt.(*packet.Publish)
+	return ok && publish.PayloadReader != nil
+}
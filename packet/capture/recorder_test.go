@@ -0,0 +1,219 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	c1, c2 := net.Pipe()
+
+	conn1 := transport.NewNetConn(c1)
+	conn2 := transport.NewNetConn(c2)
+
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(conn1, &buf)
+	assert.NoError(t, err)
+
+	connect := packet.NewConnect()
+	connect.Version = packet.Version5
+	connect.ClientID = "c"
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = []byte("p")
+
+	done := make(chan struct{})
+	go func() {
+		pkt, err := conn2.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, connect, pkt)
+
+		err = conn2.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		err = conn2.Send(publish, false)
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	err = recorder.Send(connect, false)
+	assert.NoError(t, err)
+
+	pkt, err := recorder.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	pkt, err = recorder.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, publish, pkt)
+
+	<-done
+
+	err = recorder.Close()
+	assert.NoError(t, err)
+
+	player, err := NewPlayer(&buf)
+	assert.NoError(t, err)
+
+	ts1, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Sent, dir)
+	assert.Equal(t, connect, pkt)
+
+	ts2, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, &packet.Connack{}, pkt)
+	assert.False(t, ts2.Before(ts1))
+
+	ts3, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, publish, pkt)
+	assert.False(t, ts3.Before(ts2))
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRecorderStreaming(t *testing.T) {
+	c1, c2 := net.Pipe()
+
+	conn1 := transport.NewNetConn(c1)
+	conn1.SetStreamingThreshold(10)
+
+	conn2 := transport.NewNetConn(c2)
+
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(conn1, &buf)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = bytes.Repeat([]byte("x"), 100)
+
+	go func() {
+		err := conn2.Send(publish, false)
+		assert.NoError(t, err)
+	}()
+
+	pkt, err := recorder.Receive()
+	assert.NoError(t, err)
+
+	payload, err := ioutil.ReadAll(pkt.(*packet.Publish).PayloadReader)
+	assert.NoError(t, err)
+	assert.Equal(t, publish.Message.Payload, payload)
+
+	player, err := NewPlayer(&buf)
+	assert.NoError(t, err)
+
+	_, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, publish, pkt)
+}
+
+func TestRecorderStreamingPartialRead(t *testing.T) {
+	c1, c2 := net.Pipe()
+
+	conn1 := transport.NewNetConn(c1)
+	conn1.SetStreamingThreshold(10)
+
+	conn2 := transport.NewNetConn(c2)
+
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(conn1, &buf)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = bytes.Repeat([]byte("x"), 100)
+
+	go func() {
+		err := conn2.Send(publish, false)
+		assert.NoError(t, err)
+
+		err = conn2.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+	}()
+
+	pkt, err := recorder.Receive()
+	assert.NoError(t, err)
+
+	chunk := make([]byte, 10)
+	_, err = io.ReadFull(pkt.(*packet.Publish).PayloadReader, chunk)
+	assert.NoError(t, err)
+
+	pkt, err = recorder.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.NewPingreq(), pkt)
+
+	player, err := NewPlayer(&buf)
+	assert.NoError(t, err)
+
+	_, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, publish, pkt)
+
+	_, dir, pkt, err = player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, packet.NewPingreq(), pkt)
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRecorderStreamingInterleaved(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(nil, &buf)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = []byte("hello world")
+
+	streamed := packet.NewPublish()
+	streamed.Message.Topic = "t"
+	streamed.PayloadReader = bytes.NewReader(publish.Message.Payload)
+	streamed.PayloadLength = len(publish.Message.Payload)
+
+	err = recorder.recordPacket(Sent, streamed)
+	assert.NoError(t, err)
+
+	_, err = io.ReadFull(streamed.PayloadReader, make([]byte, 5))
+	assert.NoError(t, err)
+
+	err = recorder.recordPacket(Received, packet.NewPingreq())
+	assert.NoError(t, err)
+
+	_, err = ioutil.ReadAll(streamed.PayloadReader)
+	assert.NoError(t, err)
+
+	player, err := NewPlayer(&buf)
+	assert.NoError(t, err)
+
+	_, dir, pkt, err := player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Sent, dir)
+	assert.Equal(t, publish, pkt)
+
+	_, dir, pkt, err = player.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Received, dir)
+	assert.Equal(t, packet.NewPingreq(), pkt)
+
+	_, _, _, err = player.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -40,6 +40,26 @@ func (cc ConnackCode) String() string {
 	return "invalid connack code"
 }
 
+// ReasonCode returns the corresponding MQTT 5 reason code for the ConnackCode.
+func (cc ConnackCode) ReasonCode() ReasonCode {
+	switch cc {
+	case ConnectionAccepted:
+		return ReasonSuccess
+	case InvalidProtocolVersion:
+		return ReasonUnsupportedProtocolVersion
+	case IdentifierRejected:
+		return ReasonClientIdentifierNotValid
+	case ServerUnavailable:
+		return ReasonServerUnavailable
+	case BadUsernameOrPassword:
+		return ReasonBadUsernameOrPassword
+	case NotAuthorized:
+		return ReasonNotAuthorized
+	}
+
+	return ReasonUnspecifiedError
+}
+
 // A Connack packet is sent by the server in response to a Connect packet
 // received from a client.
 type Connack struct {
@@ -52,6 +72,14 @@ type Connack struct {
 	// is unable to process it for some reason, then the server should attempt
 	// to send a Connack containing a non-zero ReturnCode.
 	ReturnCode ConnackCode
+
+	// The reason code of the packet (MQTT 5 only). If zero, the reason code
+	// is derived from the ReturnCode when encoding. When decoding, the
+	// ReturnCode is set to the closest matching code.
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewConnack creates a new Connack packet.
@@ -66,27 +94,43 @@ func (cp *Connack) Type() Type {
 
 // String returns a string representation of the packet.
 func (cp *Connack) String() string {
-	return fmt.Sprintf("<Connack SessionPresent=%t ReturnCode=%d>",
-		cp.SessionPresent, cp.ReturnCode)
+	// prepare properties
+	properties := ""
+	if !cp.Properties.Empty() {
+		properties = " Properties=" + cp.Properties.String()
+	}
+
+	// prepare reason code
+	reasonCode := ""
+	if cp.ReasonCode != 0 {
+		reasonCode = fmt.Sprintf(" ReasonCode=%d", cp.ReasonCode)
+	}
+
+	return fmt.Sprintf("<Connack SessionPresent=%t ReturnCode=%d%s%s>",
+		cp.SessionPresent, cp.ReturnCode, reasonCode, properties)
 }
 
 // Len returns the byte length of the encoded packet.
-func (cp *Connack) Len() int {
-	return headerLen(2) + 2
+func (cp *Connack) Len(version byte) int {
+	ml := cp.len(version)
+	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (cp *Connack) Decode(src []byte) (int, error) {
+func (cp *Connack) Decode(version byte, src []byte) (int, error) {
 	// decode header
-	total, _, rl, err := headerDecode(src, CONNACK)
+	hl, _, rl, err := headerDecode(src, CONNACK)
+	total := hl
 	if err != nil {
 		return total, err
 	}
 
 	// check remaining length
-	if rl != 2 {
+	if version != Version5 && rl != 2 {
 		return total, makeError(cp.Type(), "expected remaining length to be 2")
+	} else if version == Version5 && rl < 3 {
+		return total, makeError(cp.Type(), "expected remaining length to be greater than 2, got %d", rl)
 	}
 
 	// read connack flags
@@ -99,13 +143,37 @@ func (cp *Connack) Decode(src []byte) (int, error) {
 		return total, makeError(cp.Type(), "bits 7-1 in acknowledge flags are not 0")
 	}
 
-	// read return code
-	cp.ReturnCode = ConnackCode(src[total])
+	// decode return code
+	if version != Version5 {
+		// read return code
+		cp.ReturnCode = ConnackCode(src[total])
+		total++
+
+		// check return code
+		if !cp.ReturnCode.Valid() {
+			return total, makeError(cp.Type(), "invalid return code (%d)", cp.ReturnCode)
+		}
+
+		return total, nil
+	}
+
+	// read reason code
+	cp.ReasonCode = ReasonCode(src[total])
 	total++
 
-	// check return code
-	if !cp.ReturnCode.Valid() {
-		return total, makeError(cp.Type(), "invalid return code (%d)", cp.ReturnCode)
+	// check reason code
+	if !cp.ReasonCode.Allowed(CONNACK) {
+		return total, makeError(cp.Type(), "invalid reason code (%d)", cp.ReasonCode)
+	}
+
+	// set return code
+	cp.ReturnCode = cp.ReasonCode.ConnackCode()
+
+	// read properties
+	n, err := cp.Properties.decode(src[total:hl+rl], cp.Type(), false)
+	total += n
+	if err != nil {
+		return total, err
 	}
 
 	return total, nil
@@ -114,9 +182,9 @@ func (cp *Connack) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (cp *Connack) Encode(dst []byte) (int, error) {
+func (cp *Connack) Encode(version byte, dst []byte) (int, error) {
 	// encode header
-	total, err := headerEncode(dst, 0, 2, cp.Len(), CONNACK)
+	total, err := headerEncode(dst, 0, cp.len(version), cp.Len(version), CONNACK)
 	if err != nil {
 		return total, err
 	}
@@ -129,14 +197,55 @@ func (cp *Connack) Encode(dst []byte) (int, error) {
 	}
 	total++
 
-	// check return code
-	if !cp.ReturnCode.Valid() {
-		return total, makeError(cp.Type(), "invalid return code (%d)", cp.ReturnCode)
+	// encode return code
+	if version != Version5 {
+		// check return code
+		if !cp.ReturnCode.Valid() {
+			return total, makeError(cp.Type(), "invalid return code (%d)", cp.ReturnCode)
+		}
+
+		// set return code
+		dst[total] = byte(cp.ReturnCode)
+		total++
+
+		return total, nil
 	}
 
-	// set return code
-	dst[total] = byte(cp.ReturnCode)
+	// get reason code
+	reasonCode := cp.ReasonCode
+	if reasonCode == 0 {
+		reasonCode = cp.ReturnCode.ReasonCode()
+	}
+
+	// check reason code
+	if !reasonCode.Allowed(CONNACK) {
+		return total, makeError(cp.Type(), "invalid reason code (%d)", reasonCode)
+	}
+
+	// set reason code
+	dst[total] = byte(reasonCode)
 	total++
 
+	// write properties
+	n, err := cp.Properties.encode(dst[total:], cp.Type(), false)
+	total += n
+	if err != nil {
+		return total, err
+	}
+
 	return total, nil
 }
+
+// Returns the payload length.
+func (cp *Connack) len(version byte) int {
+	// add 1 byte acknowledge flags
+	// add 1 byte return code
+	total := 1 + 1
+
+	// add the properties length
+	if version == Version5 {
+		total += propertiesLen(&cp.Properties)
+	}
+
+	return total
+}
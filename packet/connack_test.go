@@ -33,7 +33,7 @@ func TestConnackDecode(t *testing.T) {
 
 	pkt := NewConnack()
 
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
@@ -51,7 +51,7 @@ func TestConnackDecodeError1(t *testing.T) {
 
 	pkt := NewConnack()
 
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 	assert.Error(t, err)
 }
 
@@ -65,7 +65,7 @@ func TestConnackDecodeError2(t *testing.T) {
 
 	pkt := NewConnack()
 
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 	assert.Error(t, err)
 }
 
@@ -79,7 +79,7 @@ func TestConnackDecodeError3(t *testing.T) {
 
 	pkt := NewConnack()
 
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 	assert.Error(t, err)
 }
 
@@ -93,7 +93,7 @@ func TestConnackDecodeError4(t *testing.T) {
 
 	pkt := NewConnack()
 
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 	assert.Error(t, err)
 }
 
@@ -107,7 +107,7 @@ func TestConnackDecodeError5(t *testing.T) {
 
 	pkt := NewConnack()
 
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 	assert.Error(t, err)
 }
 
@@ -123,8 +123,8 @@ func TestConnackEncode(t *testing.T) {
 	pkt.ReturnCode = ConnectionAccepted
 	pkt.SessionPresent = true
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
@@ -135,7 +135,7 @@ func TestConnackEncodeError1(t *testing.T) {
 	pkt := NewConnack()
 
 	dst := make([]byte, 3) // < wrong buffer size
-	n, err := pkt.Encode(dst)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -145,8 +145,8 @@ func TestConnackEncodeError2(t *testing.T) {
 	pkt := NewConnack()
 	pkt.ReturnCode = 11 // < wrong return code
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 3, n)
@@ -161,19 +161,19 @@ func TestConnackEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewConnack()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 
-	dst := make([]byte, pkt.Len())
-	n2, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n3)
@@ -184,10 +184,10 @@ func BenchmarkConnackEncode(b *testing.B) {
 	pkt.ReturnCode = ConnectionAccepted
 	pkt.SessionPresent = true
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -205,9 +205,78 @@ func BenchmarkConnackDecode(b *testing.B) {
 	pkt := NewConnack()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestConnackEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(CONNACK << 4),
+		8,
+		1, // session present
+		0, // connection accepted
+		5, // properties length
+		byte(PropertyReasonString),
+		0, // reason string MSB
+		2, // reason string LSB
+		'o', 'k',
+	}
+
+	pkt := NewConnack()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.True(t, pkt.SessionPresent)
+	assert.Equal(t, "ok", pkt.Properties.ReasonString)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	n3, err := pkt.Decode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n3)
+}
+
+func TestConnackReasonCodeVersion5(t *testing.T) {
+	pkt := NewConnack()
+	pkt.ReturnCode = BadUsernameOrPassword
+
+	dst := make([]byte, pkt.Len(Version5))
+	n, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{byte(CONNACK << 4), 3, 0, 0x86, 0}, dst[:n])
+
+	pkt = NewConnack()
+	pkt.ReasonCode = ReasonBanned
+
+	n, err = pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{byte(CONNACK << 4), 3, 0, 0x8A, 0}, dst[:n])
+	assert.Equal(t, "<Connack SessionPresent=false ReturnCode=0 ReasonCode=138>", pkt.String())
+
+	pkt = NewConnack()
+	_, err = pkt.Decode(Version5, dst[:n])
+
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonBanned, pkt.ReasonCode)
+	assert.Equal(t, NotAuthorized, pkt.ReturnCode)
+
+	pkt.ReasonCode = ReasonNoMatchingSubscribers // < invalid reason code
+
+	_, err = pkt.Encode(Version5, dst)
+	assert.Error(t, err)
+
+	_, err = pkt.Decode(Version5, []byte{byte(CONNACK << 4), 3, 0, 0x10, 0})
+	assert.Error(t, err)
+}
@@ -8,10 +8,12 @@ import (
 
 // The supported MQTT versions.
 const (
+	Version5   byte = 5
 	Version311 byte = 4
 	Version31  byte = 3
 )
 
+var version5Name = []byte("MQTT")
 var version311Name = []byte("MQTT")
 var version31Name = []byte("MQIsdp")
 
@@ -36,8 +38,14 @@ type Connect struct {
 	// The will message.
 	Will *Message
 
-	// The MQTT version 3 or 4 (defaults to 4 when 0).
+	// The MQTT version 3, 4 or 5 (defaults to 4 when 0).
 	Version byte
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
+
+	// The properties of the will message (MQTT 5 only).
+	WillProperties Properties
 }
 
 // NewConnect creates a new Connect packet.
@@ -61,8 +69,17 @@ func (cp *Connect) String() string {
 		will = cp.Will.String()
 	}
 
+	// prepare properties
+	properties := ""
+	if !cp.Properties.Empty() {
+		properties += " Properties=" + cp.Properties.String()
+	}
+	if !cp.WillProperties.Empty() {
+		properties += " WillProperties=" + cp.WillProperties.String()
+	}
+
 	return fmt.Sprintf("<Connect ClientID=%q KeepAlive=%d Username=%q "+
-		"Password=%q CleanSession=%t Will=%s Version=%d>",
+		"Password=%q CleanSession=%t Will=%s Version=%d%s>",
 		cp.ClientID,
 		cp.KeepAlive,
 		cp.Username,
@@ -70,18 +87,21 @@ func (cp *Connect) String() string {
 		cp.CleanSession,
 		will,
 		cp.Version,
+		properties,
 	)
 }
 
-// Len returns the byte length of the encoded packet.
-func (cp *Connect) Len() int {
+// Len returns the byte length of the encoded packet. The Connect packet is
+// always encoded using the version set on the packet.
+func (cp *Connect) Len(_ byte) int {
 	ml := cp.len()
 	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (cp *Connect) Decode(src []byte) (int, error) {
+// The version of the Connect packet is always read from the byte slice.
+func (cp *Connect) Decode(_ byte, src []byte) (int, error) {
 	// decode header
 	total, _, _, err := headerDecode(src, CONNECT)
 	if err != nil {
@@ -105,7 +125,7 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 	total++
 
 	// check protocol string and version
-	if versionByte != Version311 && versionByte != Version31 {
+	if versionByte != Version5 && versionByte != Version311 && versionByte != Version31 {
 		return total, makeError(cp.Type(), "invalid protocol version (%d)", versionByte)
 	}
 
@@ -113,7 +133,7 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 	cp.Version = versionByte
 
 	// check protocol version string
-	if !bytes.Equal(protoName, version311Name) && !bytes.Equal(protoName, version31Name) {
+	if !bytes.Equal(protoName, version5Name) && !bytes.Equal(protoName, version311Name) && !bytes.Equal(protoName, version31Name) {
 		return total, makeError(cp.Type(), "invalid protocol version description (%s)", protoName)
 	}
 
@@ -155,7 +175,7 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 	}
 
 	// check auth flags
-	if !usernameFlag && passwordFlag {
+	if cp.Version != Version5 && !usernameFlag && passwordFlag {
 		return total, makeError(cp.Type(), "password flag is set but username flag is not set")
 	}
 
@@ -168,6 +188,15 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 	cp.KeepAlive = binary.BigEndian.Uint16(src[total:])
 	total += 2
 
+	// read properties
+	if cp.Version == Version5 {
+		n, err = cp.Properties.decode(src[total:], cp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// read client id
 	cp.ClientID, n, err = readLPString(src[total:], cp.Type())
 	total += n
@@ -182,6 +211,15 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 
 	// check will
 	if cp.Will != nil {
+		// read will properties
+		if cp.Version == Version5 {
+			n, err = cp.WillProperties.decode(src[total:], cp.Type(), true)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+
 		// read will topic
 		cp.Will.Topic, n, err = readLPString(src[total:], cp.Type())
 		total += n
@@ -221,9 +259,10 @@ func (cp *Connect) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (cp *Connect) Encode(dst []byte) (int, error) {
+// The Connect packet is always encoded using the version set on the packet.
+func (cp *Connect) Encode(_ byte, dst []byte) (int, error) {
 	// encode header
-	total, err := headerEncode(dst, 0, cp.len(), cp.Len(), CONNECT)
+	total, err := headerEncode(dst, 0, cp.len(), cp.Len(cp.Version), CONNECT)
 	if err != nil {
 		return total, err
 	}
@@ -234,12 +273,18 @@ func (cp *Connect) Encode(dst []byte) (int, error) {
 	}
 
 	// check version byte
-	if cp.Version != Version311 && cp.Version != Version31 {
+	if cp.Version != Version5 && cp.Version != Version311 && cp.Version != Version31 {
 		return total, makeError(cp.Type(), "unsupported protocol version %d", cp.Version)
 	}
 
 	// write version string, length has been checked beforehand
-	if cp.Version == Version311 {
+	if cp.Version == Version5 {
+		n, err := writeLPBytes(dst[total:], version5Name, cp.Type())
+		if err != nil {
+			return total, err
+		}
+		total += n
+	} else if cp.Version == Version311 {
 		n, err := writeLPBytes(dst[total:], version311Name, cp.Type())
 		if err != nil {
 			return total, err
@@ -312,6 +357,15 @@ func (cp *Connect) Encode(dst []byte) (int, error) {
 	binary.BigEndian.PutUint16(dst[total:], cp.KeepAlive)
 	total += 2
 
+	// write properties
+	if cp.Version == Version5 {
+		n, err := cp.Properties.encode(dst[total:], cp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// write client id
 	n, err := writeLPString(dst[total:], cp.ClientID, cp.Type())
 	total += n
@@ -321,6 +375,15 @@ func (cp *Connect) Encode(dst []byte) (int, error) {
 
 	// check will
 	if cp.Will != nil {
+		// write will properties
+		if cp.Version == Version5 {
+			n, err = cp.WillProperties.encode(dst[total:], cp.Type(), true)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+
 		// write will topic
 		n, err = writeLPString(dst[total:], cp.Will.Topic, cp.Type())
 		total += n
@@ -337,7 +400,7 @@ func (cp *Connect) Encode(dst []byte) (int, error) {
 	}
 
 	// check username and password
-	if len(cp.Username) == 0 && len(cp.Password) > 0 {
+	if cp.Version != Version5 && len(cp.Username) == 0 && len(cp.Password) > 0 {
 		return total, makeError(cp.Type(), "password set without username")
 	}
 
@@ -384,12 +447,22 @@ func (cp *Connect) len() int {
 	// add 2 bytes keep alive timer
 	total += 1 + 2
 
+	// add the properties length
+	if cp.Version == Version5 {
+		total += propertiesLen(&cp.Properties)
+	}
+
 	// add the clientID length
 	total += 2 + len(cp.ClientID)
 
 	// add the will topic and will message length
 	if cp.Will != nil {
 		total += 2 + len(cp.Will.Topic) + 2 + len(cp.Will.Payload)
+
+		// add the will properties length
+		if cp.Version == Version5 {
+			total += propertiesLen(&cp.WillProperties)
+		}
 	}
 
 	// add the username length
@@ -47,7 +47,7 @@ func TestConnectDecode1(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -89,7 +89,7 @@ func TestConnectDecode2(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -112,7 +112,7 @@ func TestConnectDecodeError1(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -127,7 +127,7 @@ func TestConnectDecodeError2(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -143,7 +143,7 @@ func TestConnectDecodeError3(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -159,7 +159,7 @@ func TestConnectDecodeError4(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -175,7 +175,7 @@ func TestConnectDecodeError5(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -192,7 +192,7 @@ func TestConnectDecodeError6(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -209,7 +209,7 @@ func TestConnectDecodeError7(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -226,7 +226,7 @@ func TestConnectDecodeError8(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -243,7 +243,7 @@ func TestConnectDecodeError9(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -260,7 +260,7 @@ func TestConnectDecodeError10(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -279,7 +279,7 @@ func TestConnectDecodeError11(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -301,7 +301,7 @@ func TestConnectDecodeError12(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -322,7 +322,7 @@ func TestConnectDecodeError13(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -345,7 +345,7 @@ func TestConnectDecodeError14(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -370,7 +370,7 @@ func TestConnectDecodeError15(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -393,7 +393,7 @@ func TestConnectDecodeError16(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -418,7 +418,7 @@ func TestConnectDecodeError17(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -463,8 +463,8 @@ func TestConnectEncode1(t *testing.T) {
 	pkt.Username = "gomqtt"
 	pkt.Password = "verysecret"
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -491,8 +491,8 @@ func TestConnectEncode2(t *testing.T) {
 	pkt.KeepAlive = 10
 	pkt.Version = Version31
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -519,8 +519,8 @@ func TestConnectEncode3(t *testing.T) {
 	pkt.KeepAlive = 10
 	pkt.Version = 0
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -531,7 +531,7 @@ func TestConnectEncodeError1(t *testing.T) {
 	pkt := NewConnect()
 
 	dst := make([]byte, 4) // < too small buffer
-	n, err := pkt.Encode(dst)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -544,8 +544,8 @@ func TestConnectEncodeError2(t *testing.T) {
 		QOS:   3, // < wrong qos
 	}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 9, n)
@@ -555,8 +555,8 @@ func TestConnectEncodeError3(t *testing.T) {
 	pkt := NewConnect()
 	pkt.ClientID = string(make([]byte, 65536)) // < too big
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 14, n)
@@ -568,8 +568,8 @@ func TestConnectEncodeError4(t *testing.T) {
 		Topic: string(make([]byte, 65536)), // < too big
 	}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 16, n)
@@ -582,8 +582,8 @@ func TestConnectEncodeError5(t *testing.T) {
 		Payload: make([]byte, 65536), // < too big
 	}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 19, n)
@@ -593,8 +593,8 @@ func TestConnectEncodeError6(t *testing.T) {
 	pkt := NewConnect()
 	pkt.Username = string(make([]byte, 65536)) // < too big
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 16, n)
@@ -604,8 +604,8 @@ func TestConnectEncodeError7(t *testing.T) {
 	pkt := NewConnect()
 	pkt.Password = "p" // < missing username
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 14, n)
@@ -616,8 +616,8 @@ func TestConnectEncodeError8(t *testing.T) {
 	pkt.Username = "u"
 	pkt.Password = string(make([]byte, 65536)) // < too big
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 19, n)
@@ -629,8 +629,8 @@ func TestConnectEncodeError9(t *testing.T) {
 		// < missing topic
 	}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 9, n)
@@ -640,8 +640,8 @@ func TestConnectEncodeError10(t *testing.T) {
 	pkt := NewConnect()
 	pkt.Version = 255
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 2, n)
@@ -651,8 +651,8 @@ func TestConnectEncodeError11(t *testing.T) {
 	pkt := NewConnect()
 	pkt.CleanSession = false // < client id is empty
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 9, n)
@@ -687,19 +687,19 @@ func TestConnectEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewConnect()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
 
-	dst := make([]byte, pkt.Len())
-	n2, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n3)
@@ -718,10 +718,10 @@ func BenchmarkConnectEncode(b *testing.B) {
 	pkt.Username = "u"
 	pkt.Password = "p"
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -759,9 +759,115 @@ func BenchmarkConnectDecode(b *testing.B) {
 	pkt := NewConnect()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestConnectDecodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(CONNECT << 4),
+		35,
+		0, // Protocol String MSB
+		4, // Protocol String LSB
+		'M', 'Q', 'T', 'T',
+		5,  // Protocol level 5
+		14, // Connect Flags
+		0,  // Keep Alive MSB
+		10, // Keep Alive LSB
+		5,  // Properties Length
+		byte(PropertySessionExpiryInterval),
+		0, 0, 0, 60, // Session Expiry Interval
+		0, // Client ID MSB
+		2, // Client ID LSB
+		'i', 'd',
+		2, // Will Properties Length
+		byte(PropertyPayloadFormatIndicator),
+		1, // Payload Format Indicator
+		0, // Will Topic MSB
+		4, // Will Topic LSB
+		'w', 'i', 'l', 'l',
+		0, // Will Message MSB
+		4, // Will Message LSB
+		'h', 'o', 'm', 'e',
+	}
+
+	pkt := NewConnect()
+	n, err := pkt.Decode(Version311, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, Version5, pkt.Version)
+	assert.Equal(t, uint16(10), pkt.KeepAlive)
+	assert.Equal(t, "id", pkt.ClientID)
+	assert.Equal(t, uint32(60), *pkt.Properties.SessionExpiryInterval)
+	assert.Equal(t, "will", pkt.Will.Topic)
+	assert.Equal(t, []byte("home"), pkt.Will.Payload)
+	assert.True(t, *pkt.WillProperties.PayloadFormatIndicator)
+
+	dst := make([]byte, pkt.Len(Version311))
+	n2, err := pkt.Encode(Version311, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+}
+
+func TestConnectDecodeVersion5Error(t *testing.T) {
+	pktBytes := []byte{
+		byte(CONNECT << 4),
+		17,
+		0, // Protocol String MSB
+		4, // Protocol String LSB
+		'M', 'Q', 'T', 'T',
+		5, // Protocol level 5
+		2, // Connect Flags
+		0, // Keep Alive MSB
+		0, // Keep Alive LSB
+		2, // Properties Length
+		byte(PropertyTopicAlias),
+		0, // Topic Alias (not allowed)
+		0, // Client ID MSB
+		2, // Client ID LSB
+		'i', 'd',
+	}
+
+	pkt := NewConnect()
+	_, err := pkt.Decode(Version311, pktBytes)
+
+	assert.Error(t, err)
+}
+
+func TestConnectEqualDecodeEncodeVersion5(t *testing.T) {
+	expiry := uint32(10)
+
+	pkt := NewConnect()
+	pkt.Version = Version5
+	pkt.ClientID = "gomqtt"
+	pkt.Password = "secret"
+	pkt.Properties.AuthenticationMethod = "token"
+	pkt.Properties.UserProperties = []UserProperty{{"a", "b"}}
+	pkt.Will = &Message{
+		Topic:   "w",
+		Payload: []byte("m"),
+		QOS:     QOSAtLeastOnce,
+	}
+	pkt.WillProperties.MessageExpiryInterval = &expiry
+
+	assert.Equal(t, "<Connect ClientID=\"gomqtt\" KeepAlive=0 Username=\"\" Password=\"secret\" CleanSession=true Will=<Message Topic=\"w\" QOS=1 Retain=false Payload=[109]> Version=5 Properties=<Properties AuthenticationMethod=\"token\" UserProperty=\"a\"=>\"b\"> WillProperties=<Properties MessageExpiryInterval=10>>", pkt.String())
+
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(dst), n)
+
+	pkt2 := NewConnect()
+	n2, err := pkt2.Decode(Version311, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(dst), n2)
+	assert.Equal(t, pkt, pkt2)
+}
@@ -0,0 +1,681 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field describes a region of an encoded packet.
+type Field struct {
+	// The name of the field.
+	Name string
+
+	// The offset and length of the field in the encoded packet.
+	Offset int
+	Length int
+
+	// The human readable value of the field.
+	Value string
+
+	// The nested fields if any.
+	Fields []Field
+}
+
+// Dissection is the annotated structure of an encoded packet.
+type Dissection struct {
+	// The dissected bytes.
+	Data []byte
+
+	// The version used to dissect the packet.
+	Version byte
+
+	// The detected packet type.
+	Type Type
+
+	// The fixed header, variable header and payload fields.
+	Fields []Field
+
+	// The error returned by the decoder, the offset at which the decoder gave
+	// up and the length of the region that caused the failure.
+	Error       error
+	ErrorOffset int
+	ErrorLength int
+}
+
+// Dissect annotates the passed bytes with the fields of the encoded packet
+// and the position where decoding fails. The fields are read using the same
+// helpers as the decoder. The version of a Connect packet is always read from
+// the byte slice.
+func Dissect(version byte, src []byte) *Dissection {
+	// prepare dissection
+	d := &Dissection{
+		Data:    src,
+		Version: version,
+	}
+
+	// check buffer size
+	if len(src) == 0 {
+		d.Error = makeError(0, "insufficient buffer size, expected %d, got %d", 2, 0)
+		return d
+	}
+
+	// get type
+	d.Type = Type(src[0] >> 4)
+
+	// create packet
+	pkt, err := d.Type.New()
+	if err != nil {
+		d.Error = makeError(d.Type, "invalid type %d", d.Type)
+		d.ErrorLength = 1
+		return d
+	}
+
+	// decode packet
+	n, err := pkt.Decode(version, src)
+	if err != nil {
+		d.Error = err
+		d.ErrorOffset = n
+	}
+
+	// use announced version
+	if connect, ok := pkt.(*Connect); ok && connect.Version != 0 {
+		d.Version = connect.Version
+	}
+
+	// read fields
+	ds := &dissector{src: src, t: d.Type, version: d.Version}
+	d.Fields = ds.dissect()
+
+	// get error region
+	if d.Error != nil {
+		d.ErrorLength = 1
+		if f := innermost(d.Fields, n-1); f != nil && f.Offset+f.Length == n {
+			d.ErrorOffset = f.Offset
+			d.ErrorLength = f.Length
+		}
+	}
+
+	return d
+}
+
+// String returns an indented tree of all fields.
+func (d *Dissection) String() string {
+	// prepare buffer
+	var buf bytes.Buffer
+
+	// write title
+	_, _ = fmt.Fprintf(&buf, "%s (%d bytes)\n", d.Type.String(), len(d.Data))
+
+	// write fields
+	var write func([]Field, int)
+	write = func(fields []Field, depth int) {
+		for _, f := range fields {
+			_, _ = fmt.Fprintf(&buf, "%s[%d:%d] %s", strings.Repeat("  ", depth+1), f.Offset, f.Offset+f.Length, f.Name)
+			if f.Value != "" {
+				_, _ = fmt.Fprintf(&buf, ": %s", f.Value)
+			}
+			buf.WriteByte('\n')
+			write(f.Fields, depth+1)
+		}
+	}
+	write(d.Fields, 0)
+
+	// write error
+	if d.Error != nil {
+		_, _ = fmt.Fprintf(&buf, "error at offset %d: %s\n", d.ErrorOffset, d.Error.Error())
+	}
+
+	return buf.String()
+}
+
+// Hexdump returns a hexdump of the data similar to "hexdump -C" that marks the
+// region that caused decoding to fail.
+func (d *Dissection) Hexdump() string {
+	// prepare buffer
+	var buf bytes.Buffer
+
+	// get marked region
+	start, end := -1, -1
+	if d.Error != nil {
+		start, end = d.ErrorOffset, d.ErrorOffset+d.ErrorLength
+	}
+
+	// write lines, including an empty line if the error is past the end
+	for line := 0; line < len(d.Data) || line <= start; line += 16 {
+		// get chunk
+		chunk := d.Data[line:]
+		if len(chunk) > 16 {
+			chunk = chunk[:16]
+		}
+
+		// prepare marker
+		var marker []byte
+
+		// write offset
+		_, _ = fmt.Fprintf(&buf, "%08x  ", line)
+
+		// write hex
+		for i := 0; i < 16; i++ {
+			// get column
+			col := hexColumn(i)
+
+			// write byte
+			if i < len(chunk) {
+				_, _ = fmt.Fprintf(&buf, "%02x ", chunk[i])
+			} else {
+				buf.WriteString("   ")
+			}
+
+			// add space
+			if i == 7 {
+				buf.WriteByte(' ')
+			}
+
+			// mark byte
+			if line+i >= start && line+i < end {
+				for len(marker) < col {
+					marker = append(marker, ' ')
+				}
+				marker = append(marker, '^', '^')
+			}
+		}
+
+		// write ascii
+		buf.WriteString(" |")
+		for _, b := range chunk {
+			if b < 32 || b > 126 {
+				b = '.'
+			}
+			buf.WriteByte(b)
+		}
+		buf.WriteString("|\n")
+
+		// write marker
+		if marker != nil {
+			buf.Write(marker)
+			buf.WriteString(" " + d.Error.Error() + "\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// returns the column of the byte in a hexdump line
+func hexColumn(i int) int {
+	col := 10 + i*3
+	if i > 7 {
+		col++
+	}
+
+	return col
+}
+
+// returns the innermost field that contains the offset
+func innermost(fields []Field, offset int) *Field {
+	for i := range fields {
+		f := &fields[i]
+		if offset >= f.Offset && offset < f.Offset+f.Length {
+			if inner := innermost(f.Fields, offset); inner != nil {
+				return inner
+			}
+
+			return f
+		}
+	}
+
+	return nil
+}
+
+type dissector struct {
+	src     []byte
+	t       Type
+	version byte
+	pos     int
+	end     int
+}
+
+func (d *dissector) dissect() []Field {
+	// prepare fields
+	var fields []Field
+
+	// read header
+	rl, flags, ok := d.header(&fields)
+	if !ok {
+		return fields
+	}
+
+	// set end
+	d.end = d.pos + rl
+	if d.end > len(d.src) {
+		d.end = len(d.src)
+	}
+
+	// read variable header and payload
+	switch d.t {
+	case CONNECT:
+		d.connect(&fields)
+	case CONNACK:
+		d.connack(&fields, rl)
+	case PUBLISH:
+		d.publish(&fields, flags)
+	case PUBACK, PUBREC, PUBREL, PUBCOMP:
+		d.identified(&fields, rl)
+	case SUBSCRIBE:
+		d.subscribe(&fields)
+	case SUBACK:
+		d.suback(&fields)
+	case UNSUBSCRIBE:
+		d.unsubscribe(&fields)
+	case UNSUBACK:
+		d.unsuback(&fields)
+	case DISCONNECT, AUTH:
+		d.reason(&fields, rl)
+	}
+
+	return fields
+}
+
+func (d *dissector) header(fields *[]Field) (int, byte, bool) {
+	// decode header
+	hl, _, _, err := headerDecode(d.src, d.t)
+	if hl == 0 {
+		return 0, 0, false
+	}
+
+	// prepare field
+	header := Field{Name: "fixed header", Offset: 0, Length: 1}
+
+	// add type and flags
+	flags := d.src[0] & 0x0f
+	header.Fields = append(header.Fields, Field{
+		Name:   "type and flags",
+		Length: 1,
+		Value:  fmt.Sprintf("%s flags=0x%x", d.t.String(), flags),
+	})
+	if d.t == PUBLISH {
+		header.Fields[0].Value += fmt.Sprintf(" (dup=%t qos=%d retain=%t)", flags&0x8 != 0, (flags>>1)&0x3, flags&0x1 != 0)
+	}
+
+	// read remaining length
+	rl, n, rerr := readVarint(d.src[1:], d.t)
+	if n > 0 {
+		header.Fields = append(header.Fields, Field{
+			Name:   "remaining length",
+			Offset: 1,
+			Length: n,
+			Value:  strconv.Itoa(rl),
+		})
+		header.Length += n
+	}
+
+	// add header
+	*fields = append(*fields, header)
+	d.pos = header.Length
+
+	// stop if the header could not be read
+	if rerr != nil || (err != nil && hl == 1) {
+		return 0, 0, false
+	}
+
+	return rl, flags, true
+}
+
+func (d *dissector) connect(fields *[]Field) {
+	// read protocol name
+	if _, ok := d.lpString(fields, "protocol name"); !ok {
+		return
+	}
+
+	// read version
+	if _, ok := d.byte(fields, "protocol version"); !ok {
+		return
+	}
+
+	// read flags
+	flags, ok := d.byte(fields, "connect flags")
+	if !ok {
+		return
+	}
+	(*fields)[len(*fields)-1].Value += fmt.Sprintf(" (username=%t password=%t will-retain=%t will-qos=%d will=%t clean-session=%t)",
+		flags&0x80 != 0, flags&0x40 != 0, flags&0x20 != 0, (flags>>3)&0x3, flags&0x4 != 0, flags&0x2 != 0)
+
+	// read keep alive
+	if _, ok := d.uint16(fields, "keep alive"); !ok {
+		return
+	}
+
+	// read properties
+	if d.version == Version5 && !d.properties(fields, "properties", false) {
+		return
+	}
+
+	// read client id
+	if _, ok := d.lpString(fields, "client id"); !ok {
+		return
+	}
+
+	// read will
+	if flags&0x4 != 0 {
+		if d.version == Version5 && !d.properties(fields, "will properties", true) {
+			return
+		}
+		if _, ok := d.lpString(fields, "will topic"); !ok {
+			return
+		}
+		if _, ok := d.lpBytes(fields, "will payload"); !ok {
+			return
+		}
+	}
+
+	// read username
+	if flags&0x80 != 0 {
+		if _, ok := d.lpString(fields, "username"); !ok {
+			return
+		}
+	}
+
+	// read password
+	if flags&0x40 != 0 {
+		d.lpString(fields, "password")
+	}
+}
+
+func (d *dissector) connack(fields *[]Field, rl int) {
+	// read flags
+	if _, ok := d.byte(fields, "acknowledge flags"); !ok {
+		return
+	}
+
+	// read return code
+	if d.version != Version5 {
+		code, ok := d.byte(fields, "return code")
+		if ok {
+			(*fields)[len(*fields)-1].Value += " (" + ConnackCode(code).String() + ")"
+		}
+		return
+	}
+
+	// read reason code
+	if !d.reasonCode(fields) {
+		return
+	}
+
+	// read properties
+	if rl > 2 {
+		d.properties(fields, "properties", false)
+	}
+}
+
+func (d *dissector) publish(fields *[]Field, flags byte) {
+	// read topic
+	if _, ok := d.lpString(fields, "topic name"); !ok {
+		return
+	}
+
+	// read packet id
+	if (flags>>1)&0x3 != 0 {
+		if _, ok := d.uint16(fields, "packet id"); !ok {
+			return
+		}
+	}
+
+	// read properties
+	if d.version == Version5 && !d.properties(fields, "properties", false) {
+		return
+	}
+
+	// read payload
+	d.rest(fields, "payload")
+}
+
+func (d *dissector) identified(fields *[]Field, rl int) {
+	// read packet id
+	if _, ok := d.uint16(fields, "packet id"); !ok {
+		return
+	}
+
+	// check version
+	if d.version != Version5 {
+		return
+	}
+
+	// read reason code
+	if rl > 2 && !d.reasonCode(fields) {
+		return
+	}
+
+	// read properties
+	if rl > 3 {
+		d.properties(fields, "properties", false)
+	}
+}
+
+func (d *dissector) subscribe(fields *[]Field) {
+	// read packet id and properties
+	if !d.idAndProperties(fields) {
+		return
+	}
+
+	// read subscriptions
+	for i := 0; d.pos < d.end; i++ {
+		// read topic
+		if _, ok := d.lpString(fields, fmt.Sprintf("topic filter %d", i)); !ok {
+			return
+		}
+
+		// read options
+		options, ok := d.byte(fields, fmt.Sprintf("options %d", i))
+		if !ok {
+			return
+		}
+		(*fields)[len(*fields)-1].Value += fmt.Sprintf(" (qos=%d no-local=%t retain-as-published=%t retain-handling=%d)",
+			options&0x3, options&0x4 != 0, options&0x8 != 0, (options>>4)&0x3)
+	}
+}
+
+func (d *dissector) suback(fields *[]Field) {
+	// read packet id and properties
+	if !d.idAndProperties(fields) {
+		return
+	}
+
+	// read return codes
+	for i := 0; d.pos < d.end; i++ {
+		d.byte(fields, fmt.Sprintf("return code %d", i))
+	}
+}
+
+func (d *dissector) unsubscribe(fields *[]Field) {
+	// read packet id and properties
+	if !d.idAndProperties(fields) {
+		return
+	}
+
+	// read topics
+	for i := 0; d.pos < d.end; i++ {
+		if _, ok := d.lpString(fields, fmt.Sprintf("topic filter %d", i)); !ok {
+			return
+		}
+	}
+}
+
+func (d *dissector) unsuback(fields *[]Field) {
+	// read packet id and properties
+	if !d.idAndProperties(fields) {
+		return
+	}
+
+	// read reason codes
+	for d.pos < d.end {
+		d.reasonCode(fields)
+	}
+}
+
+func (d *dissector) reason(fields *[]Field, rl int) {
+	// check version
+	if d.version != Version5 {
+		return
+	}
+
+	// read reason code
+	if rl > 0 && !d.reasonCode(fields) {
+		return
+	}
+
+	// read properties
+	if rl > 1 {
+		d.properties(fields, "properties", false)
+	}
+}
+
+func (d *dissector) idAndProperties(fields *[]Field) bool {
+	// read packet id
+	if _, ok := d.uint16(fields, "packet id"); !ok {
+		return false
+	}
+
+	// read properties
+	if d.version == Version5 {
+		return d.properties(fields, "properties", false)
+	}
+
+	return true
+}
+
+func (d *dissector) add(fields *[]Field, name string, n int, value string) {
+	*fields = append(*fields, Field{Name: name, Offset: d.pos, Length: n, Value: value})
+	d.pos += n
+}
+
+func (d *dissector) byte(fields *[]Field, name string) (byte, bool) {
+	// check buffer
+	if d.pos >= d.end {
+		return 0, false
+	}
+
+	// read byte
+	b := d.src[d.pos]
+	d.add(fields, name, 1, strconv.Itoa(int(b)))
+
+	return b, true
+}
+
+func (d *dissector) uint16(fields *[]Field, name string) (uint16, bool) {
+	// check buffer
+	if d.pos+2 > d.end {
+		return 0, false
+	}
+
+	// read value
+	value := binary.BigEndian.Uint16(d.src[d.pos:])
+	d.add(fields, name, 2, strconv.Itoa(int(value)))
+
+	return value, true
+}
+
+func (d *dissector) reasonCode(fields *[]Field) bool {
+	code, ok := d.byte(fields, "reason code")
+	if ok {
+		(*fields)[len(*fields)-1].Value += " (" + ReasonCode(code).String() + ")"
+	}
+
+	return ok
+}
+
+func (d *dissector) lpBytes(fields *[]Field, name string) ([]byte, bool) {
+	// read bytes
+	value, n, err := readLPBytes(d.src[d.pos:d.end], false, d.t)
+	if err != nil {
+		if n > 0 {
+			d.add(fields, name, n, "truncated")
+		}
+		return nil, false
+	}
+
+	// add field
+	d.add(fields, name, n, fmt.Sprintf("%q", value))
+
+	return value, true
+}
+
+func (d *dissector) lpString(fields *[]Field, name string) (string, bool) {
+	value, ok := d.lpBytes(fields, name)
+	return string(value), ok
+}
+
+func (d *dissector) rest(fields *[]Field, name string) {
+	// get remaining bytes
+	value := d.src[d.pos:d.end]
+	if len(value) == 0 {
+		return
+	}
+
+	// add field
+	d.add(fields, name, len(value), fmt.Sprintf("%q", value))
+}
+
+func (d *dissector) properties(fields *[]Field, name string, will bool) bool {
+	// prepare field
+	field := Field{Name: name, Offset: d.pos}
+
+	// read length
+	length, n, err := readVarint(d.src[d.pos:d.end], d.t)
+	if err != nil {
+		if n > 0 {
+			field.Length = n
+			*fields = append(*fields, field)
+			d.pos += n
+		}
+		return false
+	}
+
+	// add length
+	d.add(&field.Fields, "length", n, strconv.Itoa(length))
+
+	// get end
+	end := d.pos + length
+	if end > d.end {
+		end = d.end
+	}
+
+	// read properties
+	ok := true
+	for d.pos < end {
+		// read identifier
+		_id, n, err := readVarint(d.src[d.pos:end], d.t)
+		if err != nil {
+			ok = false
+			break
+		}
+
+		// check identifier
+		id := PropertyID(_id)
+		if _id > 0xff || !id.Valid() || !id.Allowed(d.t, will) {
+			d.add(&field.Fields, "property", n, fmt.Sprintf("invalid identifier %d", _id))
+			ok = false
+			break
+		}
+
+		// read value
+		var props Properties
+		m, err := props.decodeValue(d.src[d.pos+n:end], id, d.t)
+		if err != nil {
+			d.add(&field.Fields, id.String(), n+m, "truncated")
+			ok = false
+			break
+		}
+
+		// add property
+		value := strings.TrimSuffix(strings.TrimPrefix(props.String(), "<Properties "+id.String()+"="), ">")
+		d.add(&field.Fields, id.String(), n+m, value)
+	}
+
+	// add field
+	field.Length = d.pos - field.Offset
+	*fields = append(*fields, field)
+
+	return ok && d.pos == field.Offset+field.Fields[0].Length+length
+}
@@ -0,0 +1,159 @@
+package packet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDissect(t *testing.T) {
+	pkt := &Publish{
+		Message:    Message{Topic: "a/b", Payload: []byte("hello"), QOS: QOSAtLeastOnce},
+		ID:         7,
+		Properties: Properties{ContentType: "text"},
+	}
+
+	buf := make([]byte, pkt.Len(Version5))
+	_, err := pkt.Encode(Version5, buf)
+	assert.NoError(t, err)
+
+	d := Dissect(Version5, buf)
+	assert.NoError(t, d.Error)
+	assert.Equal(t, PUBLISH, d.Type)
+	assert.Equal(t, []Field{
+		{Name: "fixed header", Offset: 0, Length: 2, Fields: []Field{
+			{Name: "type and flags", Offset: 0, Length: 1, Value: "Publish flags=0x2 (dup=false qos=1 retain=false)"},
+			{Name: "remaining length", Offset: 1, Length: 1, Value: "20"},
+		}},
+		{Name: "topic name", Offset: 2, Length: 5, Value: `"a/b"`},
+		{Name: "packet id", Offset: 7, Length: 2, Value: "7"},
+		{Name: "properties", Offset: 9, Length: 8, Fields: []Field{
+			{Name: "length", Offset: 9, Length: 1, Value: "7"},
+			{Name: "ContentType", Offset: 10, Length: 7, Value: `"text"`},
+		}},
+		{Name: "payload", Offset: 17, Length: 5, Value: `"hello"`},
+	}, d.Fields)
+	assert.Equal(t, strings.Join([]string{
+		`Publish (22 bytes)`,
+		`  [0:2] fixed header`,
+		`    [0:1] type and flags: Publish flags=0x2 (dup=false qos=1 retain=false)`,
+		`    [1:2] remaining length: 20`,
+		`  [2:7] topic name: "a/b"`,
+		`  [7:9] packet id: 7`,
+		`  [9:17] properties`,
+		`    [9:10] length: 7`,
+		`    [10:17] ContentType: "text"`,
+		`  [17:22] payload: "hello"`,
+		``,
+	}, "\n"), d.String())
+	assert.Equal(t, strings.Join([]string{
+		`00000000  32 14 00 03 61 2f 62 00  07 07 03 00 04 74 65 78  |2...a/b......tex|`,
+		`00000010  74 68 65 6c 6c 6f                                 |thello|`,
+		``,
+	}, "\n"), d.Hexdump())
+}
+
+func TestDissectConnect(t *testing.T) {
+	pkt := &Connect{
+		ClientID:     "c",
+		KeepAlive:    30,
+		Username:     "u",
+		CleanSession: true,
+		Will:         &Message{Topic: "w", Payload: []byte("m")},
+		Version:      Version5,
+	}
+
+	buf := make([]byte, pkt.Len(Version5))
+	_, err := pkt.Encode(Version5, buf)
+	assert.NoError(t, err)
+
+	d := Dissect(0, buf)
+	assert.NoError(t, d.Error)
+	assert.Equal(t, Version5, d.Version)
+
+	var names []string
+	for _, f := range d.Fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{
+		"fixed header",
+		"protocol name",
+		"protocol version",
+		"connect flags",
+		"keep alive",
+		"properties",
+		"client id",
+		"will properties",
+		"will topic",
+		"will payload",
+		"username",
+	}, names)
+
+	last := d.Fields[len(d.Fields)-1]
+	assert.Equal(t, len(buf), last.Offset+last.Length)
+}
+
+func TestDissectError(t *testing.T) {
+	buf := []byte{
+		byte(PUBLISH<<4) | 2,
+		7,
+		0, 1, 'a',
+		0, 0, // packet id
+		'h', 'i',
+	}
+
+	d := Dissect(Version311, buf)
+	assert.Error(t, d.Error)
+	assert.Equal(t, 5, d.ErrorOffset)
+	assert.Equal(t, 2, d.ErrorLength)
+	assert.Equal(t, strings.Join([]string{
+		`Publish (9 bytes)`,
+		`  [0:2] fixed header`,
+		`    [0:1] type and flags: Publish flags=0x2 (dup=false qos=1 retain=false)`,
+		`    [1:2] remaining length: 7`,
+		`  [2:5] topic name: "a"`,
+		`  [5:7] packet id: 0`,
+		`  [7:9] payload: "hi"`,
+		`error at offset 5: packet id must be grater than zero`,
+		``,
+	}, "\n"), d.String())
+	assert.Equal(t, strings.Join([]string{
+		`00000000  32 07 00 01 61 00 00 68  69                       |2...a..hi|`,
+		`                         ^^ ^^ packet id must be grater than zero`,
+		``,
+	}, "\n"), d.Hexdump())
+}
+
+func TestDissectTruncated(t *testing.T) {
+	buf := []byte{byte(SUBSCRIBE<<4) | 2, 8, 0, 1, 0, 3, 'a'}
+
+	d := Dissect(Version311, buf)
+	assert.Error(t, d.Error)
+	assert.Equal(t, 1, d.ErrorOffset)
+	assert.Equal(t, 1, d.ErrorLength)
+	assert.Len(t, d.Fields, 3)
+	assert.Equal(t, Field{Name: "topic filter 0", Offset: 4, Length: 2, Value: "truncated"}, d.Fields[2])
+}
+
+func TestDissectInvalid(t *testing.T) {
+	d := Dissect(Version311, nil)
+	assert.Error(t, d.Error)
+	assert.Empty(t, d.Fields)
+
+	d = Dissect(Version311, []byte{0, 0})
+	assert.Error(t, d.Error)
+	assert.Equal(t, 0, d.ErrorOffset)
+	assert.Equal(t, 1, d.ErrorLength)
+	assert.Equal(t, strings.Join([]string{
+		`00000000  00 00                                             |..|`,
+		`          ^^ invalid type 0`,
+		``,
+	}, "\n"), d.Hexdump())
+
+	d = Dissect(Version311, []byte{byte(PINGREQ<<4) | 1, 0})
+	assert.Error(t, d.Error)
+	assert.Equal(t, 0, d.ErrorOffset)
+	assert.Equal(t, 1, d.ErrorLength)
+	assert.Len(t, d.Fields, 1)
+}
@@ -11,10 +11,10 @@ func Example() {
 	pkt1.Password = "amazing!"
 
 	// Allocate buffer.
-	buf := make([]byte, pkt1.Len())
+	buf := make([]byte, pkt1.Len(Version311))
 
 	// Encode the packet.
-	if _, err := pkt1.Encode(buf); err != nil {
+	if _, err := pkt1.Encode(Version311, buf); err != nil {
 		panic(err) // error while encoding
 	}
 
@@ -35,7 +35,7 @@ func Example() {
 	}
 
 	// Decode packet.
-	_, err = pkt2.Decode(buf)
+	_, err = pkt2.Decode(Version311, buf)
 	if err != nil {
 		panic(err) // there was an error while decoding
 	}
@@ -6,21 +6,45 @@ import (
 )
 
 // returns the byte length of an identified packet
-func identifiedLen() int {
-	return headerLen(2) + 2
+func identifiedLen(version byte, rc ReasonCode, props *Properties) int {
+	ml := identifiedPayloadLen(version, rc, props)
+	return headerLen(ml) + ml
+}
+
+// returns the payload length of an identified packet
+func identifiedPayloadLen(version byte, rc ReasonCode, props *Properties) int {
+	// add packet id
+	total := 2
+
+	// check version
+	if version != Version5 {
+		return total
+	}
+
+	// add reason code and properties if present
+	if props != nil && !props.Empty() {
+		total += 1 + propertiesLen(props)
+	} else if rc != ReasonSuccess {
+		total++
+	}
+
+	return total
 }
 
 // decodes an identified packet
-func identifiedDecode(src []byte, t Type) (int, ID, error) {
+func identifiedDecode(src []byte, t Type, version byte, rc *ReasonCode, props *Properties) (int, ID, error) {
 	// decode header
-	total, _, rl, err := headerDecode(src, t)
+	hl, _, rl, err := headerDecode(src, t)
+	total := hl
 	if err != nil {
 		return total, 0, err
 	}
 
 	// check remaining length
-	if rl != 2 {
+	if version != Version5 && rl != 2 {
 		return total, 0, makeError(t, "expected remaining length to be 2")
+	} else if version == Version5 && rl < 2 {
+		return total, 0, makeError(t, "expected remaining length to be at least 2")
 	}
 
 	// read packet id
@@ -32,18 +56,62 @@ func identifiedDecode(src []byte, t Type) (int, ID, error) {
 		return total, 0, makeError(t, "packet id must be grater than zero")
 	}
 
+	// check version
+	if version != Version5 {
+		return total, packetID, nil
+	}
+
+	// reset reason code and properties
+	if rc != nil {
+		*rc = ReasonSuccess
+	}
+	if props != nil {
+		*props = Properties{}
+	}
+
+	// read reason code if present
+	if rl > 2 {
+		// get reason code
+		code := ReasonCode(src[total])
+		total++
+
+		// check reason code
+		if !code.Allowed(t) {
+			return total, 0, makeError(t, "invalid reason code (%d)", code)
+		}
+
+		// set reason code
+		if rc != nil {
+			*rc = code
+		}
+	}
+
+	// read properties if present
+	if rl > 3 && props != nil {
+		n, err := props.decode(src[total:hl+rl], t, false)
+		total += n
+		if err != nil {
+			return total, 0, err
+		}
+	}
+
 	return total, packetID, nil
 }
 
 // encodes an identified packet
-func identifiedEncode(dst []byte, id ID, t Type) (int, error) {
+func identifiedEncode(dst []byte, id ID, t Type, version byte, rc ReasonCode, props *Properties) (int, error) {
 	// check packet id
 	if !id.Valid() {
 		return 0, makeError(t, "packet id must be grater than zero")
 	}
 
+	// check reason code
+	if version == Version5 && !rc.Allowed(t) {
+		return 0, makeError(t, "invalid reason code (%d)", rc)
+	}
+
 	// encode header
-	total, err := headerEncode(dst, 0, 2, identifiedLen(), t)
+	total, err := headerEncode(dst, 0, identifiedPayloadLen(version, rc, props), identifiedLen(version, rc, props), t)
 	if err != nil {
 		return total, err
 	}
@@ -52,13 +120,59 @@ func identifiedEncode(dst []byte, id ID, t Type) (int, error) {
 	binary.BigEndian.PutUint16(dst[total:], uint16(id))
 	total += 2
 
+	// check version
+	if version != Version5 {
+		return total, nil
+	}
+
+	// write reason code and properties if present
+	if props != nil && !props.Empty() {
+		// write reason code
+		dst[total] = byte(rc)
+		total++
+
+		// write properties
+		n, err := props.encode(dst[total:], t, false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	} else if rc != ReasonSuccess {
+		// write reason code
+		dst[total] = byte(rc)
+		total++
+	}
+
 	return total, nil
 }
 
+// returns a string representation of an identified packet
+func identifiedString(t Type, id ID, rc ReasonCode, props *Properties) string {
+	// prepare reason code
+	reasonCode := ""
+	if rc != ReasonSuccess {
+		reasonCode = fmt.Sprintf(" ReasonCode=%d", rc)
+	}
+
+	// prepare properties
+	properties := ""
+	if props != nil && !props.Empty() {
+		properties = " Properties=" + props.String()
+	}
+
+	return fmt.Sprintf("<%s ID=%d%s%s>", t.String(), id, reasonCode, properties)
+}
+
 // A Puback packet is the response to a Publish packet with QOS level 1.
 type Puback struct {
 	// The packet identifier.
 	ID ID
+
+	// The reason code of the packet (MQTT 5 only).
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewPuback creates a new Puback packet.
@@ -72,14 +186,14 @@ func (pp *Puback) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Puback) Len() int {
-	return identifiedLen()
+func (pp *Puback) Len(version byte) int {
+	return identifiedLen(version, pp.ReasonCode, &pp.Properties)
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Puback) Decode(src []byte) (int, error) {
-	n, pid, err := identifiedDecode(src, PUBACK)
+func (pp *Puback) Decode(version byte, src []byte) (int, error) {
+	n, pid, err := identifiedDecode(src, PUBACK, version, &pp.ReasonCode, &pp.Properties)
 	pp.ID = pid
 	return n, err
 }
@@ -87,13 +201,13 @@ func (pp *Puback) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Puback) Encode(dst []byte) (int, error) {
-	return identifiedEncode(dst, pp.ID, PUBACK)
+func (pp *Puback) Encode(version byte, dst []byte) (int, error) {
+	return identifiedEncode(dst, pp.ID, PUBACK, version, pp.ReasonCode, &pp.Properties)
 }
 
 // String returns a string representation of the packet.
 func (pp *Puback) String() string {
-	return fmt.Sprintf("<Puback ID=%d>", pp.ID)
+	return identifiedString(pp.Type(), pp.ID, pp.ReasonCode, &pp.Properties)
 }
 
 // A Pubcomp packet is the response to a Pubrel. It is the fourth and
@@ -101,6 +215,12 @@ func (pp *Puback) String() string {
 type Pubcomp struct {
 	// The packet identifier.
 	ID ID
+
+	// The reason code of the packet (MQTT 5 only).
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 var _ Generic = (*Pubcomp)(nil)
@@ -116,14 +236,14 @@ func (pp *Pubcomp) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Pubcomp) Len() int {
-	return identifiedLen()
+func (pp *Pubcomp) Len(version byte) int {
+	return identifiedLen(version, pp.ReasonCode, &pp.Properties)
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Pubcomp) Decode(src []byte) (int, error) {
-	n, pid, err := identifiedDecode(src, PUBCOMP)
+func (pp *Pubcomp) Decode(version byte, src []byte) (int, error) {
+	n, pid, err := identifiedDecode(src, PUBCOMP, version, &pp.ReasonCode, &pp.Properties)
 	pp.ID = pid
 	return n, err
 }
@@ -131,13 +251,13 @@ func (pp *Pubcomp) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Pubcomp) Encode(dst []byte) (int, error) {
-	return identifiedEncode(dst, pp.ID, PUBCOMP)
+func (pp *Pubcomp) Encode(version byte, dst []byte) (int, error) {
+	return identifiedEncode(dst, pp.ID, PUBCOMP, version, pp.ReasonCode, &pp.Properties)
 }
 
 // String returns a string representation of the packet.
 func (pp *Pubcomp) String() string {
-	return fmt.Sprintf("<Pubcomp ID=%d>", pp.ID)
+	return identifiedString(pp.Type(), pp.ID, pp.ReasonCode, &pp.Properties)
 }
 
 // A Pubrec packet is the response to a Publish packet with QOS 2. It is the
@@ -145,6 +265,12 @@ func (pp *Pubcomp) String() string {
 type Pubrec struct {
 	// Shared packet identifier.
 	ID ID
+
+	// The reason code of the packet (MQTT 5 only).
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewPubrec creates a new Pubrec packet.
@@ -158,14 +284,14 @@ func (pp *Pubrec) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Pubrec) Len() int {
-	return identifiedLen()
+func (pp *Pubrec) Len(version byte) int {
+	return identifiedLen(version, pp.ReasonCode, &pp.Properties)
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Pubrec) Decode(src []byte) (int, error) {
-	n, pid, err := identifiedDecode(src, PUBREC)
+func (pp *Pubrec) Decode(version byte, src []byte) (int, error) {
+	n, pid, err := identifiedDecode(src, PUBREC, version, &pp.ReasonCode, &pp.Properties)
 	pp.ID = pid
 	return n, err
 }
@@ -173,13 +299,13 @@ func (pp *Pubrec) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Pubrec) Encode(dst []byte) (int, error) {
-	return identifiedEncode(dst, pp.ID, PUBREC)
+func (pp *Pubrec) Encode(version byte, dst []byte) (int, error) {
+	return identifiedEncode(dst, pp.ID, PUBREC, version, pp.ReasonCode, &pp.Properties)
 }
 
 // String returns a string representation of the packet.
 func (pp *Pubrec) String() string {
-	return fmt.Sprintf("<Pubrec ID=%d>", pp.ID)
+	return identifiedString(pp.Type(), pp.ID, pp.ReasonCode, &pp.Properties)
 }
 
 // A Pubrel packet is the response to a Pubrec packet. It is the third packet of
@@ -187,6 +313,12 @@ func (pp *Pubrec) String() string {
 type Pubrel struct {
 	// Shared packet identifier.
 	ID ID
+
+	// The reason code of the packet (MQTT 5 only).
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 var _ Generic = (*Pubrel)(nil)
@@ -202,14 +334,14 @@ func (pp *Pubrel) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Pubrel) Len() int {
-	return identifiedLen()
+func (pp *Pubrel) Len(version byte) int {
+	return identifiedLen(version, pp.ReasonCode, &pp.Properties)
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Pubrel) Decode(src []byte) (int, error) {
-	n, pid, err := identifiedDecode(src, PUBREL)
+func (pp *Pubrel) Decode(version byte, src []byte) (int, error) {
+	n, pid, err := identifiedDecode(src, PUBREL, version, &pp.ReasonCode, &pp.Properties)
 	pp.ID = pid
 	return n, err
 }
@@ -217,53 +349,11 @@ func (pp *Pubrel) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Pubrel) Encode(dst []byte) (int, error) {
-	return identifiedEncode(dst, pp.ID, PUBREL)
+func (pp *Pubrel) Encode(version byte, dst []byte) (int, error) {
+	return identifiedEncode(dst, pp.ID, PUBREL, version, pp.ReasonCode, &pp.Properties)
 }
 
 // String returns a string representation of the packet.
 func (pp *Pubrel) String() string {
-	return fmt.Sprintf("<Pubrel ID=%d>", pp.ID)
-}
-
-// An Unsuback packet is sent by the server to the client to confirm receipt of
-// an Unsubscribe packet.
-type Unsuback struct {
-	// Shared packet identifier.
-	ID ID
-}
-
-// NewUnsuback creates a new Unsuback packet.
-func NewUnsuback() *Unsuback {
-	return &Unsuback{}
-}
-
-// Type returns the packets type.
-func (up *Unsuback) Type() Type {
-	return UNSUBACK
-}
-
-// Len returns the byte length of the encoded packet.
-func (up *Unsuback) Len() int {
-	return identifiedLen()
-}
-
-// Decode reads from the byte slice argument. It returns the total number of
-// bytes decoded, and whether there have been any errors during the process.
-func (up *Unsuback) Decode(src []byte) (int, error) {
-	n, pid, err := identifiedDecode(src, UNSUBACK)
-	up.ID = pid
-	return n, err
-}
-
-// Encode writes the packet bytes into the byte slice from the argument. It
-// returns the number of bytes encoded and whether there's any errors along
-// the way. If there is an error, the byte slice should be considered invalid.
-func (up *Unsuback) Encode(dst []byte) (int, error) {
-	return identifiedEncode(dst, up.ID, UNSUBACK)
-}
-
-// String returns a string representation of the packet.
-func (up *Unsuback) String() string {
-	return fmt.Sprintf("<Unsuback ID=%d>", up.ID)
+	return identifiedString(pp.Type(), pp.ID, pp.ReasonCode, &pp.Properties)
 }
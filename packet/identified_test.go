@@ -15,7 +15,7 @@ func TestIdentifiedDecode(t *testing.T) {
 		7, // packet ID LSB
 	}
 
-	n, pid, err := identifiedDecode(pktBytes, PUBACK)
+	n, pid, err := identifiedDecode(pktBytes, PUBACK, Version311, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 	assert.Equal(t, ID(7), pid)
@@ -29,7 +29,7 @@ func TestIdentifiedDecodeError1(t *testing.T) {
 		7, // packet ID LSB
 	}
 
-	n, pid, err := identifiedDecode(pktBytes, PUBACK)
+	n, pid, err := identifiedDecode(pktBytes, PUBACK, Version311, nil, nil)
 	assert.Error(t, err)
 	assert.Equal(t, 2, n)
 	assert.Equal(t, ID(0), pid)
@@ -43,7 +43,7 @@ func TestIdentifiedDecodeError2(t *testing.T) {
 		// < insufficient bytes
 	}
 
-	n, pid, err := identifiedDecode(pktBytes, PUBACK)
+	n, pid, err := identifiedDecode(pktBytes, PUBACK, Version311, nil, nil)
 	assert.Error(t, err)
 	assert.Equal(t, 2, n)
 	assert.Equal(t, ID(0), pid)
@@ -57,7 +57,7 @@ func TestIdentifiedDecodeError3(t *testing.T) {
 		0, // packet ID MSB < zero id
 	}
 
-	n, pid, err := identifiedDecode(pktBytes, PUBACK)
+	n, pid, err := identifiedDecode(pktBytes, PUBACK, Version311, nil, nil)
 	assert.Error(t, err)
 	assert.Equal(t, 4, n)
 	assert.Equal(t, ID(0), pid)
@@ -71,8 +71,8 @@ func TestIdentifiedEncode(t *testing.T) {
 		7, // packet ID LSB
 	}
 
-	dst := make([]byte, identifiedLen())
-	n, err := identifiedEncode(dst, 7, PUBACK)
+	dst := make([]byte, identifiedLen(Version311, ReasonSuccess, nil))
+	n, err := identifiedEncode(dst, 7, PUBACK, Version311, ReasonSuccess, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
@@ -81,15 +81,15 @@ func TestIdentifiedEncode(t *testing.T) {
 
 func TestIdentifiedEncodeError1(t *testing.T) {
 	dst := make([]byte, 3) // < insufficient buffer
-	n, err := identifiedEncode(dst, 7, PUBACK)
+	n, err := identifiedEncode(dst, 7, PUBACK, Version311, ReasonSuccess, nil)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
 }
 
 func TestIdentifiedEncodeError2(t *testing.T) {
-	dst := make([]byte, identifiedLen())
-	n, err := identifiedEncode(dst, 0, PUBACK) // < zero id
+	dst := make([]byte, identifiedLen(Version311, ReasonSuccess, nil))
+	n, err := identifiedEncode(dst, 0, PUBACK, Version311, ReasonSuccess, nil) // < zero id
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -104,19 +104,19 @@ func TestIdentifiedEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := &Puback{}
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 
 	dst := make([]byte, 100)
-	n2, err := identifiedEncode(dst, 7, PUBACK)
+	n2, err := identifiedEncode(dst, 7, PUBACK, Version311, ReasonSuccess, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, pid, err := identifiedDecode(pktBytes, PUBACK)
+	n3, pid, err := identifiedDecode(pktBytes, PUBACK, Version311, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n3)
 	assert.Equal(t, ID(7), pid)
@@ -126,10 +126,10 @@ func BenchmarkIdentifiedEncode(b *testing.B) {
 	pkt := &Puback{}
 	pkt.ID = 1
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -147,7 +147,7 @@ func BenchmarkIdentifiedDecode(b *testing.B) {
 	pkt := &Puback{}
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
@@ -157,12 +157,12 @@ func BenchmarkIdentifiedDecode(b *testing.B) {
 func testIdentifiedImplementation(t *testing.T, pkt Generic) {
 	assert.Equal(t, fmt.Sprintf("<%s ID=1>", pkt.Type().String()), pkt.String())
 
-	buf := make([]byte, pkt.Len())
-	n, err := pkt.Encode(buf)
+	buf := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, buf)
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 
-	n, err = pkt.Decode(buf)
+	n, err = pkt.Decode(Version311, buf)
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 }
@@ -201,3 +201,74 @@ func TestUnsubackImplementation(t *testing.T) {
 
 	testIdentifiedImplementation(t, pkt)
 }
+
+func TestIdentifiedEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(PUBACK << 4),
+		9,
+		0, // packet ID MSB
+		7, // packet ID LSB
+		0, // reason code
+		5, // properties length
+		byte(PropertyReasonString),
+		0, // reason string MSB
+		2, // reason string LSB
+		'o', 'k',
+	}
+
+	pkt := NewPuback()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, ID(7), pkt.ID)
+	assert.Equal(t, "ok", pkt.Properties.ReasonString)
+	assert.Equal(t, "<Puback ID=7 Properties=<Properties ReasonString=\"ok\">>", pkt.String())
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	pkt.Properties = Properties{}
+
+	n3, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n3)
+	assert.Equal(t, []byte{byte(PUBACK << 4), 2, 0, 7}, dst[:n3])
+}
+
+func TestIdentifiedReasonCodeVersion5(t *testing.T) {
+	pkt := NewPubrec()
+	pkt.ID = 7
+	pkt.ReasonCode = ReasonNoMatchingSubscribers
+
+	assert.Equal(t, "<Pubrec ID=7 ReasonCode=16>", pkt.String())
+
+	dst := make([]byte, pkt.Len(Version5))
+	n, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{byte(PUBREC << 4), 3, 0, 7, 0x10}, dst[:n])
+
+	pkt2 := NewPubrec()
+	n2, err := pkt2.Decode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, n, n2)
+	assert.Equal(t, pkt, pkt2)
+
+	pkt3 := NewPubrel()
+	_, err = pkt3.Decode(Version5, []byte{byte(PUBREL<<4) | 2, 3, 0, 7, 0x10}) // < invalid reason code
+
+	assert.Error(t, err)
+
+	pkt3.ID = 7
+	pkt3.ReasonCode = ReasonNoMatchingSubscribers // < invalid reason code
+
+	_, err = pkt3.Encode(Version5, dst)
+	assert.Error(t, err)
+}
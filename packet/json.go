@@ -0,0 +1,569 @@
+package packet
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// the json representation of a packet, the type discriminates the packet and
+// only the fields of that packet are set
+type jsonPacket struct {
+	Type           string             `json:"type"`
+	ID             ID                 `json:"id,omitempty"`
+	Version        byte               `json:"version,omitempty"`
+	ClientID       string             `json:"client_id,omitempty"`
+	KeepAlive      uint16             `json:"keep_alive,omitempty"`
+	Username       string             `json:"username,omitempty"`
+	Password       string             `json:"password,omitempty"`
+	CleanSession   bool               `json:"clean_session,omitempty"`
+	Will           *Message           `json:"will,omitempty"`
+	SessionPresent bool               `json:"session_present,omitempty"`
+	ReturnCode     ConnackCode        `json:"return_code,omitempty"`
+	ReasonCode     ReasonCode         `json:"reason_code,omitempty"`
+	Message        *Message           `json:"message,omitempty"`
+	Dup            bool               `json:"dup,omitempty"`
+	Subscriptions  []jsonSubscription `json:"subscriptions,omitempty"`
+	ReturnCodes    []QOS              `json:"return_codes,omitempty"`
+	Topics         []string           `json:"topics,omitempty"`
+	ReasonCodes    []ReasonCode       `json:"reason_codes,omitempty"`
+	Properties     *Properties        `json:"properties,omitempty"`
+	WillProperties *Properties        `json:"will_properties,omitempty"`
+}
+
+// the json representation of a subscription
+type jsonSubscription struct {
+	Topic             string `json:"topic"`
+	QOS               QOS    `json:"qos,omitempty"`
+	NoLocal           bool   `json:"no_local,omitempty"`
+	RetainAsPublished bool   `json:"retain_as_published,omitempty"`
+	RetainHandling    byte   `json:"retain_handling,omitempty"`
+}
+
+// the json representation of a message
+type jsonMessage struct {
+	Topic         string  `json:"topic"`
+	Payload       *string `json:"payload,omitempty"`
+	PayloadBase64 []byte  `json:"payload_base64,omitempty"`
+	QOS           QOS     `json:"qos,omitempty"`
+	Retain        bool    `json:"retain,omitempty"`
+}
+
+// UnmarshalJSON decodes a packet of any type from its JSON representation.
+// The packet type is inferred from the "type" field.
+func UnmarshalJSON(data []byte) (Generic, error) {
+	// decode type
+	var head struct {
+		Type string `json:"type"`
+	}
+	err := json.Unmarshal(data, &head)
+	if err != nil {
+		return nil, err
+	}
+
+	// lookup type
+	t, ok := parseType(head.Type)
+	if !ok {
+		return nil, makeError(0, "invalid packet type %q", head.Type)
+	}
+
+	// allocate packet
+	pkt, err := t.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// decode packet
+	err = pkt.(json.Unmarshaler).UnmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. The payload is
+// encoded as a string if it is valid UTF-8 and as base64 otherwise. An error
+// is returned if the topic is not valid UTF-8.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	// check topic
+	if !utf8.ValidString(m.Topic) {
+		return nil, makeError(0, "invalid topic %q", m.Topic)
+	}
+
+	// prepare message
+	jm := jsonMessage{
+		Topic:  m.Topic,
+		QOS:    m.QOS,
+		Retain: m.Retain,
+	}
+
+	// set payload
+	if utf8.Valid(m.Payload) {
+		if m.Payload != nil {
+			payload := string(m.Payload)
+			jm.Payload = &payload
+		}
+	} else {
+		jm.PayloadBase64 = m.Payload
+	}
+
+	return json.Marshal(jm)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	// decode message
+	var jm jsonMessage
+	err := json.Unmarshal(data, &jm)
+	if err != nil {
+		return err
+	}
+
+	// set message
+	*m = Message{
+		Topic:  jm.Topic,
+		QOS:    jm.QOS,
+		Retain: jm.Retain,
+	}
+
+	// set payload
+	if jm.PayloadBase64 != nil {
+		m.Payload = jm.PayloadBase64
+	} else if jm.Payload != nil {
+		m.Payload = []byte(*jm.Payload)
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (cp *Connect) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(cp, jsonPacket{
+		Version:        cp.Version,
+		ClientID:       cp.ClientID,
+		KeepAlive:      cp.KeepAlive,
+		Username:       cp.Username,
+		Password:       cp.Password,
+		CleanSession:   cp.CleanSession,
+		Will:           cp.Will,
+		Properties:     jsonProperties(&cp.Properties),
+		WillProperties: jsonProperties(&cp.WillProperties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (cp *Connect) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, CONNECT)
+	if err != nil {
+		return err
+	}
+
+	*cp = Connect{
+		ClientID:       jp.ClientID,
+		KeepAlive:      jp.KeepAlive,
+		Username:       jp.Username,
+		Password:       jp.Password,
+		CleanSession:   jp.CleanSession,
+		Will:           jp.Will,
+		Version:        jp.Version,
+		Properties:     packetProperties(jp.Properties),
+		WillProperties: packetProperties(jp.WillProperties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (cp *Connack) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(cp, jsonPacket{
+		SessionPresent: cp.SessionPresent,
+		ReturnCode:     cp.ReturnCode,
+		ReasonCode:     cp.ReasonCode,
+		Properties:     jsonProperties(&cp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (cp *Connack) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, CONNACK)
+	if err != nil {
+		return err
+	}
+
+	*cp = Connack{
+		SessionPresent: jp.SessionPresent,
+		ReturnCode:     jp.ReturnCode,
+		ReasonCode:     jp.ReasonCode,
+		Properties:     packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Publish) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{
+		ID:         pp.ID,
+		Message:    &pp.Message,
+		Dup:        pp.Dup,
+		Properties: jsonProperties(&pp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Publish) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, PUBLISH)
+	if err != nil {
+		return err
+	}
+
+	// release previous buffer
+	if pp.buffer != nil {
+		pp.buffer.Release()
+	}
+
+	*pp = Publish{
+		Dup:        jp.Dup,
+		ID:         jp.ID,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	// set message
+	if jp.Message != nil {
+		pp.Message = *jp.Message
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Puback) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{
+		ID:         pp.ID,
+		ReasonCode: pp.ReasonCode,
+		Properties: jsonProperties(&pp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Puback) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, PUBACK)
+	if err != nil {
+		return err
+	}
+
+	*pp = Puback{
+		ID:         jp.ID,
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Pubrec) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{
+		ID:         pp.ID,
+		ReasonCode: pp.ReasonCode,
+		Properties: jsonProperties(&pp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Pubrec) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, PUBREC)
+	if err != nil {
+		return err
+	}
+
+	*pp = Pubrec{
+		ID:         jp.ID,
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Pubrel) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{
+		ID:         pp.ID,
+		ReasonCode: pp.ReasonCode,
+		Properties: jsonProperties(&pp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Pubrel) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, PUBREL)
+	if err != nil {
+		return err
+	}
+
+	*pp = Pubrel{
+		ID:         jp.ID,
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Pubcomp) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{
+		ID:         pp.ID,
+		ReasonCode: pp.ReasonCode,
+		Properties: jsonProperties(&pp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Pubcomp) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, PUBCOMP)
+	if err != nil {
+		return err
+	}
+
+	*pp = Pubcomp{
+		ID:         jp.ID,
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (sp *Subscribe) MarshalJSON() ([]byte, error) {
+	// prepare subscriptions
+	subs := make([]jsonSubscription, 0, len(sp.Subscriptions))
+	for _, sub := range sp.Subscriptions {
+		subs = append(subs, jsonSubscription(sub))
+	}
+
+	return marshalJSONPacket(sp, jsonPacket{
+		ID:            sp.ID,
+		Subscriptions: subs,
+		Properties:    jsonProperties(&sp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (sp *Subscribe) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, SUBSCRIBE)
+	if err != nil {
+		return err
+	}
+
+	*sp = Subscribe{
+		ID:         jp.ID,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	// set subscriptions
+	for _, sub := range jp.Subscriptions {
+		sp.Subscriptions = append(sp.Subscriptions, Subscription(sub))
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (sp *Suback) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(sp, jsonPacket{
+		ID:          sp.ID,
+		ReturnCodes: sp.ReturnCodes,
+		Properties:  jsonProperties(&sp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (sp *Suback) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, SUBACK)
+	if err != nil {
+		return err
+	}
+
+	*sp = Suback{
+		ID:          jp.ID,
+		ReturnCodes: jp.ReturnCodes,
+		Properties:  packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (up *Unsubscribe) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(up, jsonPacket{
+		ID:         up.ID,
+		Topics:     up.Topics,
+		Properties: jsonProperties(&up.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (up *Unsubscribe) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, UNSUBSCRIBE)
+	if err != nil {
+		return err
+	}
+
+	*up = Unsubscribe{
+		ID:         jp.ID,
+		Topics:     jp.Topics,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (up *Unsuback) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(up, jsonPacket{
+		ID:          up.ID,
+		ReasonCodes: up.ReasonCodes,
+		Properties:  jsonProperties(&up.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (up *Unsuback) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, UNSUBACK)
+	if err != nil {
+		return err
+	}
+
+	*up = Unsuback{
+		ID:          jp.ID,
+		ReasonCodes: jp.ReasonCodes,
+		Properties:  packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Pingreq) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Pingreq) UnmarshalJSON(data []byte) error {
+	_, err := unmarshalJSONPacket(data, PINGREQ)
+	return err
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (pp *Pingresp) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(pp, jsonPacket{})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pp *Pingresp) UnmarshalJSON(data []byte) error {
+	_, err := unmarshalJSONPacket(data, PINGRESP)
+	return err
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (dp *Disconnect) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(dp, jsonPacket{
+		ReasonCode: dp.ReasonCode,
+		Properties: jsonProperties(&dp.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (dp *Disconnect) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, DISCONNECT)
+	if err != nil {
+		return err
+	}
+
+	*dp = Disconnect{
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (ap *Auth) MarshalJSON() ([]byte, error) {
+	return marshalJSONPacket(ap, jsonPacket{
+		ReasonCode: ap.ReasonCode,
+		Properties: jsonProperties(&ap.Properties),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (ap *Auth) UnmarshalJSON(data []byte) error {
+	jp, err := unmarshalJSONPacket(data, AUTH)
+	if err != nil {
+		return err
+	}
+
+	*ap = Auth{
+		ReasonCode: jp.ReasonCode,
+		Properties: packetProperties(jp.Properties),
+	}
+
+	return nil
+}
+
+// sets the type discriminator and encodes the packet
+func marshalJSONPacket(pkt Generic, jp jsonPacket) ([]byte, error) {
+	jp.Type = pkt.Type().String()
+	return json.Marshal(jp)
+}
+
+// decodes the packet and checks the type discriminator
+func unmarshalJSONPacket(data []byte, t Type) (*jsonPacket, error) {
+	// decode packet
+	var jp jsonPacket
+	err := json.Unmarshal(data, &jp)
+	if err != nil {
+		return nil, err
+	}
+
+	// check type
+	pt, ok := parseType(jp.Type)
+	if !ok || pt != t {
+		return nil, makeError(t, "invalid packet type %q", jp.Type)
+	}
+
+	return &jp, nil
+}
+
+// returns the type with the specified name
+func parseType(name string) (Type, bool) {
+	for t := CONNECT; t <= AUTH; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
+// returns the properties if not empty
+func jsonProperties(p *Properties) *Properties {
+	if p.Empty() {
+		return nil
+	}
+
+	return p
+}
+
+// returns the properties or empty properties
+func packetProperties(p *Properties) Properties {
+	if p == nil {
+		return Properties{}
+	}
+
+	return *p
+}
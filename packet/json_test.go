@@ -0,0 +1,119 @@
+package packet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	table := []Generic{
+		&Connect{
+			ClientID:     "c",
+			KeepAlive:    30,
+			Username:     "u",
+			Password:     "p",
+			CleanSession: true,
+			Will:         &Message{Topic: "w", Payload: []byte("m"), QOS: QOSAtLeastOnce},
+			Version:      Version5,
+			Properties: Properties{
+				SessionExpiryInterval: uint32Ptr(10),
+				UserProperties:        []UserProperty{{Key: "k", Value: ""}},
+			},
+			WillProperties: Properties{ContentType: "text/plain"},
+		},
+		&Connack{SessionPresent: true, ReturnCode: NotAuthorized, ReasonCode: ReasonNotAuthorized},
+		&Publish{
+			Message:    Message{Topic: "t", Payload: []byte{0xff, 0x00}, QOS: QOSExactlyOnce, Retain: true},
+			Dup:        true,
+			ID:         7,
+			Properties: Properties{CorrelationData: []byte("cd")},
+		},
+		&Puback{ID: 1, ReasonCode: ReasonNoMatchingSubscribers},
+		&Pubrec{ID: 2},
+		&Pubrel{ID: 3},
+		&Pubcomp{ID: 4, Properties: Properties{ReasonString: "ok"}},
+		&Subscribe{ID: 5, Subscriptions: []Subscription{{Topic: "a/#", QOS: 1, NoLocal: true, RetainHandling: 2}}},
+		&Suback{ID: 5, ReturnCodes: []QOS{QOSAtLeastOnce, QOSFailure}},
+		&Unsubscribe{ID: 6, Topics: []string{"a/#", "b"}},
+		&Unsuback{ID: 6, ReasonCodes: []ReasonCode{ReasonSuccess, ReasonNoSubscriptionExisted}},
+		&Pingreq{},
+		&Pingresp{},
+		&Disconnect{ReasonCode: ReasonDisconnectWithWillMessage},
+		&Auth{ReasonCode: ReasonContinueAuthentication, Properties: Properties{AuthenticationMethod: "m"}},
+	}
+
+	for _, pkt := range table {
+		data, err := json.Marshal(pkt)
+		assert.NoError(t, err, pkt.String())
+
+		pkt2, err := UnmarshalJSON(data)
+		assert.NoError(t, err, pkt.String())
+		assert.Equal(t, pkt, pkt2, string(data))
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, err := json.Marshal(&Publish{
+		Message: Message{Topic: "t", Payload: []byte("hello"), QOS: QOSAtLeastOnce},
+		ID:      1,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"Publish","id":1,"message":{"topic":"t","payload":"hello","qos":1}}`, string(data))
+
+	data, err = json.Marshal(&Message{Topic: "t", Payload: []byte{0xff}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"topic":"t","payload_base64":"/w=="}`, string(data))
+}
+
+func TestJSONFixture(t *testing.T) {
+	pkt, err := UnmarshalJSON([]byte(`{"type":"subscribe","id":1,"subscriptions":[{"topic":"a/+","qos":2}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "a/+", QOS: 2}}}, pkt)
+
+	var msg Message
+	err = json.Unmarshal([]byte(`{"topic":"t","payload_base64":"aGk="}`), &msg)
+	assert.NoError(t, err)
+	assert.Equal(t, Message{Topic: "t", Payload: []byte("hi")}, msg)
+}
+
+func TestJSONError(t *testing.T) {
+	_, err := UnmarshalJSON([]byte(`{"type":"foo"}`))
+	assert.Error(t, err)
+
+	_, err = UnmarshalJSON([]byte(`{`))
+	assert.Error(t, err)
+
+	err = json.Unmarshal([]byte(`{"type":"Puback"}`), NewPubrec())
+	assert.Error(t, err)
+}
+
+func TestJSONMessagePayload(t *testing.T) {
+	data, err := json.Marshal(&Message{Topic: "t", Payload: []byte{}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"topic":"t","payload":""}`, string(data))
+
+	var msg Message
+	err = json.Unmarshal(data, &msg)
+	assert.NoError(t, err)
+	assert.NotNil(t, msg.Payload)
+	assert.Empty(t, msg.Payload)
+
+	data, err = json.Marshal(&Message{Topic: "t"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"topic":"t"}`, string(data))
+
+	msg = Message{}
+	err = json.Unmarshal(data, &msg)
+	assert.NoError(t, err)
+	assert.Nil(t, msg.Payload)
+}
+
+func TestJSONMessageInvalidTopic(t *testing.T) {
+	_, err := json.Marshal(&Message{Topic: "t\xff"})
+	assert.Error(t, err)
+
+	_, err = json.Marshal(&Publish{Message: Message{Topic: "t\xff"}})
+	assert.Error(t, err)
+}
@@ -25,7 +25,12 @@ func (m *Message) String() string {
 		m.Topic, m.QOS, m.Retain, m.Payload)
 }
 
-// Copy returns a copy of the message.
+// Copy returns a copy of the message including its payload.
 func (m Message) Copy() *Message {
+	// copy payload
+	if m.Payload != nil {
+		m.Payload = append([]byte(nil), m.Payload...)
+	}
+
 	return &m
 }
@@ -29,3 +29,14 @@ func TestMessageCopy(t *testing.T) {
 	msg1.Retain = true
 	assert.False(t, msg2.Retain)
 }
+
+func TestMessageCopyPayload(t *testing.T) {
+	msg1 := &Message{
+		Topic:   "w",
+		Payload: []byte("m"),
+	}
+
+	msg2 := msg1.Copy()
+	msg1.Payload[0] = 'n'
+	assert.Equal(t, []byte("m"), msg2.Payload)
+}
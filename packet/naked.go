@@ -1,5 +1,7 @@
 package packet
 
+import "fmt"
+
 // returns the byte length of a naked packet
 func nakedLen() int {
 	return headerLen(0)
@@ -26,7 +28,13 @@ func nakedEncode(dst []byte, t Type) (int, error) {
 
 // A Disconnect packet is sent from the client to the server.
 // It indicates that the client is disconnecting cleanly.
-type Disconnect struct{}
+type Disconnect struct {
+	// The reason code of the packet (MQTT 5 only).
+	ReasonCode ReasonCode
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
+}
 
 // NewDisconnect creates a new Disconnect packet.
 func NewDisconnect() *Disconnect {
@@ -39,26 +47,126 @@ func (dp *Disconnect) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (dp *Disconnect) Len() int {
-	return nakedLen()
+func (dp *Disconnect) Len(version byte) int {
+	ml := dp.len(version)
+	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (dp *Disconnect) Decode(src []byte) (int, error) {
-	return nakedDecode(src, DISCONNECT)
+func (dp *Disconnect) Decode(version byte, src []byte) (int, error) {
+	// decode naked packet
+	if version != Version5 {
+		return nakedDecode(src, DISCONNECT)
+	}
+
+	// decode header
+	hl, _, rl, err := headerDecode(src, DISCONNECT)
+	total := hl
+	if err != nil {
+		return total, err
+	}
+
+	// reset reason code and properties
+	dp.ReasonCode = ReasonNormalDisconnection
+	dp.Properties = Properties{}
+
+	// read reason code if present
+	if rl > 0 {
+		dp.ReasonCode = ReasonCode(src[total])
+		total++
+
+		// check reason code
+		if !dp.ReasonCode.Allowed(DISCONNECT) {
+			return total, makeError(dp.Type(), "invalid reason code (%d)", dp.ReasonCode)
+		}
+	}
+
+	// read properties if present
+	if rl > 1 {
+		n, err := dp.Properties.decode(src[total:hl+rl], dp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
 }
 
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (dp *Disconnect) Encode(dst []byte) (int, error) {
-	return nakedEncode(dst, DISCONNECT)
+func (dp *Disconnect) Encode(version byte, dst []byte) (int, error) {
+	// encode naked packet
+	if version != Version5 {
+		return nakedEncode(dst, DISCONNECT)
+	}
+
+	// check reason code
+	if !dp.ReasonCode.Allowed(DISCONNECT) {
+		return 0, makeError(dp.Type(), "invalid reason code (%d)", dp.ReasonCode)
+	}
+
+	// encode header
+	total, err := headerEncode(dst, 0, dp.len(version), dp.Len(version), DISCONNECT)
+	if err != nil {
+		return total, err
+	}
+
+	// write reason code and properties if present
+	if !dp.Properties.Empty() {
+		// write reason code
+		dst[total] = byte(dp.ReasonCode)
+		total++
+
+		// write properties
+		n, err := dp.Properties.encode(dst[total:], dp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	} else if dp.ReasonCode != ReasonNormalDisconnection {
+		// write reason code
+		dst[total] = byte(dp.ReasonCode)
+		total++
+	}
+
+	return total, nil
 }
 
 // String returns a string representation of the packet.
 func (dp *Disconnect) String() string {
-	return "<Disconnect>"
+	// prepare reason code
+	reasonCode := ""
+	if dp.ReasonCode != ReasonNormalDisconnection {
+		reasonCode = fmt.Sprintf(" ReasonCode=%d", dp.ReasonCode)
+	}
+
+	// prepare properties
+	properties := ""
+	if !dp.Properties.Empty() {
+		properties = " Properties=" + dp.Properties.String()
+	}
+
+	return fmt.Sprintf("<Disconnect%s%s>", reasonCode, properties)
+}
+
+// Returns the payload length.
+func (dp *Disconnect) len(version byte) int {
+	// check version
+	if version != Version5 {
+		return 0
+	}
+
+	// add reason code and properties if present
+	if !dp.Properties.Empty() {
+		return 1 + propertiesLen(&dp.Properties)
+	} else if dp.ReasonCode != ReasonNormalDisconnection {
+		return 1
+	}
+
+	return 0
 }
 
 // A Pingreq packet is sent from a client to the server.
@@ -75,20 +183,20 @@ func (pp *Pingreq) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Pingreq) Len() int {
+func (pp *Pingreq) Len(_ byte) int {
 	return nakedLen()
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Pingreq) Decode(src []byte) (int, error) {
+func (pp *Pingreq) Decode(_ byte, src []byte) (int, error) {
 	return nakedDecode(src, PINGREQ)
 }
 
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Pingreq) Encode(dst []byte) (int, error) {
+func (pp *Pingreq) Encode(_ byte, dst []byte) (int, error) {
 	return nakedEncode(dst, PINGREQ)
 }
 
@@ -114,20 +222,20 @@ func (pp *Pingresp) Type() Type {
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Pingresp) Len() int {
+func (pp *Pingresp) Len(_ byte) int {
 	return nakedLen()
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Pingresp) Decode(src []byte) (int, error) {
+func (pp *Pingresp) Decode(_ byte, src []byte) (int, error) {
 	return nakedDecode(src, PINGRESP)
 }
 
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Pingresp) Encode(dst []byte) (int, error) {
+func (pp *Pingresp) Encode(_ byte, dst []byte) (int, error) {
 	return nakedEncode(dst, PINGRESP)
 }
 
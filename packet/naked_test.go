@@ -101,12 +101,12 @@ func testNakedImplementation(t *testing.T, _t Type) {
 	assert.Equal(t, _t, pkt.Type())
 	assert.Equal(t, fmt.Sprintf("<%s>", pkt.Type().String()), pkt.String())
 
-	buf := make([]byte, pkt.Len())
-	n, err := pkt.Encode(buf)
+	buf := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, buf)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, n)
 
-	n, err = pkt.Decode(buf)
+	n, err = pkt.Decode(Version311, buf)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, n)
 }
@@ -122,3 +122,70 @@ func TestPingreqImplementation(t *testing.T) {
 func TestPingrespImplementation(t *testing.T) {
 	testNakedImplementation(t, PINGRESP)
 }
+
+func TestDisconnectEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(DISCONNECT << 4),
+		8,
+		0, // reason code
+		6, // properties length
+		byte(PropertyReasonString),
+		0, // reason string MSB
+		3, // reason string LSB
+		'b', 'y', 'e',
+	}
+
+	pkt := NewDisconnect()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, "bye", pkt.Properties.ReasonString)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	n3, err := pkt.Decode(Version5, []byte{byte(DISCONNECT << 4), 0})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n3)
+	assert.True(t, pkt.Properties.Empty())
+}
+
+func TestDisconnectReasonCodeVersion5(t *testing.T) {
+	pkt := NewDisconnect()
+	pkt.ReasonCode = ReasonDisconnectWithWillMessage
+
+	assert.Equal(t, "<Disconnect ReasonCode=4>", pkt.String())
+
+	dst := make([]byte, pkt.Len(Version5))
+	n, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{byte(DISCONNECT << 4), 1, 0x04}, dst[:n])
+
+	pkt2 := NewDisconnect()
+	n2, err := pkt2.Decode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, n, n2)
+	assert.Equal(t, pkt, pkt2)
+
+	dst = make([]byte, pkt.Len(Version311))
+	n3, err := pkt.Encode(Version311, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n3)
+
+	_, err = pkt.Decode(Version5, []byte{byte(DISCONNECT << 4), 1, 0x10}) // < invalid reason code
+	assert.Error(t, err)
+
+	pkt.ReasonCode = ReasonContinueAuthentication // < invalid reason code
+
+	_, err = pkt.Encode(Version5, make([]byte, 10))
+	assert.Error(t, err)
+}
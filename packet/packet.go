@@ -42,17 +42,20 @@ type Generic interface {
 	// Type returns the packets type.
 	Type() Type
 
-	// Len returns the byte length of the encoded packet.
-	Len() int
+	// Len returns the byte length of the packet encoded using the specified
+	// protocol version.
+	Len(version byte) int
 
-	// Decode reads from the byte slice argument. It returns the total number of
-	// bytes decoded, and whether there have been any errors during the process.
-	Decode(src []byte) (int, error)
+	// Decode reads from the byte slice argument using the specified protocol
+	// version. It returns the total number of bytes decoded, and whether there
+	// have been any errors during the process.
+	Decode(version byte, src []byte) (int, error)
 
-	// Encode writes the packet bytes into the byte slice from the argument. It
-	// returns the number of bytes encoded and whether there's any errors along
-	// the way. If there is an error, the byte slice should be considered invalid.
-	Encode(dst []byte) (int, error)
+	// Encode writes the packet bytes into the byte slice from the argument
+	// using the specified protocol version. It returns the number of bytes
+	// encoded and whether there's any errors along the way. If there is an
+	// error, the byte slice should be considered invalid.
+	Encode(version byte, dst []byte) (int, error)
 
 	// String returns a string representation of the packet.
 	String() string
@@ -107,8 +110,8 @@ func GetID(pkt Generic) (ID, bool) {
 
 // Fuzz is a basic fuzzing test that works with https://github.com/dvyukov/go-fuzz:
 //
-//		$ go-fuzz-build github.com/gomqtt/packet
-//		$ go-fuzz -bin=./packet-fuzz.zip -workdir=./fuzz
+//	$ go-fuzz-build github.com/gomqtt/packet
+//	$ go-fuzz -bin=./packet-fuzz.zip -workdir=./fuzz
 func Fuzz(data []byte) int {
 	// check for zero length data
 	if len(data) == 0 {
@@ -127,9 +130,10 @@ func Fuzz(data []byte) int {
 		return 0
 	}
 
-	// decode it from the buffer.
-	_, err = pkt.Decode(data)
-	if err != nil {
+	// decode it from the buffer using the MQTT 3.1.1 and 5 layout
+	_, err1 := pkt.Decode(Version311, data)
+	_, err2 := pkt.Decode(Version5, data)
+	if err1 != nil && err2 != nil {
 		return 0
 	}
 
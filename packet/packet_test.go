@@ -33,6 +33,7 @@ func TestFixedHeaderFlags(t *testing.T) {
 		PINGREQ:     {"Pingreq", 0},
 		PINGRESP:    {"Pingresp", 0},
 		DISCONNECT:  {"Disconnect", 0},
+		AUTH:        {"Auth", 0},
 	}
 
 	for m, d := range details {
@@ -120,6 +121,7 @@ func TestGetID(t *testing.T) {
 		PINGREQ:     {NewPingreq(), false},
 		PINGRESP:    {NewPingresp(), false},
 		DISCONNECT:  {NewDisconnect(), false},
+		AUTH:        {NewAuth(), false},
 	}
 
 	for _, d := range details {
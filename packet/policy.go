@@ -0,0 +1,96 @@
+package packet
+
+import "errors"
+
+// ErrTopicFilterLimitExceeded is returned by the Decoder if a Subscribe or
+// Unsubscribe packet exceeds the topic filter limit of the read policy.
+var ErrTopicFilterLimitExceeded = errors.New("topic filter limit exceeded")
+
+// ErrTopicLengthExceeded is returned by the Decoder if a topic name or topic
+// filter exceeds the topic length limit of the read policy.
+var ErrTopicLengthExceeded = errors.New("topic length exceeded")
+
+// A ReadPolicy defines additional limits that are enforced by the Decoder
+// when reading packets.
+type ReadPolicy struct {
+	// The maximum length of packets per type. A limit for a type takes
+	// precedence over the read limit of the Decoder. Limits of zero or less
+	// fall back to the read limit of the Decoder.
+	Limits map[Type]int64
+
+	// The maximum number of topic filters in a Subscribe or Unsubscribe packet.
+	MaxTopicFilters int
+
+	// The maximum length of topic names and topic filters.
+	MaxTopicLength int
+}
+
+// returns the limit for the specified packet type
+func (p *ReadPolicy) limit(t Type, fallback int64) int64 {
+	// check limits
+	if p == nil || p.Limits == nil {
+		return fallback
+	}
+
+	// get limit
+	limit, ok := p.Limits[t]
+	if !ok || limit <= 0 {
+		return fallback
+	}
+
+	return limit
+}
+
+// checks a decoded packet against the policy
+func (p *ReadPolicy) check(pkt Generic) error {
+	// check policy
+	if p == nil {
+		return nil
+	}
+
+	switch pkt := pkt.(type) {
+	case *Connect:
+		if pkt.Will != nil {
+			return p.checkTopic(pkt.Will.Topic)
+		}
+	case *Publish:
+		return p.checkTopic(pkt.Message.Topic)
+	case *Subscribe:
+		// check topic filters
+		if p.MaxTopicFilters > 0 && len(pkt.Subscriptions) > p.MaxTopicFilters {
+			return ErrTopicFilterLimitExceeded
+		}
+
+		// check topics
+		for _, sub := range pkt.Subscriptions {
+			err := p.checkTopic(sub.Topic)
+			if err != nil {
+				return err
+			}
+		}
+	case *Unsubscribe:
+		// check topic filters
+		if p.MaxTopicFilters > 0 && len(pkt.Topics) > p.MaxTopicFilters {
+			return ErrTopicFilterLimitExceeded
+		}
+
+		// check topics
+		for _, topic := range pkt.Topics {
+			err := p.checkTopic(topic)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checks the length of a topic
+func (p *ReadPolicy) checkTopic(topic string) error {
+	if p.MaxTopicLength > 0 && len(topic) > p.MaxTopicLength {
+		return ErrTopicLengthExceeded
+	}
+
+	return nil
+}
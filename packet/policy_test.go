@@ -0,0 +1,107 @@
+package packet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func policyTestDecoder(t *testing.T, pkts ...Generic) *Decoder {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	for _, pkt := range pkts {
+		err := enc.Write(pkt, false)
+		assert.NoError(t, err)
+	}
+
+	return NewDecoder(buf)
+}
+
+func TestReadPolicyLimits(t *testing.T) {
+	subscribe := &Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: strings.Repeat("x", 100)}}}
+	publish := &Publish{Message: Message{Topic: "t", Payload: make([]byte, 100)}}
+
+	dec := policyTestDecoder(t, publish, subscribe)
+	dec.SetReadLimit(50)
+	dec.SetReadPolicy(&ReadPolicy{
+		Limits: map[Type]int64{
+			PUBLISH:   1000,
+			SUBSCRIBE: 10,
+		},
+	})
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, publish, pkt)
+
+	pkt, err = dec.Read()
+	assert.Equal(t, ErrReadLimitExceeded, err)
+	assert.Nil(t, pkt)
+}
+
+func TestReadPolicyZeroLimit(t *testing.T) {
+	publish := &Publish{Message: Message{Topic: "t", Payload: make([]byte, 100)}}
+
+	dec := policyTestDecoder(t, publish)
+	dec.SetReadLimit(50)
+	dec.SetReadPolicy(&ReadPolicy{
+		Limits: map[Type]int64{
+			PUBLISH: 0,
+		},
+	})
+
+	pkt, err := dec.Read()
+	assert.Equal(t, ErrReadLimitExceeded, err)
+	assert.Nil(t, pkt)
+}
+
+func TestReadPolicyTopicFilters(t *testing.T) {
+	subscribe := &Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "a"}, {Topic: "b"}}}
+	unsubscribe := &Unsubscribe{ID: 1, Topics: []string{"a", "b", "c"}}
+
+	dec := policyTestDecoder(t, subscribe, unsubscribe)
+	dec.SetReadPolicy(&ReadPolicy{
+		MaxTopicFilters: 2,
+	})
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, subscribe, pkt)
+
+	pkt, err = dec.Read()
+	assert.Equal(t, ErrTopicFilterLimitExceeded, err)
+	assert.Nil(t, pkt)
+}
+
+func TestReadPolicyTopicLength(t *testing.T) {
+	table := []Generic{
+		&Publish{Message: Message{Topic: "foo/bar"}},
+		&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "foo/bar"}}},
+		&Unsubscribe{ID: 1, Topics: []string{"foo/bar"}},
+		&Connect{Will: &Message{Topic: "foo/bar"}, CleanSession: true},
+	}
+
+	for _, pkt := range table {
+		dec := policyTestDecoder(t, pkt)
+		dec.SetPooled(true)
+		dec.SetReadPolicy(&ReadPolicy{
+			MaxTopicLength: 5,
+		})
+
+		pkt, err := dec.Read()
+		assert.Equal(t, ErrTopicLengthExceeded, err)
+		assert.Nil(t, pkt)
+	}
+}
+
+func TestReadPolicyNone(t *testing.T) {
+	dec := policyTestDecoder(t, NewPingreq())
+	assert.Nil(t, dec.ReadPolicy())
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, NewPingreq(), pkt)
+}
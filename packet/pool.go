@@ -0,0 +1,140 @@
+package packet
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// buffers above this size are not returned to the pool
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &Buffer{}
+	},
+}
+
+// A Buffer is a reference counted byte slice that is returned to a pool once
+// all references have been released.
+type Buffer struct {
+	data []byte
+	refs int32
+}
+
+// AcquireBuffer returns a buffer of the specified length from the pool. The
+// returned buffer holds a single reference.
+func AcquireBuffer(length int) *Buffer {
+	// get buffer
+	buffer := bufferPool.Get().(*Buffer)
+
+	// ensure capacity
+	if cap(buffer.data) < length {
+		buffer.data = make([]byte, length)
+	}
+
+	// set length and reference
+	buffer.data = buffer.data[:length]
+	buffer.refs = 1
+
+	return buffer
+}
+
+// Bytes returns the contents of the buffer. The slice must not be used after
+// the last reference has been released.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// Retain adds a reference to the buffer.
+func (b *Buffer) Retain() {
+	atomic.AddInt32(&b.refs, 1)
+}
+
+// Release removes a reference from the buffer. The buffer is returned to the
+// pool once the last reference has been released.
+func (b *Buffer) Release() {
+	// remove reference
+	refs := atomic.AddInt32(&b.refs, -1)
+	if refs > 0 {
+		return
+	} else if refs < 0 {
+		panic("packet: buffer released too often")
+	}
+
+	// return buffer if not too big
+	if cap(b.data) <= maxPooledBufferSize {
+		bufferPool.Put(b)
+	}
+}
+
+var packetPools [AUTH + 1]sync.Pool
+
+func init() {
+	// prepare packet pools
+	for t := CONNECT; t <= AUTH; t++ {
+		t := t
+		packetPools[t].New = func() interface{} {
+			pkt, _ := t.New()
+			return pkt
+		}
+	}
+}
+
+// Acquire returns a packet of the specified type from the packet pool. The
+// packet should be returned using Release once it is not used anymore. An
+// error is returned if the type is invalid.
+func Acquire(t Type) (Generic, error) {
+	// check type
+	if !t.Valid() {
+		return nil, ErrInvalidPacketType
+	}
+
+	return packetPools[t].Get().(Generic), nil
+}
+
+// Release resets the packet and returns it to the packet pool. Publish packets
+// will also release the reference to their payload buffer. The packet and any
+// value obtained from it must not be used after the call.
+func Release(pkt Generic) {
+	// reset packet
+	switch p := pkt.(type) {
+	case *Connect:
+		*p = Connect{CleanSession: true, Version: Version311}
+	case *Connack:
+		*p = Connack{}
+	case *Publish:
+		if p.buffer != nil {
+			p.buffer.Release()
+		}
+		*p = Publish{}
+	case *Puback:
+		*p = Puback{}
+	case *Pubrec:
+		*p = Pubrec{}
+	case *Pubrel:
+		*p = Pubrel{}
+	case *Pubcomp:
+		*p = Pubcomp{}
+	case *Subscribe:
+		*p = Subscribe{}
+	case *Suback:
+		*p = Suback{}
+	case *Unsubscribe:
+		*p = Unsubscribe{}
+	case *Unsuback:
+		*p = Unsuback{}
+	case *Pingreq:
+		*p = Pingreq{}
+	case *Pingresp:
+		*p = Pingresp{}
+	case *Disconnect:
+		*p = Disconnect{}
+	case *Auth:
+		*p = Auth{}
+	default:
+		return
+	}
+
+	// return packet
+	packetPools[pkt.Type()].Put(pkt)
+}
@@ -0,0 +1,62 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	buffer := AcquireBuffer(10)
+	assert.Len(t, buffer.Bytes(), 10)
+
+	buffer.Retain()
+	buffer.Release()
+	buffer.Release()
+
+	assert.Panics(t, func() {
+		buffer.Release()
+	})
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	for tt := CONNECT; tt <= AUTH; tt++ {
+		pkt, err := Acquire(tt)
+		assert.NoError(t, err)
+		assert.Equal(t, tt, pkt.Type())
+
+		Release(pkt)
+	}
+
+	pkt, err := Acquire(Type(0))
+	assert.Error(t, err)
+	assert.Nil(t, pkt)
+}
+
+func TestReleaseReset(t *testing.T) {
+	connect := NewConnect()
+	connect.ClientID = "foo"
+	connect.Version = Version5
+	Release(connect)
+	assert.Equal(t, NewConnect(), connect)
+
+	publish := NewPublish()
+	publish.ID = 1
+	publish.Message.Topic = "foo"
+	publish.buffer = AcquireBuffer(1)
+	Release(publish)
+	assert.Equal(t, NewPublish(), publish)
+}
+
+func BenchmarkAcquireAndRelease(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		pkt, err := Acquire(PUBLISH)
+		if err != nil {
+			panic(err)
+		}
+
+		Release(pkt)
+	}
+}
@@ -0,0 +1,931 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// PropertyID identifies a MQTT 5 property.
+type PropertyID byte
+
+// All available property identifiers.
+const (
+	PropertyPayloadFormatIndicator          PropertyID = 0x01
+	PropertyMessageExpiryInterval           PropertyID = 0x02
+	PropertyContentType                     PropertyID = 0x03
+	PropertyResponseTopic                   PropertyID = 0x08
+	PropertyCorrelationData                 PropertyID = 0x09
+	PropertySubscriptionIdentifier          PropertyID = 0x0B
+	PropertySessionExpiryInterval           PropertyID = 0x11
+	PropertyAssignedClientIdentifier        PropertyID = 0x12
+	PropertyServerKeepAlive                 PropertyID = 0x13
+	PropertyAuthenticationMethod            PropertyID = 0x15
+	PropertyAuthenticationData              PropertyID = 0x16
+	PropertyRequestProblemInformation       PropertyID = 0x17
+	PropertyWillDelayInterval               PropertyID = 0x18
+	PropertyRequestResponseInformation      PropertyID = 0x19
+	PropertyResponseInformation             PropertyID = 0x1A
+	PropertyServerReference                 PropertyID = 0x1C
+	PropertyReasonString                    PropertyID = 0x1F
+	PropertyReceiveMaximum                  PropertyID = 0x21
+	PropertyTopicAliasMaximum               PropertyID = 0x22
+	PropertyTopicAlias                      PropertyID = 0x23
+	PropertyMaximumQOS                      PropertyID = 0x24
+	PropertyRetainAvailable                 PropertyID = 0x25
+	PropertyUserProperty                    PropertyID = 0x26
+	PropertyMaximumPacketSize               PropertyID = 0x27
+	PropertyWildcardSubscriptionAvailable   PropertyID = 0x28
+	PropertySubscriptionIdentifierAvailable PropertyID = 0x29
+	PropertySharedSubscriptionAvailable     PropertyID = 0x2A
+)
+
+// the pseudo type used to check will properties
+const willType Type = 0
+
+// returns the bit mask for the provided types
+func typeMask(types ...Type) uint32 {
+	var mask uint32
+	for _, t := range types {
+		mask |= 1 << t
+	}
+
+	return mask
+}
+
+// the packets that may carry a property
+var propertyTargets = map[PropertyID]uint32{
+	PropertyPayloadFormatIndicator:          typeMask(PUBLISH, willType),
+	PropertyMessageExpiryInterval:           typeMask(PUBLISH, willType),
+	PropertyContentType:                     typeMask(PUBLISH, willType),
+	PropertyResponseTopic:                   typeMask(PUBLISH, willType),
+	PropertyCorrelationData:                 typeMask(PUBLISH, willType),
+	PropertySubscriptionIdentifier:          typeMask(PUBLISH, SUBSCRIBE),
+	PropertySessionExpiryInterval:           typeMask(CONNECT, CONNACK, DISCONNECT),
+	PropertyAssignedClientIdentifier:        typeMask(CONNACK),
+	PropertyServerKeepAlive:                 typeMask(CONNACK),
+	PropertyAuthenticationMethod:            typeMask(CONNECT, CONNACK, AUTH),
+	PropertyAuthenticationData:              typeMask(CONNECT, CONNACK, AUTH),
+	PropertyRequestProblemInformation:       typeMask(CONNECT),
+	PropertyWillDelayInterval:               typeMask(willType),
+	PropertyRequestResponseInformation:      typeMask(CONNECT),
+	PropertyResponseInformation:             typeMask(CONNACK),
+	PropertyServerReference:                 typeMask(CONNACK, DISCONNECT),
+	PropertyReasonString:                    typeMask(CONNACK, PUBACK, PUBREC, PUBREL, PUBCOMP, SUBACK, UNSUBACK, DISCONNECT, AUTH),
+	PropertyReceiveMaximum:                  typeMask(CONNECT, CONNACK),
+	PropertyTopicAliasMaximum:               typeMask(CONNECT, CONNACK),
+	PropertyTopicAlias:                      typeMask(PUBLISH),
+	PropertyMaximumQOS:                      typeMask(CONNACK),
+	PropertyRetainAvailable:                 typeMask(CONNACK),
+	PropertyUserProperty:                    typeMask(CONNECT, CONNACK, PUBLISH, willType, PUBACK, PUBREC, PUBREL, PUBCOMP, SUBSCRIBE, SUBACK, UNSUBSCRIBE, UNSUBACK, DISCONNECT, AUTH),
+	PropertyMaximumPacketSize:               typeMask(CONNECT, CONNACK),
+	PropertyWildcardSubscriptionAvailable:   typeMask(CONNACK),
+	PropertySubscriptionIdentifierAvailable: typeMask(CONNACK),
+	PropertySharedSubscriptionAvailable:     typeMask(CONNACK),
+}
+
+// String returns the name of the property.
+func (id PropertyID) String() string {
+	switch id {
+	case PropertyPayloadFormatIndicator:
+		return "PayloadFormatIndicator"
+	case PropertyMessageExpiryInterval:
+		return "MessageExpiryInterval"
+	case PropertyContentType:
+		return "ContentType"
+	case PropertyResponseTopic:
+		return "ResponseTopic"
+	case PropertyCorrelationData:
+		return "CorrelationData"
+	case PropertySubscriptionIdentifier:
+		return "SubscriptionIdentifier"
+	case PropertySessionExpiryInterval:
+		return "SessionExpiryInterval"
+	case PropertyAssignedClientIdentifier:
+		return "AssignedClientIdentifier"
+	case PropertyServerKeepAlive:
+		return "ServerKeepAlive"
+	case PropertyAuthenticationMethod:
+		return "AuthenticationMethod"
+	case PropertyAuthenticationData:
+		return "AuthenticationData"
+	case PropertyRequestProblemInformation:
+		return "RequestProblemInformation"
+	case PropertyWillDelayInterval:
+		return "WillDelayInterval"
+	case PropertyRequestResponseInformation:
+		return "RequestResponseInformation"
+	case PropertyResponseInformation:
+		return "ResponseInformation"
+	case PropertyServerReference:
+		return "ServerReference"
+	case PropertyReasonString:
+		return "ReasonString"
+	case PropertyReceiveMaximum:
+		return "ReceiveMaximum"
+	case PropertyTopicAliasMaximum:
+		return "TopicAliasMaximum"
+	case PropertyTopicAlias:
+		return "TopicAlias"
+	case PropertyMaximumQOS:
+		return "MaximumQOS"
+	case PropertyRetainAvailable:
+		return "RetainAvailable"
+	case PropertyUserProperty:
+		return "UserProperty"
+	case PropertyMaximumPacketSize:
+		return "MaximumPacketSize"
+	case PropertyWildcardSubscriptionAvailable:
+		return "WildcardSubscriptionAvailable"
+	case PropertySubscriptionIdentifierAvailable:
+		return "SubscriptionIdentifierAvailable"
+	case PropertySharedSubscriptionAvailable:
+		return "SharedSubscriptionAvailable"
+	}
+
+	return "Unknown"
+}
+
+// Valid returns whether the property identifier is known.
+func (id PropertyID) Valid() bool {
+	_, ok := propertyTargets[id]
+	return ok
+}
+
+// Allowed returns whether the property may be carried by the specified packet
+// type. Will properties are checked by setting will to true.
+func (id PropertyID) Allowed(t Type, will bool) bool {
+	// get mask
+	mask := typeMask(t)
+	if will {
+		mask = typeMask(willType)
+	}
+
+	return propertyTargets[id]&mask != 0
+}
+
+// A UserProperty is a name value pair that is carried as a property.
+type UserProperty struct {
+	// The name of the property.
+	Key string `json:"key"`
+
+	// The value of the property.
+	Value string `json:"value"`
+}
+
+// Properties represent the property block of a MQTT 5 packet. Properties that
+// hold a pointer, string, byte slice or slice are absent when nil or empty.
+type Properties struct {
+	// The payload format indicator of a message. If true, the payload is UTF-8
+	// encoded character data.
+	PayloadFormatIndicator *bool `json:"payload_format_indicator,omitempty"`
+
+	// The lifetime of a message in seconds.
+	MessageExpiryInterval *uint32 `json:"message_expiry_interval,omitempty"`
+
+	// The content type of a message.
+	ContentType string `json:"content_type,omitempty"`
+
+	// The topic that should be used for a response message.
+	ResponseTopic string `json:"response_topic,omitempty"`
+
+	// The data used to correlate a response message with a request.
+	CorrelationData []byte `json:"correlation_data,omitempty"`
+
+	// The identifiers of the subscriptions. Only a Publish packet may carry
+	// more than one identifier.
+	SubscriptionIdentifiers []uint32 `json:"subscription_identifiers,omitempty"`
+
+	// The duration of a session in seconds after the connection is closed.
+	SessionExpiryInterval *uint32 `json:"session_expiry_interval,omitempty"`
+
+	// The client identifier assigned by the server.
+	AssignedClientIdentifier string `json:"assigned_client_identifier,omitempty"`
+
+	// The keep alive time assigned by the server.
+	ServerKeepAlive *uint16 `json:"server_keep_alive,omitempty"`
+
+	// The name of the extended authentication method.
+	AuthenticationMethod string `json:"authentication_method,omitempty"`
+
+	// The data of the extended authentication method.
+	AuthenticationData []byte `json:"authentication_data,omitempty"`
+
+	// Whether the server may return reason strings and user properties in
+	// case of failures.
+	RequestProblemInformation *bool `json:"request_problem_information,omitempty"`
+
+	// The delay in seconds before a will message is published.
+	WillDelayInterval *uint32 `json:"will_delay_interval,omitempty"`
+
+	// Whether the client requests the server to return response information.
+	RequestResponseInformation *bool `json:"request_response_information,omitempty"`
+
+	// The information used as the basis for creating a response topic.
+	ResponseInformation string `json:"response_information,omitempty"`
+
+	// Another server that the client should use.
+	ServerReference string `json:"server_reference,omitempty"`
+
+	// A human readable string describing the reason of a result.
+	ReasonString string `json:"reason_string,omitempty"`
+
+	// The number of QOS 1 and 2 publications that can be processed
+	// concurrently.
+	ReceiveMaximum *uint16 `json:"receive_maximum,omitempty"`
+
+	// The highest topic alias that is accepted.
+	TopicAliasMaximum *uint16 `json:"topic_alias_maximum,omitempty"`
+
+	// The alias used to identify the topic of a message.
+	TopicAlias *uint16 `json:"topic_alias,omitempty"`
+
+	// The maximum QOS level supported by the server.
+	MaximumQOS *QOS `json:"maximum_qos,omitempty"`
+
+	// Whether the server supports retained messages.
+	RetainAvailable *bool `json:"retain_available,omitempty"`
+
+	// The user defined name value pairs.
+	UserProperties []UserProperty `json:"user_properties,omitempty"`
+
+	// The maximum packet size that is accepted.
+	MaximumPacketSize *uint32 `json:"maximum_packet_size,omitempty"`
+
+	// Whether the server supports wildcard subscriptions.
+	WildcardSubscriptionAvailable *bool `json:"wildcard_subscription_available,omitempty"`
+
+	// Whether the server supports subscription identifiers.
+	SubscriptionIdentifierAvailable *bool `json:"subscription_identifier_available,omitempty"`
+
+	// Whether the server supports shared subscriptions.
+	SharedSubscriptionAvailable *bool `json:"shared_subscription_available,omitempty"`
+}
+
+// String returns a string representation of the properties.
+func (p *Properties) String() string {
+	// prepare list
+	var list []string
+
+	// add property
+	add := func(id PropertyID, value interface{}) {
+		list = append(list, fmt.Sprintf("%s=%v", id.String(), value))
+	}
+
+	// add quoted property
+	addString := func(id PropertyID, value string) {
+		list = append(list, fmt.Sprintf("%s=%q", id.String(), value))
+	}
+
+	if p.PayloadFormatIndicator != nil {
+		add(PropertyPayloadFormatIndicator, *p.PayloadFormatIndicator)
+	}
+	if p.MessageExpiryInterval != nil {
+		add(PropertyMessageExpiryInterval, *p.MessageExpiryInterval)
+	}
+	if len(p.ContentType) > 0 {
+		addString(PropertyContentType, p.ContentType)
+	}
+	if len(p.ResponseTopic) > 0 {
+		addString(PropertyResponseTopic, p.ResponseTopic)
+	}
+	if p.CorrelationData != nil {
+		add(PropertyCorrelationData, p.CorrelationData)
+	}
+	for _, id := range p.SubscriptionIdentifiers {
+		add(PropertySubscriptionIdentifier, id)
+	}
+	if p.SessionExpiryInterval != nil {
+		add(PropertySessionExpiryInterval, *p.SessionExpiryInterval)
+	}
+	if len(p.AssignedClientIdentifier) > 0 {
+		addString(PropertyAssignedClientIdentifier, p.AssignedClientIdentifier)
+	}
+	if p.ServerKeepAlive != nil {
+		add(PropertyServerKeepAlive, *p.ServerKeepAlive)
+	}
+	if len(p.AuthenticationMethod) > 0 {
+		addString(PropertyAuthenticationMethod, p.AuthenticationMethod)
+	}
+	if p.AuthenticationData != nil {
+		add(PropertyAuthenticationData, p.AuthenticationData)
+	}
+	if p.RequestProblemInformation != nil {
+		add(PropertyRequestProblemInformation, *p.RequestProblemInformation)
+	}
+	if p.WillDelayInterval != nil {
+		add(PropertyWillDelayInterval, *p.WillDelayInterval)
+	}
+	if p.RequestResponseInformation != nil {
+		add(PropertyRequestResponseInformation, *p.RequestResponseInformation)
+	}
+	if len(p.ResponseInformation) > 0 {
+		addString(PropertyResponseInformation, p.ResponseInformation)
+	}
+	if len(p.ServerReference) > 0 {
+		addString(PropertyServerReference, p.ServerReference)
+	}
+	if len(p.ReasonString) > 0 {
+		addString(PropertyReasonString, p.ReasonString)
+	}
+	if p.ReceiveMaximum != nil {
+		add(PropertyReceiveMaximum, *p.ReceiveMaximum)
+	}
+	if p.TopicAliasMaximum != nil {
+		add(PropertyTopicAliasMaximum, *p.TopicAliasMaximum)
+	}
+	if p.TopicAlias != nil {
+		add(PropertyTopicAlias, *p.TopicAlias)
+	}
+	if p.MaximumQOS != nil {
+		add(PropertyMaximumQOS, *p.MaximumQOS)
+	}
+	if p.RetainAvailable != nil {
+		add(PropertyRetainAvailable, *p.RetainAvailable)
+	}
+	for _, up := range p.UserProperties {
+		list = append(list, fmt.Sprintf("%s=%q=>%q", PropertyUserProperty.String(), up.Key, up.Value))
+	}
+	if p.MaximumPacketSize != nil {
+		add(PropertyMaximumPacketSize, *p.MaximumPacketSize)
+	}
+	if p.WildcardSubscriptionAvailable != nil {
+		add(PropertyWildcardSubscriptionAvailable, *p.WildcardSubscriptionAvailable)
+	}
+	if p.SubscriptionIdentifierAvailable != nil {
+		add(PropertySubscriptionIdentifierAvailable, *p.SubscriptionIdentifierAvailable)
+	}
+	if p.SharedSubscriptionAvailable != nil {
+		add(PropertySharedSubscriptionAvailable, *p.SharedSubscriptionAvailable)
+	}
+
+	return fmt.Sprintf("<Properties %s>", strings.Join(list, " "))
+}
+
+// Empty returns whether no property is present.
+func (p *Properties) Empty() bool {
+	return p.len() == 0
+}
+
+// returns the byte length of the properties without the length prefix
+func (p *Properties) len() int {
+	// prepare total
+	total := 0
+
+	// add fixed length properties (identifier and value)
+	if p.PayloadFormatIndicator != nil {
+		total += 1 + 1
+	}
+	if p.MessageExpiryInterval != nil {
+		total += 1 + 4
+	}
+	if p.SessionExpiryInterval != nil {
+		total += 1 + 4
+	}
+	if p.ServerKeepAlive != nil {
+		total += 1 + 2
+	}
+	if p.RequestProblemInformation != nil {
+		total += 1 + 1
+	}
+	if p.WillDelayInterval != nil {
+		total += 1 + 4
+	}
+	if p.RequestResponseInformation != nil {
+		total += 1 + 1
+	}
+	if p.ReceiveMaximum != nil {
+		total += 1 + 2
+	}
+	if p.TopicAliasMaximum != nil {
+		total += 1 + 2
+	}
+	if p.TopicAlias != nil {
+		total += 1 + 2
+	}
+	if p.MaximumQOS != nil {
+		total += 1 + 1
+	}
+	if p.RetainAvailable != nil {
+		total += 1 + 1
+	}
+	if p.MaximumPacketSize != nil {
+		total += 1 + 4
+	}
+	if p.WildcardSubscriptionAvailable != nil {
+		total += 1 + 1
+	}
+	if p.SubscriptionIdentifierAvailable != nil {
+		total += 1 + 1
+	}
+	if p.SharedSubscriptionAvailable != nil {
+		total += 1 + 1
+	}
+
+	// add strings and binary data
+	for _, str := range []string{
+		p.ContentType,
+		p.ResponseTopic,
+		p.AssignedClientIdentifier,
+		p.AuthenticationMethod,
+		p.ResponseInformation,
+		p.ServerReference,
+		p.ReasonString,
+	} {
+		if len(str) > 0 {
+			total += 1 + 2 + len(str)
+		}
+	}
+	if p.CorrelationData != nil {
+		total += 1 + 2 + len(p.CorrelationData)
+	}
+	if p.AuthenticationData != nil {
+		total += 1 + 2 + len(p.AuthenticationData)
+	}
+
+	// add subscription identifiers
+	for _, id := range p.SubscriptionIdentifiers {
+		total += 1 + varintLen(int(id))
+	}
+
+	// add user properties
+	for _, up := range p.UserProperties {
+		total += 1 + 2 + len(up.Key) + 2 + len(up.Value)
+	}
+
+	return total
+}
+
+// returns the byte length of the encoded properties including the length prefix
+func propertiesLen(p *Properties) int {
+	l := p.len()
+	return varintLen(l) + l
+}
+
+// decodes a property block
+func (p *Properties) decode(src []byte, t Type, will bool) (int, error) {
+	// read length
+	length, total, err := readVarint(src, t)
+	if err != nil {
+		return total, err
+	}
+
+	// check buffer
+	if len(src) < total+length {
+		return total, makeError(t, "insufficient buffer size, expected %d, got %d", total+length, len(src))
+	}
+
+	// reset properties
+	*p = Properties{}
+
+	// prepare seen properties
+	var seen uint64
+
+	// read properties
+	end := total + length
+	for total < end {
+		// read identifier
+		_id, n, err := readVarint(src[total:end], t)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		// check identifier
+		id := PropertyID(_id)
+		if _id > 0xff || !id.Valid() {
+			return total, makeError(t, "invalid property identifier (%d)", _id)
+		}
+
+		// check target
+		if !id.Allowed(t, will) {
+			return total, makeError(t, "property %s not allowed", id.String())
+		}
+
+		// check for duplicates
+		if seen&(1<<id) != 0 && id != PropertyUserProperty && !(id == PropertySubscriptionIdentifier && t == PUBLISH) {
+			return total, makeError(t, "duplicate property %s", id.String())
+		}
+		seen |= 1 << id
+
+		// read value
+		n, err = p.decodeValue(src[total:end], id, t)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// decodes a single property value
+func (p *Properties) decodeValue(src []byte, id PropertyID, t Type) (int, error) {
+	switch id {
+	case PropertyPayloadFormatIndicator:
+		return readBoolProperty(src, &p.PayloadFormatIndicator, id, t)
+	case PropertyMessageExpiryInterval:
+		return readUint32Property(src, &p.MessageExpiryInterval, t)
+	case PropertyContentType:
+		return readStringProperty(src, &p.ContentType, t)
+	case PropertyResponseTopic:
+		return readStringProperty(src, &p.ResponseTopic, t)
+	case PropertyCorrelationData:
+		return readBytesProperty(src, &p.CorrelationData, t)
+	case PropertySubscriptionIdentifier:
+		value, n, err := readVarint(src, t)
+		if err != nil {
+			return n, err
+		}
+
+		// check value
+		if value == 0 {
+			return n, makeError(t, "subscription identifier must be greater than zero")
+		}
+
+		p.SubscriptionIdentifiers = append(p.SubscriptionIdentifiers, uint32(value))
+		return n, nil
+	case PropertySessionExpiryInterval:
+		return readUint32Property(src, &p.SessionExpiryInterval, t)
+	case PropertyAssignedClientIdentifier:
+		return readStringProperty(src, &p.AssignedClientIdentifier, t)
+	case PropertyServerKeepAlive:
+		return readUint16Property(src, &p.ServerKeepAlive, t)
+	case PropertyAuthenticationMethod:
+		return readStringProperty(src, &p.AuthenticationMethod, t)
+	case PropertyAuthenticationData:
+		return readBytesProperty(src, &p.AuthenticationData, t)
+	case PropertyRequestProblemInformation:
+		return readBoolProperty(src, &p.RequestProblemInformation, id, t)
+	case PropertyWillDelayInterval:
+		return readUint32Property(src, &p.WillDelayInterval, t)
+	case PropertyRequestResponseInformation:
+		return readBoolProperty(src, &p.RequestResponseInformation, id, t)
+	case PropertyResponseInformation:
+		return readStringProperty(src, &p.ResponseInformation, t)
+	case PropertyServerReference:
+		return readStringProperty(src, &p.ServerReference, t)
+	case PropertyReasonString:
+		return readStringProperty(src, &p.ReasonString, t)
+	case PropertyReceiveMaximum:
+		n, err := readUint16Property(src, &p.ReceiveMaximum, t)
+		if err == nil && *p.ReceiveMaximum == 0 {
+			return n, makeError(t, "receive maximum must be greater than zero")
+		}
+		return n, err
+	case PropertyTopicAliasMaximum:
+		return readUint16Property(src, &p.TopicAliasMaximum, t)
+	case PropertyTopicAlias:
+		n, err := readUint16Property(src, &p.TopicAlias, t)
+		if err == nil && *p.TopicAlias == 0 {
+			return n, makeError(t, "topic alias must be greater than zero")
+		}
+		return n, err
+	case PropertyMaximumQOS:
+		// check buffer
+		if len(src) < 1 {
+			return 0, makeError(t, "insufficient buffer size, expected 1, got 0")
+		}
+
+		// check value
+		qos := QOS(src[0])
+		if qos > QOSAtLeastOnce {
+			return 1, makeError(t, "invalid maximum QOS level (%d)", qos)
+		}
+
+		p.MaximumQOS = &qos
+		return 1, nil
+	case PropertyRetainAvailable:
+		return readBoolProperty(src, &p.RetainAvailable, id, t)
+	case PropertyUserProperty:
+		key, n, err := readLPString(src, t)
+		if err != nil {
+			return n, err
+		}
+
+		value, m, err := readLPString(src[n:], t)
+		if err != nil {
+			return n + m, err
+		}
+
+		p.UserProperties = append(p.UserProperties, UserProperty{Key: key, Value: value})
+		return n + m, nil
+	case PropertyMaximumPacketSize:
+		n, err := readUint32Property(src, &p.MaximumPacketSize, t)
+		if err == nil && *p.MaximumPacketSize == 0 {
+			return n, makeError(t, "maximum packet size must be greater than zero")
+		}
+		return n, err
+	case PropertyWildcardSubscriptionAvailable:
+		return readBoolProperty(src, &p.WildcardSubscriptionAvailable, id, t)
+	case PropertySubscriptionIdentifierAvailable:
+		return readBoolProperty(src, &p.SubscriptionIdentifierAvailable, id, t)
+	case PropertySharedSubscriptionAvailable:
+		return readBoolProperty(src, &p.SharedSubscriptionAvailable, id, t)
+	}
+
+	return 0, makeError(t, "invalid property identifier (%d)", id)
+}
+
+// encodes a property block
+func (p *Properties) encode(dst []byte, t Type, will bool) (int, error) {
+	// write length
+	total, err := writeVarint(dst, p.len(), t)
+	if err != nil {
+		return total, err
+	}
+
+	// prepare writer
+	var n int
+	write := func(id PropertyID, fn func([]byte) (int, error)) {
+		// skip on error
+		if err != nil {
+			return
+		}
+
+		// check target
+		if !id.Allowed(t, will) {
+			err = makeError(t, "property %s not allowed", id.String())
+			return
+		}
+
+		// check buffer
+		if len(dst) < total+1 {
+			err = makeError(t, "insufficient buffer size, expected %d, got %d", total+1, len(dst))
+			return
+		}
+
+		// write identifier
+		dst[total] = byte(id)
+		total++
+
+		// write value
+		n, err = fn(dst[total:])
+		total += n
+	}
+
+	if p.PayloadFormatIndicator != nil {
+		write(PropertyPayloadFormatIndicator, boolWriter(*p.PayloadFormatIndicator, t))
+	}
+	if p.MessageExpiryInterval != nil {
+		write(PropertyMessageExpiryInterval, uint32Writer(*p.MessageExpiryInterval, t))
+	}
+	if len(p.ContentType) > 0 {
+		write(PropertyContentType, stringWriter(p.ContentType, t))
+	}
+	if len(p.ResponseTopic) > 0 {
+		write(PropertyResponseTopic, stringWriter(p.ResponseTopic, t))
+	}
+	if p.CorrelationData != nil {
+		write(PropertyCorrelationData, bytesWriter(p.CorrelationData, t))
+	}
+	for i, id := range p.SubscriptionIdentifiers {
+		// check count
+		if i > 0 && t != PUBLISH {
+			return total, makeError(t, "duplicate property %s", PropertySubscriptionIdentifier.String())
+		}
+
+		// check value
+		if id == 0 {
+			return total, makeError(t, "subscription identifier must be greater than zero")
+		}
+
+		write(PropertySubscriptionIdentifier, varintWriter(int(id), t))
+	}
+	if p.SessionExpiryInterval != nil {
+		write(PropertySessionExpiryInterval, uint32Writer(*p.SessionExpiryInterval, t))
+	}
+	if len(p.AssignedClientIdentifier) > 0 {
+		write(PropertyAssignedClientIdentifier, stringWriter(p.AssignedClientIdentifier, t))
+	}
+	if p.ServerKeepAlive != nil {
+		write(PropertyServerKeepAlive, uint16Writer(*p.ServerKeepAlive, t))
+	}
+	if len(p.AuthenticationMethod) > 0 {
+		write(PropertyAuthenticationMethod, stringWriter(p.AuthenticationMethod, t))
+	}
+	if p.AuthenticationData != nil {
+		write(PropertyAuthenticationData, bytesWriter(p.AuthenticationData, t))
+	}
+	if p.RequestProblemInformation != nil {
+		write(PropertyRequestProblemInformation, boolWriter(*p.RequestProblemInformation, t))
+	}
+	if p.WillDelayInterval != nil {
+		write(PropertyWillDelayInterval, uint32Writer(*p.WillDelayInterval, t))
+	}
+	if p.RequestResponseInformation != nil {
+		write(PropertyRequestResponseInformation, boolWriter(*p.RequestResponseInformation, t))
+	}
+	if len(p.ResponseInformation) > 0 {
+		write(PropertyResponseInformation, stringWriter(p.ResponseInformation, t))
+	}
+	if len(p.ServerReference) > 0 {
+		write(PropertyServerReference, stringWriter(p.ServerReference, t))
+	}
+	if len(p.ReasonString) > 0 {
+		write(PropertyReasonString, stringWriter(p.ReasonString, t))
+	}
+	if p.ReceiveMaximum != nil {
+		// check value
+		if *p.ReceiveMaximum == 0 {
+			return total, makeError(t, "receive maximum must be greater than zero")
+		}
+
+		write(PropertyReceiveMaximum, uint16Writer(*p.ReceiveMaximum, t))
+	}
+	if p.TopicAliasMaximum != nil {
+		write(PropertyTopicAliasMaximum, uint16Writer(*p.TopicAliasMaximum, t))
+	}
+	if p.TopicAlias != nil {
+		// check value
+		if *p.TopicAlias == 0 {
+			return total, makeError(t, "topic alias must be greater than zero")
+		}
+
+		write(PropertyTopicAlias, uint16Writer(*p.TopicAlias, t))
+	}
+	if p.MaximumQOS != nil {
+		// check value
+		if *p.MaximumQOS > QOSAtLeastOnce {
+			return total, makeError(t, "invalid maximum QOS level (%d)", *p.MaximumQOS)
+		}
+
+		write(PropertyMaximumQOS, byteWriter(byte(*p.MaximumQOS), t))
+	}
+	if p.RetainAvailable != nil {
+		write(PropertyRetainAvailable, boolWriter(*p.RetainAvailable, t))
+	}
+	for _, up := range p.UserProperties {
+		write(PropertyUserProperty, pairWriter(up, t))
+	}
+	if p.MaximumPacketSize != nil {
+		// check value
+		if *p.MaximumPacketSize == 0 {
+			return total, makeError(t, "maximum packet size must be greater than zero")
+		}
+
+		write(PropertyMaximumPacketSize, uint32Writer(*p.MaximumPacketSize, t))
+	}
+	if p.WildcardSubscriptionAvailable != nil {
+		write(PropertyWildcardSubscriptionAvailable, boolWriter(*p.WildcardSubscriptionAvailable, t))
+	}
+	if p.SubscriptionIdentifierAvailable != nil {
+		write(PropertySubscriptionIdentifierAvailable, boolWriter(*p.SubscriptionIdentifierAvailable, t))
+	}
+	if p.SharedSubscriptionAvailable != nil {
+		write(PropertySharedSubscriptionAvailable, boolWriter(*p.SharedSubscriptionAvailable, t))
+	}
+
+	return total, err
+}
+
+// read a boolean property value
+func readBoolProperty(src []byte, dst **bool, id PropertyID, t Type) (int, error) {
+	// check buffer
+	if len(src) < 1 {
+		return 0, makeError(t, "insufficient buffer size, expected 1, got 0")
+	}
+
+	// check value
+	if src[0] > 1 {
+		return 1, makeError(t, "invalid value (%d) for property %s", src[0], id.String())
+	}
+
+	// set value
+	value := src[0] == 1
+	*dst = &value
+
+	return 1, nil
+}
+
+// read a two byte integer property value
+func readUint16Property(src []byte, dst **uint16, t Type) (int, error) {
+	// check buffer
+	if len(src) < 2 {
+		return 0, makeError(t, "insufficient buffer size, expected 2, got %d", len(src))
+	}
+
+	// set value
+	value := binary.BigEndian.Uint16(src)
+	*dst = &value
+
+	return 2, nil
+}
+
+// read a four byte integer property value
+func readUint32Property(src []byte, dst **uint32, t Type) (int, error) {
+	// check buffer
+	if len(src) < 4 {
+		return 0, makeError(t, "insufficient buffer size, expected 4, got %d", len(src))
+	}
+
+	// set value
+	value := binary.BigEndian.Uint32(src)
+	*dst = &value
+
+	return 4, nil
+}
+
+// read a string property value
+func readStringProperty(src []byte, dst *string, t Type) (int, error) {
+	str, n, err := readLPString(src, t)
+	*dst = str
+	return n, err
+}
+
+// read a binary data property value
+func readBytesProperty(src []byte, dst *[]byte, t Type) (int, error) {
+	bytes, n, err := readLPBytes(src, true, t)
+	*dst = bytes
+	return n, err
+}
+
+// returns a writer for a single byte value
+func byteWriter(value byte, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		// check buffer
+		if len(dst) < 1 {
+			return 0, makeError(t, "insufficient buffer size, expected 1, got 0")
+		}
+
+		// write value
+		dst[0] = value
+
+		return 1, nil
+	}
+}
+
+// returns a writer for a boolean value
+func boolWriter(value bool, t Type) func([]byte) (int, error) {
+	if value {
+		return byteWriter(1, t)
+	}
+
+	return byteWriter(0, t)
+}
+
+// returns a writer for a two byte integer value
+func uint16Writer(value uint16, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		// check buffer
+		if len(dst) < 2 {
+			return 0, makeError(t, "insufficient buffer size, expected 2, got %d", len(dst))
+		}
+
+		// write value
+		binary.BigEndian.PutUint16(dst, value)
+
+		return 2, nil
+	}
+}
+
+// returns a writer for a four byte integer value
+func uint32Writer(value uint32, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		// check buffer
+		if len(dst) < 4 {
+			return 0, makeError(t, "insufficient buffer size, expected 4, got %d", len(dst))
+		}
+
+		// write value
+		binary.BigEndian.PutUint32(dst, value)
+
+		return 4, nil
+	}
+}
+
+// returns a writer for a variable byte integer value
+func varintWriter(value int, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		return writeVarint(dst, value, t)
+	}
+}
+
+// returns a writer for a string value
+func stringWriter(value string, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		return writeLPString(dst, value, t)
+	}
+}
+
+// returns a writer for a binary data value
+func bytesWriter(value []byte, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		return writeLPBytes(dst, value, t)
+	}
+}
+
+// returns a writer for a string pair value
+func pairWriter(value UserProperty, t Type) func([]byte) (int, error) {
+	return func(dst []byte) (int, error) {
+		// write key
+		n, err := writeLPString(dst, value.Key, t)
+		if err != nil {
+			return n, err
+		}
+
+		// write value
+		m, err := writeLPString(dst[n:], value.Value, t)
+		return n + m, err
+	}
+}
@@ -0,0 +1,210 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func qosPtr(v QOS) *QOS {
+	return &v
+}
+
+func TestPropertyID(t *testing.T) {
+	assert.Equal(t, "ContentType", PropertyContentType.String())
+	assert.Equal(t, "Unknown", PropertyID(0).String())
+	assert.True(t, PropertyContentType.Valid())
+	assert.False(t, PropertyID(0x04).Valid())
+	assert.True(t, PropertyContentType.Allowed(PUBLISH, false))
+	assert.True(t, PropertyContentType.Allowed(CONNECT, true))
+	assert.False(t, PropertyContentType.Allowed(CONNECT, false))
+	assert.True(t, PropertyWillDelayInterval.Allowed(CONNECT, true))
+	assert.False(t, PropertyWillDelayInterval.Allowed(PUBLISH, false))
+}
+
+func TestPropertiesEncode(t *testing.T) {
+	propBytes := []byte{
+		15, // property length
+		byte(PropertyPayloadFormatIndicator),
+		1,
+		byte(PropertyContentType),
+		0, // content type MSB
+		4, // content type LSB
+		'j', 's', 'o', 'n',
+		byte(PropertySubscriptionIdentifier),
+		0x80, 0x01, // subscription identifier
+		byte(PropertyTopicAlias),
+		0, // topic alias MSB
+		5, // topic alias LSB
+	}
+
+	props := Properties{
+		PayloadFormatIndicator:  boolPtr(true),
+		ContentType:             "json",
+		SubscriptionIdentifiers: []uint32{128},
+		TopicAlias:              uint16Ptr(5),
+	}
+
+	assert.Equal(t, len(propBytes), propertiesLen(&props))
+
+	dst := make([]byte, propertiesLen(&props))
+	n, err := props.encode(dst, PUBLISH, false)
+	assert.NoError(t, err)
+	assert.Equal(t, len(propBytes), n)
+	assert.Equal(t, propBytes, dst)
+
+	var props2 Properties
+	n, err = props2.decode(propBytes, PUBLISH, false)
+	assert.NoError(t, err)
+	assert.Equal(t, len(propBytes), n)
+	assert.Equal(t, props, props2)
+}
+
+func TestPropertiesEqualDecodeEncode(t *testing.T) {
+	table := []struct {
+		typ   Type
+		will  bool
+		props Properties
+	}{
+		{CONNECT, false, Properties{
+			SessionExpiryInterval:      uint32Ptr(60),
+			AuthenticationMethod:       "SCRAM-SHA-1",
+			AuthenticationData:         []byte{1, 2, 3},
+			RequestProblemInformation:  boolPtr(false),
+			RequestResponseInformation: boolPtr(true),
+			ReceiveMaximum:             uint16Ptr(10),
+			TopicAliasMaximum:          uint16Ptr(20),
+			UserProperties:             []UserProperty{{"a", "b"}, {"a", "c"}},
+			MaximumPacketSize:          uint32Ptr(1024),
+		}},
+		{CONNECT, true, Properties{
+			PayloadFormatIndicator: boolPtr(false),
+			MessageExpiryInterval:  uint32Ptr(10),
+			ContentType:            "text/plain",
+			ResponseTopic:          "response",
+			CorrelationData:        []byte("id"),
+			WillDelayInterval:      uint32Ptr(5),
+			UserProperties:         []UserProperty{{"k", "v"}},
+		}},
+		{CONNACK, false, Properties{
+			SessionExpiryInterval:           uint32Ptr(60),
+			AssignedClientIdentifier:        "client",
+			ServerKeepAlive:                 uint16Ptr(30),
+			ResponseInformation:             "info",
+			ServerReference:                 "other",
+			ReasonString:                    "reason",
+			ReceiveMaximum:                  uint16Ptr(10),
+			MaximumQOS:                      qosPtr(QOSAtLeastOnce),
+			RetainAvailable:                 boolPtr(false),
+			WildcardSubscriptionAvailable:   boolPtr(true),
+			SubscriptionIdentifierAvailable: boolPtr(false),
+			SharedSubscriptionAvailable:     boolPtr(true),
+		}},
+		{PUBLISH, false, Properties{
+			SubscriptionIdentifiers: []uint32{1, 268435455},
+			TopicAlias:              uint16Ptr(1),
+		}},
+		{PUBACK, false, Properties{
+			ReasonString:   "ok",
+			UserProperties: []UserProperty{{"x", "y"}},
+		}},
+	}
+
+	for _, item := range table {
+		dst := make([]byte, propertiesLen(&item.props))
+		n, err := item.props.encode(dst, item.typ, item.will)
+		assert.NoError(t, err)
+		assert.Equal(t, len(dst), n)
+
+		var props Properties
+		n, err = props.decode(dst, item.typ, item.will)
+		assert.NoError(t, err)
+		assert.Equal(t, len(dst), n)
+		assert.Equal(t, item.props, props)
+	}
+}
+
+func TestPropertiesString(t *testing.T) {
+	props := Properties{
+		ContentType:           "json",
+		MessageExpiryInterval: uint32Ptr(10),
+		UserProperties:        []UserProperty{{"a", "b"}},
+	}
+
+	assert.Equal(t, `<Properties MessageExpiryInterval=10 ContentType="json" UserProperty="a"=>"b">`, props.String())
+}
+
+func TestPropertiesEmpty(t *testing.T) {
+	props := Properties{}
+	assert.True(t, props.Empty())
+	assert.Equal(t, 1, propertiesLen(&props))
+
+	props.ReasonString = "foo"
+	assert.False(t, props.Empty())
+}
+
+func TestPropertiesDecodeError(t *testing.T) {
+	table := []struct {
+		typ   Type
+		bytes []byte
+	}{
+		{PUBLISH, []byte{}},                                                                                      // < missing length
+		{PUBLISH, []byte{2, byte(PropertyTopicAlias)}},                                                           // < insufficient buffer
+		{PUBLISH, []byte{1, 0x04}},                                                                               // < invalid identifier
+		{PUBLISH, []byte{2, byte(PropertyReasonString), 0}},                                                      // < not allowed
+		{PUBLISH, []byte{2, byte(PropertyPayloadFormatIndicator), 2}},                                            // < invalid value
+		{PUBLISH, []byte{3, byte(PropertyTopicAlias), 0, 0}},                                                     // < zero topic alias
+		{PUBLISH, []byte{2, byte(PropertySubscriptionIdentifier), 0}},                                            // < zero identifier
+		{PUBLISH, []byte{4, byte(PropertyPayloadFormatIndicator), 1, byte(PropertyPayloadFormatIndicator), 1}},   // < duplicate
+		{SUBSCRIBE, []byte{4, byte(PropertySubscriptionIdentifier), 1, byte(PropertySubscriptionIdentifier), 2}}, // < duplicate
+		{CONNECT, []byte{3, byte(PropertyReceiveMaximum), 0, 0}},                                                 // < zero receive maximum
+		{CONNECT, []byte{5, byte(PropertyMaximumPacketSize), 0, 0, 0, 0}},                                        // < zero maximum packet size
+		{CONNACK, []byte{2, byte(PropertyMaximumQOS), 2}},                                                        // < invalid maximum qos
+		{CONNACK, []byte{3, byte(PropertyUserProperty), 0, 1}},                                                   // < insufficient key
+		{CONNACK, []byte{5, byte(PropertyUserProperty), 0, 0, 0, 1}},                                             // < insufficient value
+	}
+
+	for _, item := range table {
+		var props Properties
+		_, err := props.decode(item.bytes, item.typ, false)
+		assert.Error(t, err, "%v", item.bytes)
+	}
+}
+
+func TestPropertiesEncodeError(t *testing.T) {
+	table := []struct {
+		typ   Type
+		props Properties
+	}{
+		{PUBLISH, Properties{ReasonString: "foo"}},                       // < not allowed
+		{PUBLISH, Properties{TopicAlias: uint16Ptr(0)}},                  // < zero topic alias
+		{PUBLISH, Properties{SubscriptionIdentifiers: []uint32{0}}},      // < zero identifier
+		{SUBSCRIBE, Properties{SubscriptionIdentifiers: []uint32{1, 2}}}, // < multiple identifiers
+		{CONNECT, Properties{ReceiveMaximum: uint16Ptr(0)}},              // < zero receive maximum
+		{CONNECT, Properties{MaximumPacketSize: uint32Ptr(0)}},           // < zero maximum packet size
+		{CONNACK, Properties{MaximumQOS: qosPtr(QOSExactlyOnce)}},        // < invalid maximum qos
+		{CONNACK, Properties{ReasonString: string(make([]byte, 65536))}}, // < too long
+	}
+
+	for _, item := range table {
+		dst := make([]byte, propertiesLen(&item.props))
+		_, err := item.props.encode(dst, item.typ, false)
+		assert.Error(t, err)
+	}
+
+	props := Properties{ContentType: "json"}
+	_, err := props.encode(make([]byte, 3), PUBLISH, false) // < insufficient buffer
+	assert.Error(t, err)
+}
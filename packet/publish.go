@@ -3,6 +3,7 @@ package packet
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 // A Publish packet is sent from a client to a server or from server to a client
@@ -19,6 +20,24 @@ type Publish struct {
 
 	// The packet identifier.
 	ID ID
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
+
+	// If set, the payload is read from the reader instead of being taken from
+	// the message. The reader must provide exactly PayloadLength bytes. The
+	// Decoder sets the reader if streaming is enabled and the payload exceeds
+	// the streaming threshold. In this case the reader is only valid until the
+	// next packet is read. Encoding the packet consumes the reader, a packet
+	// with a reader can therefore only be encoded once and cannot be saved in
+	// a session to be resent later.
+	PayloadReader io.Reader
+
+	// The length of the payload provided by the PayloadReader.
+	PayloadLength int
+
+	// the buffer referenced by the payload
+	buffer *Buffer
 }
 
 // NewPublish creates a new Publish packet.
@@ -33,19 +52,45 @@ func (pp *Publish) Type() Type {
 
 // String returns a string representation of the packet.
 func (pp *Publish) String() string {
-	return fmt.Sprintf("<Publish ID=%d Message=%s Dup=%t>",
-		pp.ID, pp.Message.String(), pp.Dup)
+	// prepare properties
+	properties := ""
+	if !pp.Properties.Empty() {
+		properties = " Properties=" + pp.Properties.String()
+	}
+
+	// prepare payload stream
+	stream := ""
+	if pp.PayloadReader != nil {
+		stream = fmt.Sprintf(" PayloadLength=%d", pp.PayloadLength)
+	}
+
+	return fmt.Sprintf("<Publish ID=%d Message=%s Dup=%t%s%s>",
+		pp.ID, pp.Message.String(), pp.Dup, stream, properties)
 }
 
 // Len returns the byte length of the encoded packet.
-func (pp *Publish) Len() int {
-	ml := pp.len()
+func (pp *Publish) Len(version byte) int {
+	ml := pp.len(version)
 	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (pp *Publish) Decode(src []byte) (int, error) {
+func (pp *Publish) Decode(version byte, src []byte) (int, error) {
+	return pp.decode(version, src, nil)
+}
+
+// decodes the packet and references the payload from the buffer if present,
+// the packet takes over the reference to the buffer
+func (pp *Publish) decode(version byte, src []byte, buffer *Buffer) (int, error) {
+	// release previous buffer
+	if pp.buffer != nil {
+		pp.buffer.Release()
+	}
+
+	// set buffer
+	pp.buffer = buffer
+
 	// decode header
 	hl, flags, rl, err := headerDecode(src, PUBLISH)
 	total := hl
@@ -88,11 +133,31 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 		}
 	}
 
+	// read properties
+	if version == Version5 {
+		// check remaining length
+		if total > hl+rl {
+			return total, makeError(pp.Type(), "remaining length (%d) is less than the variable header length (%d)", rl, total-hl)
+		}
+
+		n, err = pp.Properties.decode(src[total:hl+rl], pp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// calculate payload length
 	l := rl - (total - hl)
 
-	// read payload
-	if l > 0 {
+	// reference payload if buffer is available
+	if l > 0 && buffer != nil {
+		pp.Message.Payload = src[total : total+l : total+l]
+		total += l
+	}
+
+	// otherwise read payload
+	if l > 0 && buffer == nil {
 		pp.Message.Payload = make([]byte, l)
 		copy(pp.Message.Payload, src[total:total+l])
 		total += len(pp.Message.Payload)
@@ -104,9 +169,40 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (pp *Publish) Encode(dst []byte) (int, error) {
-	// check topic length
-	if len(pp.Message.Topic) == 0 {
+func (pp *Publish) Encode(version byte, dst []byte) (int, error) {
+	// check buffer length
+	if len(dst) < pp.Len(version) {
+		return 0, makeError(pp.Type(), "insufficient buffer size, expected %d, got %d", pp.Len(version), len(dst))
+	}
+
+	// encode header
+	total, err := pp.encodeHeader(version, dst)
+	if err != nil {
+		return total, err
+	}
+
+	// read payload if streamed
+	if pp.PayloadReader != nil {
+		n, err := io.ReadFull(pp.PayloadReader, dst[total:total+pp.PayloadLength])
+		total += n
+		if err != nil {
+			return total, makeError(pp.Type(), "failed to read payload: %s", err.Error())
+		}
+
+		return total, nil
+	}
+
+	// write payload
+	copy(dst[total:], pp.Message.Payload)
+	total += len(pp.Message.Payload)
+
+	return total, nil
+}
+
+// encodes the fixed header and the variable header of the packet
+func (pp *Publish) encodeHeader(version byte, dst []byte) (int, error) {
+	// check topic length (may be empty if a topic alias is used)
+	if len(pp.Message.Topic) == 0 && (version != Version5 || pp.Properties.TopicAlias == nil) {
 		return 0, makeError(pp.Type(), "topic name is empty")
 	}
 
@@ -136,8 +232,13 @@ func (pp *Publish) Encode(dst []byte) (int, error) {
 	// set qos
 	flags = (flags & 249) | (byte(pp.Message.QOS) << 1) // 249 = 11111001
 
+	// check payload length
+	if pp.PayloadReader != nil && pp.PayloadLength < 0 {
+		return 0, makeError(pp.Type(), "invalid payload length (%d)", pp.PayloadLength)
+	}
+
 	// encode header
-	total, err := headerEncode(dst, flags, pp.len(), pp.Len(), PUBLISH)
+	total, err := headerEncode(dst, flags, pp.len(version), pp.Len(version)-pp.payloadLen(), PUBLISH)
 	if err != nil {
 		return total, err
 	}
@@ -155,19 +256,38 @@ func (pp *Publish) Encode(dst []byte) (int, error) {
 		total += 2
 	}
 
-	// write payload
-	copy(dst[total:], pp.Message.Payload)
-	total += len(pp.Message.Payload)
+	// write properties
+	if version == Version5 {
+		n, err = pp.Properties.encode(dst[total:], pp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
 
 	return total, nil
 }
 
+// Returns the length of the message payload or streamed payload.
+func (pp *Publish) payloadLen() int {
+	if pp.PayloadReader != nil {
+		return pp.PayloadLength
+	}
+
+	return len(pp.Message.Payload)
+}
+
 // Returns the payload length.
-func (pp *Publish) len() int {
-	total := 2 + len(pp.Message.Topic) + len(pp.Message.Payload)
+func (pp *Publish) len(version byte) int {
+	total := 2 + len(pp.Message.Topic) + pp.payloadLen()
 	if pp.Message.QOS != 0 {
 		total += 2
 	}
 
+	// add the properties length
+	if version == Version5 {
+		total += propertiesLen(&pp.Properties)
+	}
+
 	return total
 }
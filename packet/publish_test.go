@@ -1,6 +1,7 @@
 package packet
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,7 +27,7 @@ func TestPublishDecode1(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -49,7 +50,7 @@ func TestPublishDecode2(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -68,7 +69,7 @@ func TestPublishDecodeError1(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -80,7 +81,7 @@ func TestPublishDecodeError2(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -93,7 +94,7 @@ func TestPublishDecodeError3(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -108,7 +109,7 @@ func TestPublishDecodeError4(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -124,7 +125,7 @@ func TestPublishDecodeError5(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -141,7 +142,7 @@ func TestPublishDecodeError6(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -166,8 +167,8 @@ func TestPublishEncode1(t *testing.T) {
 	pkt.ID = 7
 	pkt.Message.Payload = []byte("send me home")
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -188,8 +189,8 @@ func TestPublishEncode2(t *testing.T) {
 	pkt.Message.Topic = "gomqtt"
 	pkt.Message.Payload = []byte("send me home")
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -200,8 +201,8 @@ func TestPublishEncodeError1(t *testing.T) {
 	pkt := NewPublish()
 	pkt.Message.Topic = "" // < empty topic
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -211,8 +212,8 @@ func TestPublishEncodeError2(t *testing.T) {
 	pkt.Message.Topic = "t"
 	pkt.Message.QOS = 3 // < wrong qos
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -222,7 +223,7 @@ func TestPublishEncodeError3(t *testing.T) {
 	pkt.Message.Topic = "t"
 
 	dst := make([]byte, 1) // < too small
-	_, err := pkt.Encode(dst)
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -231,8 +232,8 @@ func TestPublishEncodeError4(t *testing.T) {
 	pkt := NewPublish()
 	pkt.Message.Topic = string(make([]byte, 65536)) // < too big
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -243,8 +244,8 @@ func TestPublishEncodeError5(t *testing.T) {
 	pkt.Message.QOS = 1
 	pkt.ID = 0 // < zero packet id
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -262,19 +263,19 @@ func TestPublishEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewPublish()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
 
-	dst := make([]byte, pkt.Len())
-	n2, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n3)
@@ -287,10 +288,10 @@ func BenchmarkPublishEncode(b *testing.B) {
 	pkt.ID = 1
 	pkt.Message.Payload = []byte("p")
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -312,9 +313,98 @@ func BenchmarkPublishDecode(b *testing.B) {
 	pkt := NewPublish()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestPublishEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(PUBLISH<<4) | 2,
+		11,
+		0, // topic name MSB
+		1, // topic name LSB
+		't',
+		0, // packet ID MSB
+		7, // packet ID LSB
+		3, // properties length
+		byte(PropertyTopicAlias),
+		0, // topic alias MSB
+		1, // topic alias LSB
+		'h', 'i',
+	}
+
+	pkt := NewPublish()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, "t", pkt.Message.Topic)
+	assert.Equal(t, uint16(1), *pkt.Properties.TopicAlias)
+	assert.Equal(t, []byte("hi"), pkt.Message.Payload)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	pkt.Message.Topic = ""
+
+	dst = make([]byte, pkt.Len(Version5))
+	_, err = pkt.Encode(Version5, dst)
+	assert.NoError(t, err)
+
+	_, err = pkt.Encode(Version311, dst)
+	assert.Error(t, err)
+}
+
+func TestPublishEncodePayloadReader(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		pkt1 := NewPublish()
+		pkt1.Message.Topic = "t"
+		pkt1.Message.QOS = QOSAtLeastOnce
+		pkt1.Message.Payload = []byte("payload")
+		pkt1.ID = 1
+
+		pkt2 := NewPublish()
+		pkt2.Message.Topic = "t"
+		pkt2.Message.QOS = QOSAtLeastOnce
+		pkt2.PayloadReader = bytes.NewReader([]byte("payload"))
+		pkt2.PayloadLength = 7
+		pkt2.ID = 1
+
+		assert.Equal(t, pkt1.Len(version), pkt2.Len(version))
+		assert.Equal(t, "<Publish ID=1 Message=<Message Topic=\"t\" QOS=1 Retain=false Payload=[]> Dup=false PayloadLength=7>", pkt2.String())
+
+		dst1 := make([]byte, pkt1.Len(version))
+		n1, err := pkt1.Encode(version, dst1)
+		assert.NoError(t, err)
+
+		dst2 := make([]byte, pkt2.Len(version))
+		n2, err := pkt2.Encode(version, dst2)
+		assert.NoError(t, err)
+
+		assert.Equal(t, n1, n2)
+		assert.Equal(t, dst1, dst2)
+	}
+}
+
+func TestPublishEncodePayloadReaderError(t *testing.T) {
+	pkt := NewPublish()
+	pkt.Message.Topic = "t"
+	pkt.PayloadReader = bytes.NewReader([]byte("pay"))
+	pkt.PayloadLength = 7
+
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
+	assert.Error(t, err)
+
+	pkt.PayloadLength = -1
+
+	_, err = pkt.Encode(Version311, make([]byte, 10))
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package packet
+
+// ReasonCode represents a MQTT 5 reason code that indicates the result of an
+// operation.
+type ReasonCode byte
+
+// All available reason codes.
+const (
+	ReasonSuccess                             ReasonCode = 0x00
+	ReasonNormalDisconnection                 ReasonCode = 0x00
+	ReasonGrantedQOS0                         ReasonCode = 0x00
+	ReasonGrantedQOS1                         ReasonCode = 0x01
+	ReasonGrantedQOS2                         ReasonCode = 0x02
+	ReasonDisconnectWithWillMessage           ReasonCode = 0x04
+	ReasonNoMatchingSubscribers               ReasonCode = 0x10
+	ReasonNoSubscriptionExisted               ReasonCode = 0x11
+	ReasonContinueAuthentication              ReasonCode = 0x18
+	ReasonReAuthenticate                      ReasonCode = 0x19
+	ReasonUnspecifiedError                    ReasonCode = 0x80
+	ReasonMalformedPacket                     ReasonCode = 0x81
+	ReasonProtocolError                       ReasonCode = 0x82
+	ReasonImplementationSpecificError         ReasonCode = 0x83
+	ReasonUnsupportedProtocolVersion          ReasonCode = 0x84
+	ReasonClientIdentifierNotValid            ReasonCode = 0x85
+	ReasonBadUsernameOrPassword               ReasonCode = 0x86
+	ReasonNotAuthorized                       ReasonCode = 0x87
+	ReasonServerUnavailable                   ReasonCode = 0x88
+	ReasonServerBusy                          ReasonCode = 0x89
+	ReasonBanned                              ReasonCode = 0x8A
+	ReasonServerShuttingDown                  ReasonCode = 0x8B
+	ReasonBadAuthenticationMethod             ReasonCode = 0x8C
+	ReasonKeepAliveTimeout                    ReasonCode = 0x8D
+	ReasonSessionTakenOver                    ReasonCode = 0x8E
+	ReasonTopicFilterInvalid                  ReasonCode = 0x8F
+	ReasonTopicNameInvalid                    ReasonCode = 0x90
+	ReasonPacketIdentifierInUse               ReasonCode = 0x91
+	ReasonPacketIdentifierNotFound            ReasonCode = 0x92
+	ReasonReceiveMaximumExceeded              ReasonCode = 0x93
+	ReasonTopicAliasInvalid                   ReasonCode = 0x94
+	ReasonPacketTooLarge                      ReasonCode = 0x95
+	ReasonMessageRateTooHigh                  ReasonCode = 0x96
+	ReasonQuotaExceeded                       ReasonCode = 0x97
+	ReasonAdministrativeAction                ReasonCode = 0x98
+	ReasonPayloadFormatInvalid                ReasonCode = 0x99
+	ReasonRetainNotSupported                  ReasonCode = 0x9A
+	ReasonQOSNotSupported                     ReasonCode = 0x9B
+	ReasonUseAnotherServer                    ReasonCode = 0x9C
+	ReasonServerMoved                         ReasonCode = 0x9D
+	ReasonSharedSubscriptionsNotSupported     ReasonCode = 0x9E
+	ReasonConnectionRateExceeded              ReasonCode = 0x9F
+	ReasonMaximumConnectTime                  ReasonCode = 0xA0
+	ReasonSubscriptionIdentifiersNotSupported ReasonCode = 0xA1
+	ReasonWildcardSubscriptionsNotSupported   ReasonCode = 0xA2
+)
+
+// the packets that may carry a reason code
+var reasonTargets = map[ReasonCode]uint32{
+	ReasonSuccess:                             typeMask(CONNACK, PUBACK, PUBREC, PUBREL, PUBCOMP, SUBACK, UNSUBACK, DISCONNECT, AUTH),
+	ReasonGrantedQOS1:                         typeMask(SUBACK),
+	ReasonGrantedQOS2:                         typeMask(SUBACK),
+	ReasonDisconnectWithWillMessage:           typeMask(DISCONNECT),
+	ReasonNoMatchingSubscribers:               typeMask(PUBACK, PUBREC),
+	ReasonNoSubscriptionExisted:               typeMask(UNSUBACK),
+	ReasonContinueAuthentication:              typeMask(AUTH),
+	ReasonReAuthenticate:                      typeMask(AUTH),
+	ReasonUnspecifiedError:                    typeMask(CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT),
+	ReasonMalformedPacket:                     typeMask(CONNACK, DISCONNECT),
+	ReasonProtocolError:                       typeMask(CONNACK, DISCONNECT),
+	ReasonImplementationSpecificError:         typeMask(CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT),
+	ReasonUnsupportedProtocolVersion:          typeMask(CONNACK),
+	ReasonClientIdentifierNotValid:            typeMask(CONNACK),
+	ReasonBadUsernameOrPassword:               typeMask(CONNACK),
+	ReasonNotAuthorized:                       typeMask(CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT),
+	ReasonServerUnavailable:                   typeMask(CONNACK),
+	ReasonServerBusy:                          typeMask(CONNACK, DISCONNECT),
+	ReasonBanned:                              typeMask(CONNACK),
+	ReasonServerShuttingDown:                  typeMask(DISCONNECT),
+	ReasonBadAuthenticationMethod:             typeMask(CONNACK, DISCONNECT),
+	ReasonKeepAliveTimeout:                    typeMask(DISCONNECT),
+	ReasonSessionTakenOver:                    typeMask(DISCONNECT),
+	ReasonTopicFilterInvalid:                  typeMask(SUBACK, UNSUBACK, DISCONNECT),
+	ReasonTopicNameInvalid:                    typeMask(CONNACK, PUBACK, PUBREC, DISCONNECT),
+	ReasonPacketIdentifierInUse:               typeMask(PUBACK, PUBREC, SUBACK, UNSUBACK),
+	ReasonPacketIdentifierNotFound:            typeMask(PUBREL, PUBCOMP),
+	ReasonReceiveMaximumExceeded:              typeMask(DISCONNECT),
+	ReasonTopicAliasInvalid:                   typeMask(DISCONNECT),
+	ReasonPacketTooLarge:                      typeMask(CONNACK, DISCONNECT),
+	ReasonMessageRateTooHigh:                  typeMask(DISCONNECT),
+	ReasonQuotaExceeded:                       typeMask(CONNACK, PUBACK, PUBREC, SUBACK, DISCONNECT),
+	ReasonAdministrativeAction:                typeMask(DISCONNECT),
+	ReasonPayloadFormatInvalid:                typeMask(CONNACK, PUBACK, PUBREC, DISCONNECT),
+	ReasonRetainNotSupported:                  typeMask(CONNACK, DISCONNECT),
+	ReasonQOSNotSupported:                     typeMask(CONNACK, DISCONNECT),
+	ReasonUseAnotherServer:                    typeMask(CONNACK, DISCONNECT),
+	ReasonServerMoved:                         typeMask(CONNACK, DISCONNECT),
+	ReasonSharedSubscriptionsNotSupported:     typeMask(SUBACK, DISCONNECT),
+	ReasonConnectionRateExceeded:              typeMask(CONNACK, DISCONNECT),
+	ReasonMaximumConnectTime:                  typeMask(DISCONNECT),
+	ReasonSubscriptionIdentifiersNotSupported: typeMask(SUBACK, DISCONNECT),
+	ReasonWildcardSubscriptionsNotSupported:   typeMask(SUBACK, DISCONNECT),
+}
+
+// Valid returns whether the reason code is known.
+func (rc ReasonCode) Valid() bool {
+	_, ok := reasonTargets[rc]
+	return ok
+}
+
+// Allowed returns whether the reason code may be carried by the specified
+// packet type.
+func (rc ReasonCode) Allowed(t Type) bool {
+	return reasonTargets[rc]&typeMask(t) != 0
+}
+
+// Failure returns whether the reason code indicates a failure.
+func (rc ReasonCode) Failure() bool {
+	return rc >= 0x80
+}
+
+// String returns the corresponding reason string for the reason code.
+func (rc ReasonCode) String() string {
+	switch rc {
+	case ReasonSuccess:
+		return "success"
+	case ReasonGrantedQOS1:
+		return "granted qos 1"
+	case ReasonGrantedQOS2:
+		return "granted qos 2"
+	case ReasonDisconnectWithWillMessage:
+		return "disconnect with will message"
+	case ReasonNoMatchingSubscribers:
+		return "no matching subscribers"
+	case ReasonNoSubscriptionExisted:
+		return "no subscription existed"
+	case ReasonContinueAuthentication:
+		return "continue authentication"
+	case ReasonReAuthenticate:
+		return "re-authenticate"
+	case ReasonUnspecifiedError:
+		return "unspecified error"
+	case ReasonMalformedPacket:
+		return "malformed packet"
+	case ReasonProtocolError:
+		return "protocol error"
+	case ReasonImplementationSpecificError:
+		return "implementation specific error"
+	case ReasonUnsupportedProtocolVersion:
+		return "unsupported protocol version"
+	case ReasonClientIdentifierNotValid:
+		return "client identifier not valid"
+	case ReasonBadUsernameOrPassword:
+		return "bad user name or password"
+	case ReasonNotAuthorized:
+		return "not authorized"
+	case ReasonServerUnavailable:
+		return "server unavailable"
+	case ReasonServerBusy:
+		return "server busy"
+	case ReasonBanned:
+		return "banned"
+	case ReasonServerShuttingDown:
+		return "server shutting down"
+	case ReasonBadAuthenticationMethod:
+		return "bad authentication method"
+	case ReasonKeepAliveTimeout:
+		return "keep alive timeout"
+	case ReasonSessionTakenOver:
+		return "session taken over"
+	case ReasonTopicFilterInvalid:
+		return "topic filter invalid"
+	case ReasonTopicNameInvalid:
+		return "topic name invalid"
+	case ReasonPacketIdentifierInUse:
+		return "packet identifier in use"
+	case ReasonPacketIdentifierNotFound:
+		return "packet identifier not found"
+	case ReasonReceiveMaximumExceeded:
+		return "receive maximum exceeded"
+	case ReasonTopicAliasInvalid:
+		return "topic alias invalid"
+	case ReasonPacketTooLarge:
+		return "packet too large"
+	case ReasonMessageRateTooHigh:
+		return "message rate too high"
+	case ReasonQuotaExceeded:
+		return "quota exceeded"
+	case ReasonAdministrativeAction:
+		return "administrative action"
+	case ReasonPayloadFormatInvalid:
+		return "payload format invalid"
+	case ReasonRetainNotSupported:
+		return "retain not supported"
+	case ReasonQOSNotSupported:
+		return "qos not supported"
+	case ReasonUseAnotherServer:
+		return "use another server"
+	case ReasonServerMoved:
+		return "server moved"
+	case ReasonSharedSubscriptionsNotSupported:
+		return "shared subscriptions not supported"
+	case ReasonConnectionRateExceeded:
+		return "connection rate exceeded"
+	case ReasonMaximumConnectTime:
+		return "maximum connect time"
+	case ReasonSubscriptionIdentifiersNotSupported:
+		return "subscription identifiers not supported"
+	case ReasonWildcardSubscriptionsNotSupported:
+		return "wildcard subscriptions not supported"
+	}
+
+	return "invalid reason code"
+}
+
+// ConnackCode returns the closest MQTT 3 return code for the reason code.
+func (rc ReasonCode) ConnackCode() ConnackCode {
+	switch rc {
+	case ReasonSuccess:
+		return ConnectionAccepted
+	case ReasonUnsupportedProtocolVersion:
+		return InvalidProtocolVersion
+	case ReasonClientIdentifierNotValid:
+		return IdentifierRejected
+	case ReasonBadUsernameOrPassword:
+		return BadUsernameOrPassword
+	case ReasonNotAuthorized, ReasonBanned, ReasonBadAuthenticationMethod:
+		return NotAuthorized
+	}
+
+	return ServerUnavailable
+}
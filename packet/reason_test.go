@@ -0,0 +1,37 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasonCode(t *testing.T) {
+	assert.Equal(t, "success", ReasonSuccess.String())
+	assert.Equal(t, "not authorized", ReasonNotAuthorized.String())
+	assert.Equal(t, "invalid reason code", ReasonCode(0x03).String())
+
+	assert.True(t, ReasonSuccess.Valid())
+	assert.False(t, ReasonCode(0x03).Valid())
+
+	assert.True(t, ReasonSuccess.Allowed(AUTH))
+	assert.True(t, ReasonNoMatchingSubscribers.Allowed(PUBACK))
+	assert.False(t, ReasonNoMatchingSubscribers.Allowed(PUBREL))
+	assert.False(t, ReasonGrantedQOS1.Allowed(PUBACK))
+	assert.False(t, ReasonCode(0x03).Allowed(DISCONNECT))
+
+	assert.False(t, ReasonSuccess.Failure())
+	assert.False(t, ReasonNoMatchingSubscribers.Failure())
+	assert.True(t, ReasonUnspecifiedError.Failure())
+}
+
+func TestReasonCodeConnackCode(t *testing.T) {
+	for code := ConnectionAccepted; code <= NotAuthorized; code++ {
+		assert.True(t, code.ReasonCode().Allowed(CONNACK))
+		assert.Equal(t, code, code.ReasonCode().ConnackCode())
+	}
+
+	assert.Equal(t, ReasonUnspecifiedError, ConnackCode(6).ReasonCode())
+	assert.Equal(t, NotAuthorized, ReasonBanned.ConnackCode())
+	assert.Equal(t, ServerUnavailable, ReasonServerBusy.ConnackCode())
+}
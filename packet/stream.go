@@ -3,8 +3,11 @@ package packet
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"io"
+	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,29 +22,75 @@ var ErrDetectionOverflow = errors.New("detection overflow")
 // exceeded its read limit.
 var ErrReadLimitExceeded = errors.New("read limit exceeded")
 
+// returns the protocol version of a Connect packet
+func connectVersion(pkt Generic) (byte, bool) {
+	// check packet
+	connect, ok := pkt.(*Connect)
+	if !ok {
+		return 0, false
+	}
+
+	// check version
+	if connect.Version == 0 {
+		return Version311, true
+	}
+
+	return connect.Version, true
+}
+
+// a scatter list of encoded packets used for batch writes
+type scatterList struct {
+	buffers []*Buffer
+	vector  net.Buffers
+}
+
+var scatterListPool = sync.Pool{
+	New: func() interface{} {
+		return &scatterList{}
+	},
+}
+
 // An Encoder wraps a writer and continuously encodes packets.
 type Encoder struct {
-	writer *mercury.Writer
-	buffer bytes.Buffer
+	version uint32
+	raw     io.Writer
+	writer  *mercury.Writer
+	buffer  bytes.Buffer
 }
 
 // NewEncoder creates a new Encoder.
 func NewEncoder(writer io.Writer) *Encoder {
 	return &Encoder{
-		writer: mercury.NewWriter(writer, 0),
+		version: uint32(Version311),
+		raw:     writer,
+		writer:  mercury.NewWriter(writer, 0),
 	}
 }
 
-// Write encodes and writes the passed packet to the write buffer.
+// Write encodes and writes the passed packet to the write buffer. Writing a
+// Connect packet will set the protocol version to the version of the packet.
 func (e *Encoder) Write(pkt Generic, async bool) error {
+	// set version from connect packets
+	if version, ok := connectVersion(pkt); ok {
+		e.SetVersion(version)
+	}
+
+	// get version
+	version := e.Version()
+
+	// stream publish payloads
+	if publish, ok := pkt.(*Publish); ok && publish.PayloadReader != nil {
+		return e.writeStream(publish, version, async)
+	}
+
 	// reset and potentially grow buffer
-	packetLength := pkt.Len()
+	packetLength := pkt.Len(version)
 	e.buffer.Reset()
 	e.buffer.Grow(packetLength)
 	buf := e.buffer.Bytes()[0:packetLength]
 
 	// encode packet
-	_, err := pkt.Encode(buf)
+	_, err := pkt.Encode(version, buf)
 	if err != nil {
 		return err
 	}
@@ -59,6 +108,111 @@ func (e *Encoder) Write(pkt Generic, async bool) error {
 	return nil
 }
 
+// writes the header of the publish packet and copies the payload from the
+// payload reader
+func (e *Encoder) writeStream(publish *Publish, version byte, async bool) error {
+	// reset and potentially grow buffer
+	headerLength := publish.Len(version) - publish.PayloadLength
+	e.buffer.Reset()
+	e.buffer.Grow(headerLength)
+	buf := e.buffer.Bytes()[0:headerLength]
+
+	// encode header
+	_, err := publish.encodeHeader(version, buf)
+	if err != nil {
+		return err
+	}
+
+	// write header
+	_, err = e.writer.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	// acquire copy buffer
+	buffer := AcquireBuffer(streamBufferSize)
+	defer buffer.Release()
+
+	// copy payload
+	n, err := io.CopyBuffer(e.writer, io.LimitReader(publish.PayloadReader, int64(publish.PayloadLength)), buffer.Bytes())
+	if err != nil {
+		return err
+	} else if n < int64(publish.PayloadLength) {
+		return io.ErrUnexpectedEOF
+	}
+
+	// flush if not async
+	if !async {
+		return e.writer.Flush()
+	}
+
+	return nil
+}
+
+// WriteBatch encodes the passed packets into pooled buffers and writes them
+// after any buffered data using a single vectored write if supported by the
+// underlying writer. Writing a Connect packet will set the protocol version
+// for the subsequent packets.
+func (e *Encoder) WriteBatch(pkts []Generic) error {
+	// acquire scatter list
+	list := scatterListPool.Get().(*scatterList)
+	defer releaseScatterList(list)
+
+	// encode packets
+	for _, pkt := range pkts {
+		// set version from connect packets
+		if version, ok := connectVersion(pkt); ok {
+			e.SetVersion(version)
+		}
+
+		// get version
+		version := e.Version()
+
+		// acquire buffer
+		buffer := AcquireBuffer(pkt.Len(version))
+		list.buffers = append(list.buffers, buffer)
+
+		// encode packet
+		_, err := pkt.Encode(version, buffer.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// add to vector
+		list.vector = append(list.vector, buffer.Bytes())
+	}
+
+	// flush buffered data to retain order
+	err := e.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	// write vector (using a copy as writing consumes the vector)
+	vector := list.vector
+	_, err = vector.WriteTo(e.raw)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// releases the buffers and returns the scatter list to the pool
+func releaseScatterList(list *scatterList) {
+	// release buffers
+	for i, buffer := range list.buffers {
+		buffer.Release()
+		list.buffers[i] = nil
+	}
+
+	// reset list
+	list.buffers = list.buffers[:0]
+	list.vector = list.vector[:0]
+
+	scatterListPool.Put(list)
+}
+
 // Flush flushes the writer buffer.
 func (e *Encoder) Flush() error {
 	return e.writer.Flush()
@@ -70,22 +224,80 @@ func (e *Encoder) SetMaxWriteDelay(delay time.Duration) {
 	e.writer.SetMaxDelay(delay)
 }
 
+// SetVersion will set the protocol version used to encode packets.
+func (e *Encoder) SetVersion(version byte) {
+	atomic.StoreUint32(&e.version, uint32(version))
+}
+
+// Version returns the protocol version used to encode packets.
+func (e *Encoder) Version() byte {
+	return byte(atomic.LoadUint32(&e.version))
+}
+
+// the size of the buffer used to copy streamed payloads
+const streamBufferSize = 32 * 1024
+
 // A Decoder wraps a Reader and continuously decodes packets.
 type Decoder struct {
-	limit  int64
-	reader *bufio.Reader
-	buffer bytes.Buffer
+	limit     int64
+	threshold int64
+	version   uint32
+	pooled    uint32
+	policy    atomic.Value
+	reader    *bufio.Reader
+	buffer    bytes.Buffer
+	header    []byte
+	stream    *io.LimitedReader
 }
 
 // NewDecoder returns a new Decoder.
 func NewDecoder(reader io.Reader) *Decoder {
 	return &Decoder{
-		reader: bufio.NewReader(reader),
+		version: uint32(Version311),
+		reader:  bufio.NewReader(reader),
 	}
 }
 
-// Read reads the next packet from the buffered reader.
+// Read reads the next packet from the buffered reader. Reading a Connect packet
+// will set the protocol version to the version of the packet.
 func (d *Decoder) Read() (Generic, error) {
+	// get policy
+	policy := d.ReadPolicy()
+
+	// read packet
+	pkt, err := d.read(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// check policy
+	err = policy.check(pkt)
+	if err != nil {
+		// return pooled packets
+		if atomic.LoadUint32(&d.pooled) == 1 {
+			Release(pkt)
+		}
+
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+// reads the next packet
+func (d *Decoder) read(policy *ReadPolicy) (Generic, error) {
+	// discard the unread payload of a previously streamed packet
+	if d.stream != nil {
+		_, err := d.reader.Discard(int(d.stream.N))
+		d.stream.N = 0
+		d.stream = nil
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
 	// initial detection length
 	detectionLength := 2
 
@@ -115,17 +327,36 @@ func (d *Decoder) Read() (Generic, error) {
 		}
 
 		// check read limit
-		limit := atomic.LoadInt64(&d.limit)
+		limit := policy.limit(packetType, atomic.LoadInt64(&d.limit))
 		if limit > 0 && int64(packetLength) > limit {
 			return nil, ErrReadLimitExceeded
 		}
 
-		// create packet
-		pkt, err := packetType.New()
+		// stream large publish packets
+		threshold := atomic.LoadInt64(&d.threshold)
+		if threshold > 0 && packetType == PUBLISH && int64(packetLength) > threshold {
+			return d.readStream(header[0], detectionLength, packetLength-detectionLength)
+		}
+
+		// check pooling
+		pooled := atomic.LoadUint32(&d.pooled) == 1
+
+		// create or acquire packet
+		var pkt Generic
+		if pooled {
+			pkt, err = Acquire(packetType)
+		} else {
+			pkt, err = packetType.New()
+		}
 		if err != nil {
 			return nil, err
 		}
 
+		// read pooled publish packets into a shared buffer
+		if publish, ok := pkt.(*Publish); ok && pooled {
+			return d.readPublish(publish, packetLength)
+		}
+
 		// reset and eventually grow buffer
 		d.buffer.Reset()
 		d.buffer.Grow(packetLength)
@@ -138,21 +369,207 @@ func (d *Decoder) Read() (Generic, error) {
 		}
 
 		// decode buffer
-		_, err = pkt.Decode(buf)
+		_, err = pkt.Decode(d.Version(), buf)
 		if err != nil {
 			return nil, err
 		}
 
+		// set version from connect packets
+		if version, ok := connectVersion(pkt); ok {
+			d.SetVersion(version)
+		}
+
 		return pkt, nil
 	}
 }
 
+// reads a pooled publish packet and references the payload from a pooled
+// buffer
+func (d *Decoder) readPublish(publish *Publish, packetLength int) (Generic, error) {
+	// acquire buffer
+	buffer := AcquireBuffer(packetLength)
+
+	// read whole packet (will not return EOF)
+	_, err := io.ReadFull(d.reader, buffer.Bytes())
+	if err != nil {
+		buffer.Release()
+		return nil, err
+	}
+
+	// decode buffer (takes over the buffer reference)
+	_, err = publish.decode(d.Version(), buffer.Bytes(), buffer)
+	if err != nil {
+		Release(publish)
+		return nil, err
+	}
+
+	return publish, nil
+}
+
+// reads the header of a publish packet and exposes the payload as a stream
+func (d *Decoder) readStream(typeAndFlags byte, headerLength, remainingLength int) (Generic, error) {
+	// discard fixed header
+	_, err := d.reader.Discard(headerLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// reset header
+	d.header = d.header[:0]
+
+	// read topic length
+	err = d.readHeader(2, remainingLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// get topic and packet id length
+	length := int(binary.BigEndian.Uint16(d.header))
+	if (typeAndFlags>>1)&0x3 != 0 {
+		length += 2
+	}
+
+	// read topic and packet id
+	err = d.readHeader(length, remainingLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// get version
+	version := d.Version()
+
+	// read properties
+	if version == Version5 {
+		// read properties length
+		start := len(d.header)
+		for i := 0; i < 4; i++ {
+			err = d.readHeader(1, remainingLength)
+			if err != nil {
+				return nil, err
+			} else if d.header[len(d.header)-1]&0x80 == 0 {
+				break
+			}
+		}
+
+		// decode properties length
+		length, _, err := readVarint(d.header[start:], PUBLISH)
+		if err != nil {
+			return nil, err
+		}
+
+		// read properties
+		err = d.readHeader(length, remainingLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// prepare packet without payload
+	buf := make([]byte, headerLen(len(d.header))+len(d.header))
+	buf[0] = typeAndFlags
+	n, err := writeVarint(buf[1:], len(d.header), PUBLISH)
+	if err != nil {
+		return nil, err
+	}
+	copy(buf[1+n:], d.header)
+
+	// acquire or create packet
+	var publish *Publish
+	if atomic.LoadUint32(&d.pooled) == 1 {
+		pkt, _ := Acquire(PUBLISH)
+		publish = pkt.(*Publish)
+	} else {
+		publish = NewPublish()
+	}
+
+	// decode packet
+	_, err = publish.Decode(version, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// set payload stream
+	d.stream = &io.LimitedReader{R: d.reader, N: int64(remainingLength - len(d.header))}
+	publish.PayloadReader = d.stream
+	publish.PayloadLength = remainingLength - len(d.header)
+
+	return publish, nil
+}
+
+// reads the specified amount of bytes of the variable header
+func (d *Decoder) readHeader(n int, remainingLength int) error {
+	// check remaining length
+	if len(d.header)+n > remainingLength {
+		return makeError(PUBLISH, "remaining length (%d) is less than the variable header length (%d)", remainingLength, len(d.header)+n)
+	}
+
+	// grow header
+	l := len(d.header)
+	if cap(d.header) < l+n {
+		header := make([]byte, l, l+n)
+		copy(header, d.header)
+		d.header = header
+	}
+	d.header = d.header[:l+n]
+
+	// read bytes
+	_, err := io.ReadFull(d.reader, d.header[l:])
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
 // SetReadLimit will set the read limit. Packets with a length above that limit
 // will cause the ErrReadLimitExceeded error.
 func (d *Decoder) SetReadLimit(limit int64) {
 	atomic.StoreInt64(&d.limit, limit)
 }
 
+// SetReadPolicy will set the read policy that is enforced in addition to the
+// read limit. Violations will cause the ErrReadLimitExceeded,
+// ErrTopicFilterLimitExceeded or ErrTopicLengthExceeded error.
+func (d *Decoder) SetReadPolicy(policy *ReadPolicy) {
+	d.policy.Store(policy)
+}
+
+// ReadPolicy returns the currently set read policy.
+func (d *Decoder) ReadPolicy() *ReadPolicy {
+	policy, _ := d.policy.Load().(*ReadPolicy)
+	return policy
+}
+
+// SetStreamingThreshold will set the packet size above which the payload of
+// Publish packets is not read into memory but exposed as a stream using the
+// PayloadReader of the packet. The payload must be consumed before the next
+// packet is read, otherwise it is discarded. The read limit still applies to
+// streamed packets. A threshold of zero disables streaming.
+func (d *Decoder) SetStreamingThreshold(threshold int64) {
+	atomic.StoreInt64(&d.threshold, threshold)
+}
+
+// SetPooled will set whether packets are acquired from the packet pool. If
+// enabled, the payload of a Publish packet references a pooled buffer instead
+// of being copied. Packets should be returned using Release once processed.
+func (d *Decoder) SetPooled(pooled bool) {
+	if pooled {
+		atomic.StoreUint32(&d.pooled, 1)
+	} else {
+		atomic.StoreUint32(&d.pooled, 0)
+	}
+}
+
+// SetVersion will set the protocol version used to decode packets.
+func (d *Decoder) SetVersion(version byte) {
+	atomic.StoreUint32(&d.version, uint32(version))
+}
+
+// Version returns the protocol version used to decode packets.
+func (d *Decoder) Version() byte {
+	return byte(atomic.LoadUint32(&d.version))
+}
+
 // A Stream combines an Encoder and Decoder
 type Stream struct {
 	*Decoder
@@ -166,3 +583,57 @@ func NewStream(reader io.Reader, writer io.Writer) *Stream {
 		Encoder: NewEncoder(writer),
 	}
 }
+
+// Read reads the next packet from the buffered reader. Reading a Connect packet
+// will set the protocol version of the stream to the version of the packet.
+func (s *Stream) Read() (Generic, error) {
+	// read packet
+	pkt, err := s.Decoder.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// set version from connect packets
+	if version, ok := connectVersion(pkt); ok {
+		s.Encoder.SetVersion(version)
+	}
+
+	return pkt, nil
+}
+
+// Write encodes and writes the passed packet to the write buffer. Writing a
+// Connect packet will set the protocol version of the stream to the version of
+// the packet.
+func (s *Stream) Write(pkt Generic, async bool) error {
+	// set version from connect packets
+	if version, ok := connectVersion(pkt); ok {
+		s.Decoder.SetVersion(version)
+	}
+
+	return s.Encoder.Write(pkt, async)
+}
+
+// WriteBatch encodes and writes the passed packets using a single vectored
+// write. Writing a Connect packet will set the protocol version of the stream
+// to the version of the packet.
+func (s *Stream) WriteBatch(pkts []Generic) error {
+	// set version from connect packets
+	for _, pkt := range pkts {
+		if version, ok := connectVersion(pkt); ok {
+			s.Decoder.SetVersion(version)
+		}
+	}
+
+	return s.Encoder.WriteBatch(pkts)
+}
+
+// SetVersion will set the protocol version used to encode and decode packets.
+func (s *Stream) SetVersion(version byte) {
+	s.Decoder.SetVersion(version)
+	s.Encoder.SetVersion(version)
+}
+
+// Version returns the protocol version used to encode and decode packets.
+func (s *Stream) Version() byte {
+	return s.Encoder.Version()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -50,13 +51,64 @@ func TestEncoderWriterError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestEncoderWriteBatch(t *testing.T) {
+	buf1 := new(bytes.Buffer)
+	enc1 := NewEncoder(buf1)
+
+	buf2 := new(bytes.Buffer)
+	enc2 := NewEncoder(buf2)
+
+	connect := NewConnect()
+	connect.Version = Version5
+
+	publish := NewPublish()
+	publish.Message.Topic = "foo"
+	publish.Message.Payload = []byte("bar")
+	publish.Properties.ContentType = "text/plain"
+
+	for _, pkt := range []Generic{connect, publish, NewPingreq()} {
+		err := enc1.Write(pkt, false)
+		assert.NoError(t, err)
+	}
+
+	err := enc2.Write(connect, true)
+	assert.NoError(t, err)
+
+	err = enc2.WriteBatch([]Generic{publish, NewPingreq()})
+	assert.NoError(t, err)
+
+	assert.Equal(t, buf1.Bytes(), buf2.Bytes())
+	assert.Equal(t, Version5, enc2.Version())
+}
+
+func TestEncoderWriteBatchEncodeError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	pkt := NewConnack()
+	pkt.ReturnCode = 11 // < invalid return code
+
+	err := enc.WriteBatch([]Generic{NewPingreq(), pkt})
+	assert.Error(t, err)
+	assert.Zero(t, buf.Len())
+}
+
+func TestEncoderWriteBatchWriterError(t *testing.T) {
+	enc := NewEncoder(&errorWriter{
+		err: errors.New("foo"),
+	})
+
+	err := enc.WriteBatch([]Generic{NewPingreq()})
+	assert.Error(t, err)
+}
+
 func TestDecoder(t *testing.T) {
 	buf := new(bytes.Buffer)
 	dec := NewDecoder(buf)
 
 	var pkt Generic = NewConnect()
-	b := make([]byte, pkt.Len())
-	_, err := pkt.Encode(b)
+	b := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, b)
 	assert.NoError(t, err)
 	buf.Write(b)
 
@@ -164,3 +216,320 @@ func TestStream(t *testing.T) {
 	assert.NotNil(t, pkt)
 	assert.NoError(t, err)
 }
+
+func TestStreamVersion(t *testing.T) {
+	in := new(bytes.Buffer)
+	out := new(bytes.Buffer)
+
+	s := NewStream(in, out)
+	assert.Equal(t, Version311, s.Version())
+
+	connect := NewConnect()
+	connect.Version = Version5
+
+	err := s.Write(connect, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Version5, s.Version())
+
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Properties.ContentType = "text/plain"
+
+	err = s.Write(publish, false)
+	assert.NoError(t, err)
+
+	err = s.Flush()
+	assert.NoError(t, err)
+
+	s2 := NewStream(out, nil)
+
+	pkt, err := s2.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, connect, pkt)
+	assert.Equal(t, Version5, s2.Version())
+
+	pkt, err = s2.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, publish, pkt)
+}
+
+func TestDecoderPooled(t *testing.T) {
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = []byte("payload")
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	for i := 0; i < 2; i++ {
+		err := enc.Write(publish, false)
+		assert.NoError(t, err)
+	}
+
+	err := enc.Write(NewPingreq(), false)
+	assert.NoError(t, err)
+
+	dec := NewDecoder(buf)
+	dec.SetPooled(true)
+
+	pkt1, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, publish.Message, pkt1.(*Publish).Message)
+	assert.NotNil(t, pkt1.(*Publish).buffer)
+
+	pkt2, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, publish.Message, pkt2.(*Publish).Message)
+
+	Release(pkt1)
+	assert.Equal(t, []byte("payload"), pkt2.(*Publish).Message.Payload)
+	Release(pkt2)
+
+	pkt3, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, NewPingreq(), pkt3)
+	Release(pkt3)
+}
+
+func TestEncoderStream(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		buf1 := new(bytes.Buffer)
+		enc1 := NewEncoder(buf1)
+		enc1.SetVersion(version)
+
+		buf2 := new(bytes.Buffer)
+		enc2 := NewEncoder(buf2)
+		enc2.SetVersion(version)
+
+		publish := NewPublish()
+		publish.Message.Topic = "t"
+		publish.Message.Payload = bytes.Repeat([]byte("x"), 100000)
+		publish.Properties.ContentType = "text/plain"
+
+		err := enc1.Write(publish, false)
+		assert.NoError(t, err)
+
+		publish.PayloadReader = bytes.NewReader(publish.Message.Payload)
+		publish.PayloadLength = len(publish.Message.Payload)
+		publish.Message.Payload = nil
+
+		err = enc2.Write(publish, false)
+		assert.NoError(t, err)
+
+		assert.Equal(t, buf1.Bytes(), buf2.Bytes())
+	}
+}
+
+func TestEncoderStreamShortPayload(t *testing.T) {
+	enc := NewEncoder(ioutil.Discard)
+
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.PayloadReader = bytes.NewReader([]byte("pay"))
+	publish.PayloadLength = 7
+
+	err := enc.Write(publish, false)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecoderStreaming(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf)
+		enc.SetVersion(version)
+
+		small := NewPublish()
+		small.Message.Topic = "s"
+		small.Message.Payload = []byte("small")
+
+		large := NewPublish()
+		large.Message.Topic = "l"
+		large.Message.QOS = QOSAtLeastOnce
+		large.Message.Payload = bytes.Repeat([]byte("x"), 10000)
+		large.ID = 7
+		large.Properties.ContentType = "text/plain"
+
+		for _, pkt := range []Generic{small, large, large, NewPingreq()} {
+			err := enc.Write(pkt, false)
+			assert.NoError(t, err)
+		}
+
+		dec := NewDecoder(buf)
+		dec.SetVersion(version)
+		dec.SetStreamingThreshold(1000)
+
+		pkt, err := dec.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, small, pkt)
+
+		pkt, err = dec.Read()
+		assert.NoError(t, err)
+		publish := pkt.(*Publish)
+		assert.Equal(t, "l", publish.Message.Topic)
+		assert.Equal(t, ID(7), publish.ID)
+		assert.Nil(t, publish.Message.Payload)
+		assert.Equal(t, 10000, publish.PayloadLength)
+
+		payload, err := ioutil.ReadAll(publish.PayloadReader)
+		assert.NoError(t, err)
+		assert.Equal(t, large.Message.Payload, payload)
+
+		if version == Version5 {
+			assert.Equal(t, "text/plain", publish.Properties.ContentType)
+		}
+
+		// partially read payload is discarded
+		pkt, err = dec.Read()
+		assert.NoError(t, err)
+		publish = pkt.(*Publish)
+		_, err = publish.PayloadReader.Read(make([]byte, 10))
+		assert.NoError(t, err)
+
+		pkt, err = dec.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, NewPingreq(), pkt)
+
+		_, err = publish.PayloadReader.Read(make([]byte, 10))
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestDecoderStreamingReadLimit(t *testing.T) {
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = bytes.Repeat([]byte("x"), 100)
+
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf).Write(publish, false)
+	assert.NoError(t, err)
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetStreamingThreshold(10)
+	dec.SetReadLimit(50)
+
+	pkt, err := dec.Read()
+	assert.Nil(t, pkt)
+	assert.Equal(t, ErrReadLimitExceeded, err)
+
+	dec = NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetStreamingThreshold(10)
+	dec.SetReadPolicy(&ReadPolicy{
+		Limits: map[Type]int64{PUBLISH: 50},
+	})
+
+	pkt, err = dec.Read()
+	assert.Nil(t, pkt)
+	assert.Equal(t, ErrReadLimitExceeded, err)
+}
+
+func TestDecoderStreamingUnexpectedEOF(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = bytes.Repeat([]byte("x"), 100)
+
+	err := enc.Write(publish, false)
+	assert.NoError(t, err)
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()[:50]))
+	dec.SetStreamingThreshold(10)
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+
+	_, err = ioutil.ReadAll(pkt.(*Publish).PayloadReader)
+	assert.NoError(t, err)
+
+	_, err = dec.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	dec = NewDecoder(bytes.NewReader(buf.Bytes()[:3]))
+	dec.SetStreamingThreshold(10)
+
+	_, err = dec.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecoderPooledAllocations(t *testing.T) {
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = []byte("payload")
+
+	pktBytes := make([]byte, publish.Len(Version311))
+	_, err := publish.Encode(Version311, pktBytes)
+	assert.NoError(t, err)
+
+	reader := bytes.NewReader(pktBytes)
+
+	dec := NewDecoder(reader)
+	dec.SetPooled(true)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset(pktBytes)
+
+		pkt, err := dec.Read()
+		if err != nil {
+			panic(err)
+		}
+
+		Release(pkt)
+	})
+
+	// only the topic string is allocated
+	assert.True(t, allocs <= 1)
+}
+
+func BenchmarkDecoderPooled(b *testing.B) {
+	publish := NewPublish()
+	publish.Message.Topic = "t"
+	publish.Message.Payload = []byte("payload")
+
+	pktBytes := make([]byte, publish.Len(Version311))
+	_, err := publish.Encode(Version311, pktBytes)
+	if err != nil {
+		panic(err)
+	}
+
+	reader := bytes.NewReader(pktBytes)
+
+	dec := NewDecoder(reader)
+	dec.SetPooled(true)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader.Reset(pktBytes)
+
+		pkt, err := dec.Read()
+		if err != nil {
+			panic(err)
+		}
+
+		Release(pkt)
+	}
+}
+
+func BenchmarkEncoderWriteBatch(b *testing.B) {
+	enc := NewEncoder(ioutil.Discard)
+
+	pkts := make([]Generic, 0, 16)
+	for i := 0; i < 16; i++ {
+		publish := NewPublish()
+		publish.Message.Topic = "foo/bar/baz"
+		publish.Message.Payload = make([]byte, 256)
+		pkts = append(pkts, publish)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := enc.WriteBatch(pkts)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
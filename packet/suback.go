@@ -10,11 +10,15 @@ import (
 // processing of a Subscribe packet. The Suback packet contains a list of return
 // codes, that specify the maximum QOS levels that have been granted.
 type Suback struct {
-	// The granted QOS levels for the requested subscriptions.
+	// The granted QOS levels for the requested subscriptions. In MQTT 5 the
+	// list may also contain any reason code that is allowed for a Suback.
 	ReturnCodes []QOS
 
 	// The packet identifier.
 	ID ID
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewSuback creates a new Suback packet.
@@ -35,21 +39,28 @@ func (sp *Suback) String() string {
 		codes = append(codes, fmt.Sprintf("%d", c))
 	}
 
-	return fmt.Sprintf("<Suback ID=%d ReturnCodes=[%s]>",
-		sp.ID, strings.Join(codes, ", "))
+	// prepare properties
+	properties := ""
+	if !sp.Properties.Empty() {
+		properties = " Properties=" + sp.Properties.String()
+	}
+
+	return fmt.Sprintf("<Suback ID=%d ReturnCodes=[%s]%s>",
+		sp.ID, strings.Join(codes, ", "), properties)
 }
 
 // Len returns the byte length of the encoded packet.
-func (sp *Suback) Len() int {
-	ml := sp.len()
+func (sp *Suback) Len(version byte) int {
+	ml := sp.len(version)
 	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (sp *Suback) Decode(src []byte) (int, error) {
+func (sp *Suback) Decode(version byte, src []byte) (int, error) {
 	// decode header
-	total, _, rl, err := headerDecode(src, SUBACK)
+	hl, _, rl, err := headerDecode(src, SUBACK)
+	total := hl
 	if err != nil {
 		return total, err
 	}
@@ -73,8 +84,22 @@ func (sp *Suback) Decode(src []byte) (int, error) {
 		return total, makeError(sp.Type(), "packet id must be grater than zero")
 	}
 
+	// read properties
+	if version == Version5 {
+		n, err := sp.Properties.decode(src[total:hl+rl], sp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// calculate number of return codes
-	rcl := rl - 2
+	rcl := rl - (total - hl)
+
+	// check return codes
+	if rcl <= 0 {
+		return total, makeError(sp.Type(), "missing return codes")
+	}
 
 	// read return codes
 	sp.ReturnCodes = make([]QOS, rcl)
@@ -85,7 +110,7 @@ func (sp *Suback) Decode(src []byte) (int, error) {
 
 	// validate return codes
 	for i, code := range sp.ReturnCodes {
-		if !code.Successful() && code != QOSFailure {
+		if !validSubackCode(version, code) {
 			return total, makeError(sp.Type(), "invalid return code %d for topic %d", code, i)
 		}
 	}
@@ -96,10 +121,10 @@ func (sp *Suback) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (sp *Suback) Encode(dst []byte) (int, error) {
+func (sp *Suback) Encode(version byte, dst []byte) (int, error) {
 	// check return codes
 	for i, code := range sp.ReturnCodes {
-		if !code.Successful() && code != QOSFailure {
+		if !validSubackCode(version, code) {
 			return 0, makeError(sp.Type(), "invalid return code %d for topic %d", code, i)
 		}
 	}
@@ -110,7 +135,7 @@ func (sp *Suback) Encode(dst []byte) (int, error) {
 	}
 
 	// encode header
-	total, err := headerEncode(dst, 0, sp.len(), sp.Len(), SUBACK)
+	total, err := headerEncode(dst, 0, sp.len(version), sp.Len(version), SUBACK)
 	if err != nil {
 		return total, err
 	}
@@ -119,6 +144,15 @@ func (sp *Suback) Encode(dst []byte) (int, error) {
 	binary.BigEndian.PutUint16(dst[total:], uint16(sp.ID))
 	total += 2
 
+	// write properties
+	if version == Version5 {
+		n, err := sp.Properties.encode(dst[total:], sp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// write return codes
 	for _, rc := range sp.ReturnCodes {
 		dst[total] = byte(rc)
@@ -129,6 +163,24 @@ func (sp *Suback) Encode(dst []byte) (int, error) {
 }
 
 // Returns the payload length.
-func (sp *Suback) len() int {
-	return 2 + len(sp.ReturnCodes)
+func (sp *Suback) len(version byte) int {
+	// add packet id and return codes
+	total := 2 + len(sp.ReturnCodes)
+
+	// add the properties length
+	if version == Version5 {
+		total += propertiesLen(&sp.Properties)
+	}
+
+	return total
+}
+
+// returns whether the return code is valid for the specified version
+func validSubackCode(version byte, code QOS) bool {
+	// check reason code
+	if version == Version5 {
+		return ReasonCode(code).Allowed(SUBACK)
+	}
+
+	return code.Successful() || code == QOSFailure
 }
@@ -27,7 +27,7 @@ func TestSubackDecode(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -44,7 +44,7 @@ func TestSubackDecodeError1(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -62,7 +62,7 @@ func TestSubackDecodeError2(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -75,7 +75,7 @@ func TestSubackDecodeError3(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -90,7 +90,7 @@ func TestSubackDecodeError4(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -105,7 +105,7 @@ func TestSubackDecodeError5(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -127,7 +127,7 @@ func TestSubackEncode(t *testing.T) {
 	pkt.ReturnCodes = []QOS{0, 1, 2, 0x80}
 
 	dst := make([]byte, 10)
-	n, err := pkt.Encode(dst)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -139,8 +139,8 @@ func TestSubackEncodeError1(t *testing.T) {
 	pkt.ID = 7
 	pkt.ReturnCodes = []QOS{0x81}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -151,8 +151,8 @@ func TestSubackEncodeError2(t *testing.T) {
 	pkt.ID = 7
 	pkt.ReturnCodes = []QOS{0x80}
 
-	dst := make([]byte, pkt.Len()-1)
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311)-1)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -163,8 +163,8 @@ func TestSubackEncodeError3(t *testing.T) {
 	pkt.ID = 0 // < zero packet id
 	pkt.ReturnCodes = []QOS{0x80}
 
-	dst := make([]byte, pkt.Len()-1)
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311)-1)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -183,19 +183,19 @@ func TestSubackEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewSuback()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
 
 	dst := make([]byte, 100)
-	n2, err := pkt.Encode(dst)
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n3)
@@ -206,10 +206,10 @@ func BenchmarkSubackEncode(b *testing.B) {
 	pkt.ID = 1
 	pkt.ReturnCodes = []QOS{0}
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -228,9 +228,57 @@ func BenchmarkSubackDecode(b *testing.B) {
 	pkt := NewSuback()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestSubackEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(SUBACK << 4),
+		5,
+		0,    // packet ID MSB
+		7,    // packet ID LSB
+		0,    // properties length
+		1,    // return code 1
+		0x80, // return code 2
+	}
+
+	pkt := NewSuback()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, []QOS{QOSAtLeastOnce, QOSFailure}, pkt.ReturnCodes)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+}
+
+func TestSubackReasonCodesVersion5(t *testing.T) {
+	pkt := NewSuback()
+	pkt.ID = 7
+	pkt.ReturnCodes = []QOS{QOSAtLeastOnce, QOS(ReasonNotAuthorized)}
+
+	dst := make([]byte, pkt.Len(Version5))
+	n, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(dst), n)
+
+	pkt2 := NewSuback()
+	_, err = pkt2.Decode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.ReturnCodes, pkt2.ReturnCodes)
+
+	dst = make([]byte, pkt.Len(Version311))
+	_, err = pkt.Encode(Version311, dst)
+	assert.Error(t, err)
+}
@@ -13,10 +13,50 @@ type Subscription struct {
 
 	// The requested maximum QOS level.
 	QOS QOS
+
+	// If set, messages published by the subscribing client are not forwarded
+	// to it (MQTT 5 only).
+	NoLocal bool
+
+	// If set, forwarded messages keep the retain flag they were published
+	// with (MQTT 5 only).
+	RetainAsPublished bool
+
+	// Controls when retained messages are sent: 0 at the time of the
+	// subscribe, 1 only if the subscription does not currently exist and 2 not
+	// at all (MQTT 5 only).
+	RetainHandling byte
 }
 
 func (s *Subscription) String() string {
-	return fmt.Sprintf("%q=>%d", s.Topic, s.QOS)
+	// check options
+	if !s.NoLocal && !s.RetainAsPublished && s.RetainHandling == 0 {
+		return fmt.Sprintf("%q=>%d", s.Topic, s.QOS)
+	}
+
+	return fmt.Sprintf("%q=>%d(NoLocal=%t RetainAsPublished=%t RetainHandling=%d)",
+		s.Topic, s.QOS, s.NoLocal, s.RetainAsPublished, s.RetainHandling)
+}
+
+// returns the subscription options byte
+func (s *Subscription) options(version byte) byte {
+	// set qos
+	options := byte(s.QOS)
+
+	// set mqtt 5 options
+	if version == Version5 {
+		if s.NoLocal {
+			options |= 0x4 // 00000100
+		}
+
+		if s.RetainAsPublished {
+			options |= 0x8 // 00001000
+		}
+
+		options |= s.RetainHandling << 4
+	}
+
+	return options
 }
 
 // A Subscribe packet is sent from the client to the server to create one or
@@ -28,6 +68,9 @@ type Subscribe struct {
 
 	// The packet identifier.
 	ID ID
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewSubscribe creates a new Subscribe packet.
@@ -48,21 +91,28 @@ func (sp *Subscribe) String() string {
 		subscriptions = append(subscriptions, t.String())
 	}
 
-	return fmt.Sprintf("<Subscribe ID=%d Subscriptions=[%s]>",
-		sp.ID, strings.Join(subscriptions, ", "))
+	// prepare properties
+	properties := ""
+	if !sp.Properties.Empty() {
+		properties = " Properties=" + sp.Properties.String()
+	}
+
+	return fmt.Sprintf("<Subscribe ID=%d Subscriptions=[%s]%s>",
+		sp.ID, strings.Join(subscriptions, ", "), properties)
 }
 
 // Len returns the byte length of the encoded packet.
-func (sp *Subscribe) Len() int {
-	ml := sp.len()
+func (sp *Subscribe) Len(version byte) int {
+	ml := sp.len(version)
 	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (sp *Subscribe) Decode(src []byte) (int, error) {
+func (sp *Subscribe) Decode(version byte, src []byte) (int, error) {
 	// decode header
-	total, _, rl, err := headerDecode(src, SUBSCRIBE)
+	hl, _, rl, err := headerDecode(src, SUBSCRIBE)
+	total := hl
 	if err != nil {
 		return total, err
 	}
@@ -81,11 +131,25 @@ func (sp *Subscribe) Decode(src []byte) (int, error) {
 		return total, makeError(sp.Type(), "packet id must be grater than zero")
 	}
 
+	// read properties
+	if version == Version5 {
+		// check remaining length
+		if total > hl+rl {
+			return total, makeError(sp.Type(), "remaining length (%d) is less than the variable header length (%d)", rl, total-hl)
+		}
+
+		n, err := sp.Properties.decode(src[total:hl+rl], sp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// reset subscriptions
 	sp.Subscriptions = sp.Subscriptions[:0]
 
 	// calculate number of subscriptions
-	sl := rl - 2
+	sl := rl - (total - hl)
 
 	// read subscriptions
 	for sl > 0 {
@@ -101,14 +165,38 @@ func (sp *Subscribe) Decode(src []byte) (int, error) {
 			return total, makeError(sp.Type(), "insufficient buffer size, expected %d, got %d", total+1, len(src))
 		}
 
+		// read options
+		options := src[total]
+
+		// check reserved bits
+		if version != Version5 && options&0xfc != 0 || version == Version5 && options&0xc0 != 0 {
+			return total, makeError(sp.Type(), "invalid subscription options (%d)", options)
+		}
+
 		// read qos
-		qos := QOS(src[total])
+		qos := QOS(options & 0x3)
 		if !qos.Successful() {
 			return total, makeError(sp.Type(), "invalid QOS level (%d)", qos)
 		}
 
-		// read qos and add subscription
-		sp.Subscriptions = append(sp.Subscriptions, Subscription{t, qos})
+		// read mqtt 5 options
+		noLocal := options&0x4 != 0
+		retainAsPublished := options&0x8 != 0
+		retainHandling := (options >> 4) & 0x3
+
+		// check retain handling
+		if retainHandling > 2 {
+			return total, makeError(sp.Type(), "invalid retain handling (%d)", retainHandling)
+		}
+
+		// add subscription
+		sp.Subscriptions = append(sp.Subscriptions, Subscription{
+			Topic:             t,
+			QOS:               qos,
+			NoLocal:           noLocal,
+			RetainAsPublished: retainAsPublished,
+			RetainHandling:    retainHandling,
+		})
 		total++
 
 		// decrement counter
@@ -126,14 +214,14 @@ func (sp *Subscribe) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (sp *Subscribe) Encode(dst []byte) (int, error) {
+func (sp *Subscribe) Encode(version byte, dst []byte) (int, error) {
 	// check packet id
 	if !sp.ID.Valid() {
 		return 0, makeError(sp.Type(), "packet id must be grater than zero")
 	}
 
 	// encode header
-	total, err := headerEncode(dst, 0, sp.len(), sp.Len(), SUBSCRIBE)
+	total, err := headerEncode(dst, 0, sp.len(version), sp.Len(version), SUBSCRIBE)
 	if err != nil {
 		return total, err
 	}
@@ -142,6 +230,15 @@ func (sp *Subscribe) Encode(dst []byte) (int, error) {
 	binary.BigEndian.PutUint16(dst[total:], uint16(sp.ID))
 	total += 2
 
+	// write properties
+	if version == Version5 {
+		n, err := sp.Properties.encode(dst[total:], sp.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// write subscriptions
 	for _, t := range sp.Subscriptions {
 		// write topic
@@ -156,8 +253,13 @@ func (sp *Subscribe) Encode(dst []byte) (int, error) {
 			return total, makeError(sp.Type(), "invalid QOS level (%d)", t.QOS)
 		}
 
-		// write qos
-		dst[total] = byte(t.QOS)
+		// check retain handling
+		if t.RetainHandling > 2 {
+			return total, makeError(sp.Type(), "invalid retain handling (%d)", t.RetainHandling)
+		}
+
+		// write options
+		dst[total] = t.options(version)
 		total++
 	}
 
@@ -165,10 +267,15 @@ func (sp *Subscribe) Encode(dst []byte) (int, error) {
 }
 
 // Returns the payload length.
-func (sp *Subscribe) len() int {
+func (sp *Subscribe) len(version byte) int {
 	// packet ID
 	total := 2
 
+	// add the properties length
+	if version == Version5 {
+		total += propertiesLen(&sp.Properties)
+	}
+
 	// add subscriptions
 	for _, t := range sp.Subscriptions {
 		total += 2 + len(t.Topic) + 1
@@ -38,7 +38,7 @@ func TestSubscribeDecode(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -58,7 +58,7 @@ func TestSubscribeDecodeError1(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -71,7 +71,7 @@ func TestSubscribeDecodeError2(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -86,7 +86,7 @@ func TestSubscribeDecodeError3(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -103,7 +103,7 @@ func TestSubscribeDecodeError4(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -121,7 +121,7 @@ func TestSubscribeDecodeError5(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -139,7 +139,7 @@ func TestSubscribeDecodeError6(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -157,7 +157,7 @@ func TestSubscribeDecodeError7(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -185,13 +185,13 @@ func TestSubscribeEncode(t *testing.T) {
 	pkt := NewSubscribe()
 	pkt.ID = 7
 	pkt.Subscriptions = []Subscription{
-		{"gomqtt", 0},
-		{"/a/b/#/c", 1},
-		{"/a/b/#/cdd", 2},
+		{Topic: "gomqtt", QOS: 0},
+		{Topic: "/a/b/#/c", QOS: 1},
+		{Topic: "/a/b/#/cdd", QOS: 2},
 	}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -203,7 +203,7 @@ func TestSubscribeEncodeError1(t *testing.T) {
 	pkt.ID = 7
 
 	dst := make([]byte, 1) // < too small
-	_, err := pkt.Encode(dst)
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -212,11 +212,11 @@ func TestSubscribeEncodeError2(t *testing.T) {
 	pkt := NewSubscribe()
 	pkt.ID = 7
 	pkt.Subscriptions = []Subscription{
-		{string(make([]byte, 65536)), 0}, // too big
+		{Topic: string(make([]byte, 65536)), QOS: 0}, // too big
 	}
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -225,8 +225,8 @@ func TestSubscribeEncodeError3(t *testing.T) {
 	pkt := NewSubscribe()
 	pkt.ID = 0 // < zero packet id
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -235,11 +235,11 @@ func TestSubscribeEncodeError4(t *testing.T) {
 	pkt := NewSubscribe()
 	pkt.ID = 7
 	pkt.Subscriptions = []Subscription{
-		{string(make([]byte, 10)), 0x81}, // invalid qos
+		{Topic: string(make([]byte, 10)), QOS: 0x81}, // invalid qos
 	}
 
-	dst := make([]byte, pkt.Len())
-	_, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	_, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 }
@@ -265,19 +265,19 @@ func TestSubscribeEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewSubscribe()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
 
-	dst := make([]byte, pkt.Len())
-	n2, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n3)
@@ -287,13 +287,13 @@ func BenchmarkSubscribeEncode(b *testing.B) {
 	pkt := NewSubscribe()
 	pkt.ID = 7
 	pkt.Subscriptions = []Subscription{
-		{"t", 0},
+		{Topic: "t", QOS: 0},
 	}
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -315,9 +315,45 @@ func BenchmarkSubscribeDecode(b *testing.B) {
 	pkt := NewSubscribe()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestSubscribeEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(SUBSCRIBE<<4) | 2,
+		11,
+		0, // packet ID MSB
+		7, // packet ID LSB
+		2, // properties length
+		byte(PropertySubscriptionIdentifier),
+		5, // subscription identifier
+		0, // topic name MSB
+		3, // topic name LSB
+		'a', '/', 'b',
+		0x2d, // options
+	}
+
+	pkt := NewSubscribe()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, []uint32{5}, pkt.Properties.SubscriptionIdentifiers)
+	assert.Equal(t, []Subscription{
+		{Topic: "a/b", QOS: QOSAtLeastOnce, NoLocal: true, RetainAsPublished: true, RetainHandling: 2},
+	}, pkt.Subscriptions)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	_, err = pkt.Decode(Version311, pktBytes)
+	assert.Error(t, err)
+}
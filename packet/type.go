@@ -25,6 +25,7 @@ const (
 	PINGREQ
 	PINGRESP
 	DISCONNECT
+	AUTH
 )
 
 // String returns the type as a string.
@@ -58,6 +59,8 @@ func (t Type) String() string {
 		return "Pingresp"
 	case DISCONNECT:
 		return "Disconnect"
+	case AUTH:
+		return "Auth"
 	}
 
 	return "Unknown"
@@ -93,6 +96,8 @@ func (t Type) defaultFlags() byte {
 		return 0
 	case DISCONNECT:
 		return 0
+	case AUTH:
+		return 0
 	}
 
 	return 0
@@ -130,6 +135,8 @@ func (t Type) New() (Generic, error) {
 		return NewPingresp(), nil
 	case DISCONNECT:
 		return NewDisconnect(), nil
+	case AUTH:
+		return NewAuth(), nil
 	}
 
 	return nil, ErrInvalidPacketType
@@ -137,5 +144,5 @@ func (t Type) New() (Generic, error) {
 
 // Valid returns a boolean indicating whether the type is valid or not.
 func (t Type) Valid() bool {
-	return t >= CONNECT && t <= DISCONNECT
+	return t >= CONNECT && t <= AUTH
 }
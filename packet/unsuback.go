@@ -0,0 +1,175 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// An Unsuback packet is sent by the server to the client to confirm receipt of
+// an Unsubscribe packet.
+type Unsuback struct {
+	// Shared packet identifier.
+	ID ID
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
+
+	// The reason codes for the unsubscribed topics (MQTT 5 only).
+	ReasonCodes []ReasonCode
+}
+
+// NewUnsuback creates a new Unsuback packet.
+func NewUnsuback() *Unsuback {
+	return &Unsuback{}
+}
+
+// Type returns the packets type.
+func (up *Unsuback) Type() Type {
+	return UNSUBACK
+}
+
+// Len returns the byte length of the encoded packet.
+func (up *Unsuback) Len(version byte) int {
+	ml := up.len(version)
+	return headerLen(ml) + ml
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (up *Unsuback) Decode(version byte, src []byte) (int, error) {
+	// decode identified packet
+	if version != Version5 {
+		n, pid, err := identifiedDecode(src, UNSUBACK, version, nil, nil)
+		up.ID = pid
+		return n, err
+	}
+
+	// decode header
+	hl, _, rl, err := headerDecode(src, UNSUBACK)
+	total := hl
+	if err != nil {
+		return total, err
+	}
+
+	// check remaining length
+	if rl < 3 {
+		return total, makeError(up.Type(), "expected remaining length to be greater than 2, got %d", rl)
+	}
+
+	// read packet id
+	up.ID = ID(binary.BigEndian.Uint16(src[total:]))
+	total += 2
+
+	// check packet id
+	if !up.ID.Valid() {
+		return total, makeError(up.Type(), "packet id must be grater than zero")
+	}
+
+	// read properties
+	n, err := up.Properties.decode(src[total:hl+rl], up.Type(), false)
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	// check remaining buffer
+	rcl := hl + rl - total
+	if rcl <= 0 {
+		return total, makeError(up.Type(), "missing reason codes")
+	}
+
+	// read reason codes
+	up.ReasonCodes = make([]ReasonCode, rcl)
+	for i := range up.ReasonCodes {
+		// read reason code
+		up.ReasonCodes[i] = ReasonCode(src[total])
+		total++
+
+		// check reason code
+		if !up.ReasonCodes[i].Allowed(UNSUBACK) {
+			return total, makeError(up.Type(), "invalid reason code (%d)", up.ReasonCodes[i])
+		}
+	}
+
+	return total, nil
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (up *Unsuback) Encode(version byte, dst []byte) (int, error) {
+	// encode identified packet
+	if version != Version5 {
+		return identifiedEncode(dst, up.ID, UNSUBACK, version, ReasonSuccess, nil)
+	}
+
+	// check packet id
+	if !up.ID.Valid() {
+		return 0, makeError(up.Type(), "packet id must be grater than zero")
+	}
+
+	// check reason codes
+	if len(up.ReasonCodes) == 0 {
+		return 0, makeError(up.Type(), "missing reason codes")
+	}
+	for _, rc := range up.ReasonCodes {
+		if !rc.Allowed(UNSUBACK) {
+			return 0, makeError(up.Type(), "invalid reason code (%d)", rc)
+		}
+	}
+
+	// encode header
+	total, err := headerEncode(dst, 0, up.len(version), up.Len(version), UNSUBACK)
+	if err != nil {
+		return total, err
+	}
+
+	// write packet id
+	binary.BigEndian.PutUint16(dst[total:], uint16(up.ID))
+	total += 2
+
+	// write properties
+	n, err := up.Properties.encode(dst[total:], up.Type(), false)
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	// write reason codes
+	for _, rc := range up.ReasonCodes {
+		dst[total] = byte(rc)
+		total++
+	}
+
+	return total, nil
+}
+
+// String returns a string representation of the packet.
+func (up *Unsuback) String() string {
+	// prepare properties
+	properties := ""
+	if !up.Properties.Empty() {
+		properties = " Properties=" + up.Properties.String()
+	}
+
+	// prepare reason codes
+	reasonCodes := ""
+	if len(up.ReasonCodes) > 0 {
+		reasonCodes = fmt.Sprintf(" ReasonCodes=%d", up.ReasonCodes)
+	}
+
+	return fmt.Sprintf("<Unsuback ID=%d%s%s>", up.ID, properties, reasonCodes)
+}
+
+// Returns the payload length.
+func (up *Unsuback) len(version byte) int {
+	// packet ID
+	total := 2
+
+	// add the properties length and reason codes
+	if version == Version5 {
+		total += propertiesLen(&up.Properties) + len(up.ReasonCodes)
+	}
+
+	return total
+}
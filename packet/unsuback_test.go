@@ -0,0 +1,102 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsubackInterface(t *testing.T) {
+	pkt := NewUnsuback()
+	pkt.ID = 7
+	pkt.ReasonCodes = []ReasonCode{ReasonSuccess, ReasonNoSubscriptionExisted}
+
+	assert.Equal(t, pkt.Type(), UNSUBACK)
+	assert.Equal(t, "<Unsuback ID=7 ReasonCodes=[0 17]>", pkt.String())
+}
+
+func TestUnsubackDecodeError1(t *testing.T) {
+	pktBytes := []byte{
+		byte(UNSUBACK << 4),
+		3,
+		0, // packet ID MSB
+		7, // packet ID LSB
+		0, // properties length
+		// < missing reason codes
+	}
+
+	pkt := NewUnsuback()
+	_, err := pkt.Decode(Version5, pktBytes)
+
+	assert.Error(t, err)
+}
+
+func TestUnsubackDecodeError2(t *testing.T) {
+	pktBytes := []byte{
+		byte(UNSUBACK << 4),
+		4,
+		0,    // packet ID MSB
+		7,    // packet ID LSB
+		0,    // properties length
+		0x10, // < invalid reason code
+	}
+
+	pkt := NewUnsuback()
+	_, err := pkt.Decode(Version5, pktBytes)
+
+	assert.Error(t, err)
+}
+
+func TestUnsubackEncodeError1(t *testing.T) {
+	pkt := NewUnsuback()
+	pkt.ID = 7 // < missing reason codes
+
+	dst := make([]byte, pkt.Len(Version5))
+	_, err := pkt.Encode(Version5, dst)
+
+	assert.Error(t, err)
+}
+
+func TestUnsubackEncodeError2(t *testing.T) {
+	pkt := NewUnsuback()
+	pkt.ID = 7
+	pkt.ReasonCodes = []ReasonCode{ReasonGrantedQOS1} // < invalid reason code
+
+	dst := make([]byte, pkt.Len(Version5))
+	_, err := pkt.Encode(Version5, dst)
+
+	assert.Error(t, err)
+}
+
+func TestUnsubackEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(UNSUBACK << 4),
+		5,
+		0,    // packet ID MSB
+		7,    // packet ID LSB
+		0,    // properties length
+		0x00, // reason code 1
+		0x11, // reason code 2
+	}
+
+	pkt := NewUnsuback()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, ID(7), pkt.ID)
+	assert.Equal(t, []ReasonCode{ReasonSuccess, ReasonNoSubscriptionExisted}, pkt.ReasonCodes)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+
+	dst = make([]byte, pkt.Len(Version311))
+	n3, err := pkt.Encode(Version311, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n3)
+}
@@ -13,6 +13,9 @@ type Unsubscribe struct {
 
 	// The packet identifier.
 	ID ID
+
+	// The properties of the packet (MQTT 5 only).
+	Properties Properties
 }
 
 // NewUnsubscribe creates a new Unsubscribe packet.
@@ -33,21 +36,28 @@ func (up *Unsubscribe) String() string {
 		topics = append(topics, fmt.Sprintf("%q", t))
 	}
 
-	return fmt.Sprintf("<Unsubscribe Topics=[%s]>",
-		strings.Join(topics, ", "))
+	// prepare properties
+	properties := ""
+	if !up.Properties.Empty() {
+		properties = " Properties=" + up.Properties.String()
+	}
+
+	return fmt.Sprintf("<Unsubscribe Topics=[%s]%s>",
+		strings.Join(topics, ", "), properties)
 }
 
 // Len returns the byte length of the encoded packet.
-func (up *Unsubscribe) Len() int {
-	ml := up.len()
+func (up *Unsubscribe) Len(version byte) int {
+	ml := up.len(version)
 	return headerLen(ml) + ml
 }
 
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
-func (up *Unsubscribe) Decode(src []byte) (int, error) {
+func (up *Unsubscribe) Decode(version byte, src []byte) (int, error) {
 	// decode header
-	total, _, rl, err := headerDecode(src, UNSUBSCRIBE)
+	hl, _, rl, err := headerDecode(src, UNSUBSCRIBE)
+	total := hl
 	if err != nil {
 		return total, err
 	}
@@ -66,8 +76,22 @@ func (up *Unsubscribe) Decode(src []byte) (int, error) {
 		return total, makeError(up.Type(), "packet id must be grater than zero")
 	}
 
+	// read properties
+	if version == Version5 {
+		// check remaining length
+		if total > hl+rl {
+			return total, makeError(up.Type(), "remaining length (%d) is less than the variable header length (%d)", rl, total-hl)
+		}
+
+		n, err := up.Properties.decode(src[total:hl+rl], up.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// prepare counter
-	tl := rl - 2
+	tl := rl - (total - hl)
 
 	// reset topics
 	up.Topics = up.Topics[:0]
@@ -99,14 +123,14 @@ func (up *Unsubscribe) Decode(src []byte) (int, error) {
 // Encode writes the packet bytes into the byte slice from the argument. It
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
-func (up *Unsubscribe) Encode(dst []byte) (int, error) {
+func (up *Unsubscribe) Encode(version byte, dst []byte) (int, error) {
 	// check packet id
 	if !up.ID.Valid() {
 		return 0, makeError(up.Type(), "packet id must be grater than zero")
 	}
 
 	// encode header
-	total, err := headerEncode(dst, 0, up.len(), up.Len(), UNSUBSCRIBE)
+	total, err := headerEncode(dst, 0, up.len(version), up.Len(version), UNSUBSCRIBE)
 	if err != nil {
 		return total, err
 	}
@@ -115,6 +139,15 @@ func (up *Unsubscribe) Encode(dst []byte) (int, error) {
 	binary.BigEndian.PutUint16(dst[total:], uint16(up.ID))
 	total += 2
 
+	// write properties
+	if version == Version5 {
+		n, err := up.Properties.encode(dst[total:], up.Type(), false)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
 	// write topics
 	for _, t := range up.Topics {
 		// write topic
@@ -129,10 +162,15 @@ func (up *Unsubscribe) Encode(dst []byte) (int, error) {
 }
 
 // Returns the payload length.
-func (up *Unsubscribe) len() int {
+func (up *Unsubscribe) len(version byte) int {
 	// packet ID
 	total := 2
 
+	// add the properties length
+	if version == Version5 {
+		total += propertiesLen(&up.Properties)
+	}
+
 	// add topics
 	for _, t := range up.Topics {
 		total += 2 + len(t)
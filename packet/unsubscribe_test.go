@@ -32,7 +32,7 @@ func TestUnsubscribeDecode(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -52,7 +52,7 @@ func TestUnsubscribeDecodeError1(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -66,7 +66,7 @@ func TestUnsubscribeDecodeError2(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -79,7 +79,7 @@ func TestUnsubscribeDecodeError3(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -96,7 +96,7 @@ func TestUnsubscribeDecodeError4(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -113,7 +113,7 @@ func TestUnsubscribeDecodeError5(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	_, err := pkt.Decode(pktBytes)
+	_, err := pkt.Decode(Version311, pktBytes)
 
 	assert.Error(t, err)
 }
@@ -144,7 +144,7 @@ func TestUnsubscribeEncode(t *testing.T) {
 	}
 
 	dst := make([]byte, 100)
-	n, err := pkt.Encode(dst)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
@@ -157,7 +157,7 @@ func TestUnsubscribeEncodeError1(t *testing.T) {
 	pkt.Topics = []string{"gomqtt"}
 
 	dst := make([]byte, 1) // < too small
-	n, err := pkt.Encode(dst)
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -168,8 +168,8 @@ func TestUnsubscribeEncodeError2(t *testing.T) {
 	pkt.ID = 7
 	pkt.Topics = []string{string(make([]byte, 65536))}
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 6, n)
@@ -179,8 +179,8 @@ func TestUnsubscribeEncodeError3(t *testing.T) {
 	pkt := NewUnsubscribe()
 	pkt.ID = 0 // < zero packet id
 
-	dst := make([]byte, pkt.Len())
-	n, err := pkt.Encode(dst)
+	dst := make([]byte, pkt.Len(Version311))
+	n, err := pkt.Encode(Version311, dst)
 
 	assert.Error(t, err)
 	assert.Equal(t, 0, n)
@@ -204,19 +204,19 @@ func TestUnsubscribeEqualDecodeEncode(t *testing.T) {
 	}
 
 	pkt := NewUnsubscribe()
-	n, err := pkt.Decode(pktBytes)
+	n, err := pkt.Decode(Version311, pktBytes)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n)
 
 	dst := make([]byte, 100)
-	n2, err := pkt.Encode(dst)
+	n2, err := pkt.Encode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n2)
 	assert.Equal(t, pktBytes, dst[:n2])
 
-	n3, err := pkt.Decode(dst)
+	n3, err := pkt.Decode(Version311, dst)
 
 	assert.NoError(t, err)
 	assert.Equal(t, len(pktBytes), n3)
@@ -227,10 +227,10 @@ func BenchmarkUnsubscribeEncode(b *testing.B) {
 	pkt.ID = 1
 	pkt.Topics = []string{"t"}
 
-	buf := make([]byte, pkt.Len())
+	buf := make([]byte, pkt.Len(Version311))
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(Version311, buf)
 		if err != nil {
 			panic(err)
 		}
@@ -251,9 +251,44 @@ func BenchmarkUnsubscribeDecode(b *testing.B) {
 	pkt := NewUnsubscribe()
 
 	for i := 0; i < b.N; i++ {
-		_, err := pkt.Decode(pktBytes)
+		_, err := pkt.Decode(Version311, pktBytes)
 		if err != nil {
 			panic(err)
 		}
 	}
 }
+
+func TestUnsubscribeEqualDecodeEncodeVersion5(t *testing.T) {
+	pktBytes := []byte{
+		byte(UNSUBSCRIBE<<4) | 2,
+		15,
+		0, // packet ID MSB
+		7, // packet ID LSB
+		7, // properties length
+		byte(PropertyUserProperty),
+		0, // key MSB
+		1, // key LSB
+		'a',
+		0, // value MSB
+		1, // value LSB
+		'b',
+		0, // topic name MSB
+		3, // topic name LSB
+		'a', '/', 'b',
+	}
+
+	pkt := NewUnsubscribe()
+	n, err := pkt.Decode(Version5, pktBytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n)
+	assert.Equal(t, []string{"a/b"}, pkt.Topics)
+	assert.Equal(t, []UserProperty{{"a", "b"}}, pkt.Properties.UserProperties)
+
+	dst := make([]byte, pkt.Len(Version5))
+	n2, err := pkt.Encode(Version5, dst)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(pktBytes), n2)
+	assert.Equal(t, pktBytes, dst[:n2])
+}
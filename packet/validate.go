@@ -0,0 +1,365 @@
+package packet
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A Rule is a normative statement of the specification that can be enforced
+// on decoded packets.
+type Rule struct {
+	// The identifier of the normative statement e.g. "MQTT-3.3.2-2".
+	Statement string
+
+	// A short description of the statement.
+	Description string
+
+	// the check that returns false if the packet violates the statement
+	check func(pkt Generic, version byte) bool
+}
+
+// Check returns whether the packet conforms to the rule.
+func (r Rule) Check(pkt Generic, version byte) bool {
+	return r.check(pkt, version)
+}
+
+// A Profile is a named set of rules that is used to validate packets.
+type Profile struct {
+	// The name of the profile.
+	Name string
+
+	// The enforced rules.
+	Rules []Rule
+}
+
+// Validate will check the packet against all rules of the profile and return
+// an error for the first violated rule.
+func (p *Profile) Validate(pkt Generic, version byte) error {
+	// check rules
+	for _, rule := range p.Rules {
+		if !rule.check(pkt, version) {
+			return makeError(pkt.Type(), "violation of %s: %s", rule.Statement, rule.Description)
+		}
+	}
+
+	return nil
+}
+
+// RuleWellFormedStrings enforces that all strings are well-formed UTF-8.
+var RuleWellFormedStrings = Rule{
+	Statement:   "MQTT-1.5.3-1",
+	Description: "strings must be well-formed utf-8",
+	check: func(pkt Generic, _ byte) bool {
+		for _, str := range packetStrings(pkt) {
+			if !utf8.ValidString(str) {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleNoNullCharacter enforces that no string includes the null character.
+var RuleNoNullCharacter = Rule{
+	Statement:   "MQTT-1.5.3-2",
+	Description: "strings must not include the null character",
+	check: func(pkt Generic, _ byte) bool {
+		for _, str := range packetStrings(pkt) {
+			if strings.ContainsRune(str, 0) {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleNonEmptyTopics enforces that topic names and topic filters are at
+// least one character long. Empty topic names of MQTT 5 Publish packets that
+// carry a topic alias are allowed.
+var RuleNonEmptyTopics = Rule{
+	Statement:   "MQTT-4.7.3-1",
+	Description: "topic names and filters must be at least one character long",
+	check: func(pkt Generic, version byte) bool {
+		// check topic names
+		for _, name := range topicNames(pkt, version) {
+			if name == "" {
+				return false
+			}
+		}
+
+		// check topic filters
+		for _, filter := range topicFilters(pkt) {
+			if filter == "" {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleNoWildcardsInTopicNames enforces that topic names of published and will
+// messages do not contain wildcards.
+var RuleNoWildcardsInTopicNames = Rule{
+	Statement:   "MQTT-3.3.2-2",
+	Description: "topic names must not contain wildcard characters",
+	check: func(pkt Generic, version byte) bool {
+		for _, name := range topicNames(pkt, version) {
+			if strings.ContainsAny(name, "#+") {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleMultiLevelWildcard enforces that the multi-level wildcard is the last
+// character of a topic filter and occupies a whole level.
+var RuleMultiLevelWildcard = Rule{
+	Statement:   "MQTT-4.7.1-2",
+	Description: "the multi-level wildcard must be the last character and occupy a whole level",
+	check: func(pkt Generic, _ byte) bool {
+		for _, filter := range topicFilters(pkt) {
+			i := strings.IndexByte(filter, '#')
+			if i < 0 {
+				continue
+			}
+
+			// check position and preceding separator
+			if i != len(filter)-1 || (i > 0 && filter[i-1] != '/') {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleSingleLevelWildcard enforces that the single-level wildcard occupies a
+// whole level of a topic filter.
+var RuleSingleLevelWildcard = Rule{
+	Statement:   "MQTT-4.7.1-3",
+	Description: "the single-level wildcard must occupy a whole level",
+	check: func(pkt Generic, _ byte) bool {
+		for _, filter := range topicFilters(pkt) {
+			for _, level := range strings.Split(filter, "/") {
+				if strings.ContainsRune(level, '+') && level != "+" {
+					return false
+				}
+			}
+		}
+
+		return true
+	},
+}
+
+// RuleSubscribeFilters enforces that a Subscribe packet contains at least one
+// subscription.
+var RuleSubscribeFilters = Rule{
+	Statement:   "MQTT-3.8.3-3",
+	Description: "subscribe packets must contain at least one subscription",
+	check: func(pkt Generic, _ byte) bool {
+		subscribe, ok := pkt.(*Subscribe)
+		return !ok || len(subscribe.Subscriptions) > 0
+	},
+}
+
+// RuleUnsubscribeFilters enforces that an Unsubscribe packet contains at least
+// one topic filter.
+var RuleUnsubscribeFilters = Rule{
+	Statement:   "MQTT-3.10.3-2",
+	Description: "unsubscribe packets must contain at least one topic filter",
+	check: func(pkt Generic, _ byte) bool {
+		unsubscribe, ok := pkt.(*Unsubscribe)
+		return !ok || len(unsubscribe.Topics) > 0
+	},
+}
+
+// RuleNoDupForQOS0 enforces that the Dup flag is not set for QOS 0 messages.
+var RuleNoDupForQOS0 = Rule{
+	Statement:   "MQTT-3.3.1-2",
+	Description: "the dup flag must not be set for qos 0 messages",
+	check: func(pkt Generic, _ byte) bool {
+		publish, ok := pkt.(*Publish)
+		return !ok || !publish.Dup || publish.Message.QOS != QOSAtMostOnce
+	},
+}
+
+// RuleLegacyClientID enforces that MQTT 3.1 client ids are between 1 and 23
+// bytes long.
+var RuleLegacyClientID = Rule{
+	Statement:   "MQTT-3.1.3-5",
+	Description: "mqtt 3.1 client ids must be between 1 and 23 bytes long",
+	check: func(pkt Generic, version byte) bool {
+		connect, ok := pkt.(*Connect)
+		if !ok || announcedVersion(connect, version) != Version31 {
+			return true
+		}
+
+		return len(connect.ClientID) >= 1 && len(connect.ClientID) <= 23
+	},
+}
+
+// RuleEmptyClientID enforces that MQTT 3.1.1 clients that supply an empty
+// client id also request a clean session.
+var RuleEmptyClientID = Rule{
+	Statement:   "MQTT-3.1.3-7",
+	Description: "an empty client id requires a clean session",
+	check: func(pkt Generic, version byte) bool {
+		connect, ok := pkt.(*Connect)
+		if !ok || announcedVersion(connect, version) != Version311 {
+			return true
+		}
+
+		return connect.ClientID != "" || connect.CleanSession
+	},
+}
+
+// RuleNoLocalSharedSubscription enforces that the no local option is not set
+// on MQTT 5 shared subscriptions.
+var RuleNoLocalSharedSubscription = Rule{
+	Statement:   "MQTT-3.8.3-4",
+	Description: "the no local option must not be set on shared subscriptions",
+	check: func(pkt Generic, version byte) bool {
+		subscribe, ok := pkt.(*Subscribe)
+		if !ok || version != Version5 {
+			return true
+		}
+
+		for _, sub := range subscribe.Subscriptions {
+			if sub.NoLocal && strings.HasPrefix(sub.Topic, "$share/") {
+				return false
+			}
+		}
+
+		return true
+	},
+}
+
+// LenientProfile only enforces the rules that protect the routing of messages.
+//
+// It enforces:
+//
+//   - MQTT-1.5.3-2: strings must not include the null character
+//   - MQTT-4.7.3-1: topic names and filters must be at least one character long
+//   - MQTT-3.3.2-2: topic names must not contain wildcard characters
+//   - MQTT-4.7.1-2: the multi-level wildcard must be the last character and occupy a whole level
+//   - MQTT-4.7.1-3: the single-level wildcard must occupy a whole level
+//   - MQTT-3.8.3-3: subscribe packets must contain at least one subscription
+//   - MQTT-3.10.3-2: unsubscribe packets must contain at least one topic filter
+var LenientProfile = &Profile{
+	Name: "lenient",
+	Rules: []Rule{
+		RuleNoNullCharacter,
+		RuleNonEmptyTopics,
+		RuleNoWildcardsInTopicNames,
+		RuleMultiLevelWildcard,
+		RuleSingleLevelWildcard,
+		RuleSubscribeFilters,
+		RuleUnsubscribeFilters,
+	},
+}
+
+// StrictProfile enforces all supported rules.
+//
+// In addition to the rules of the lenient profile it enforces:
+//
+//   - MQTT-1.5.3-1: strings must be well-formed utf-8
+//   - MQTT-3.3.1-2: the dup flag must not be set for qos 0 messages
+//   - MQTT-3.1.3-5: mqtt 3.1 client ids must be between 1 and 23 bytes long
+//   - MQTT-3.1.3-7: an empty client id requires a clean session
+//   - MQTT-3.8.3-4: the no local option must not be set on shared subscriptions
+var StrictProfile = &Profile{
+	Name: "strict",
+	Rules: []Rule{
+		RuleWellFormedStrings,
+		RuleNoNullCharacter,
+		RuleNonEmptyTopics,
+		RuleNoWildcardsInTopicNames,
+		RuleMultiLevelWildcard,
+		RuleSingleLevelWildcard,
+		RuleSubscribeFilters,
+		RuleUnsubscribeFilters,
+		RuleNoDupForQOS0,
+		RuleLegacyClientID,
+		RuleEmptyClientID,
+		RuleNoLocalSharedSubscription,
+	},
+}
+
+// Validate will check the packet against the strict profile. It returns an
+// error for the first violated normative statement.
+func Validate(pkt Generic, version byte) error {
+	return StrictProfile.Validate(pkt, version)
+}
+
+// returns the version announced by a connect packet
+func announcedVersion(connect *Connect, version byte) byte {
+	if connect.Version != 0 {
+		return connect.Version
+	}
+
+	if version != 0 {
+		return version
+	}
+
+	return Version311
+}
+
+// returns the topic names carried by the packet
+func topicNames(pkt Generic, version byte) []string {
+	switch pkt := pkt.(type) {
+	case *Publish:
+		// topic aliases allow empty topic names
+		if version == Version5 && pkt.Message.Topic == "" && pkt.Properties.TopicAlias != nil {
+			return nil
+		}
+
+		return []string{pkt.Message.Topic}
+	case *Connect:
+		if pkt.Will != nil {
+			return []string{pkt.Will.Topic}
+		}
+	}
+
+	return nil
+}
+
+// returns the topic filters carried by the packet
+func topicFilters(pkt Generic) []string {
+	switch pkt := pkt.(type) {
+	case *Subscribe:
+		filters := make([]string, 0, len(pkt.Subscriptions))
+		for _, sub := range pkt.Subscriptions {
+			filters = append(filters, sub.Topic)
+		}
+
+		return filters
+	case *Unsubscribe:
+		return pkt.Topics
+	}
+
+	return nil
+}
+
+// returns all strings carried by the packet
+func packetStrings(pkt Generic) []string {
+	switch pkt := pkt.(type) {
+	case *Connect:
+		strs := []string{pkt.ClientID, pkt.Username}
+		if pkt.Will != nil {
+			strs = append(strs, pkt.Will.Topic)
+		}
+
+		return strs
+	case *Publish:
+		return []string{pkt.Message.Topic}
+	case *Subscribe, *Unsubscribe:
+		return topicFilters(pkt)
+	}
+
+	return nil
+}
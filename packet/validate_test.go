@@ -0,0 +1,64 @@
+package packet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	table := []struct {
+		pkt       Generic
+		version   byte
+		statement string
+	}{
+		{&Publish{Message: Message{Topic: "foo/bar"}}, Version311, ""},
+		{&Publish{Message: Message{Topic: "foo/+"}}, Version311, "MQTT-3.3.2-2"},
+		{&Publish{Message: Message{Topic: "foo/#"}}, Version311, "MQTT-3.3.2-2"},
+		{&Publish{Message: Message{Topic: "foo\x00"}}, Version311, "MQTT-1.5.3-2"},
+		{&Publish{Message: Message{Topic: "foo\xff"}}, Version311, "MQTT-1.5.3-1"},
+		{&Publish{Message: Message{Topic: ""}}, Version311, "MQTT-4.7.3-1"},
+		{&Publish{Message: Message{Topic: ""}, Properties: Properties{TopicAlias: uint16Ptr(1)}}, Version5, ""},
+		{&Publish{Message: Message{Topic: "foo"}, Dup: true}, Version311, "MQTT-3.3.1-2"},
+		{&Publish{Message: Message{Topic: "foo", QOS: 1}, Dup: true, ID: 1}, Version311, ""},
+		{&Subscribe{ID: 1}, Version311, "MQTT-3.8.3-3"},
+		{&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "foo/#"}}}, Version311, ""},
+		{&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "foo#"}}}, Version311, "MQTT-4.7.1-2"},
+		{&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "foo/#/bar"}}}, Version311, "MQTT-4.7.1-2"},
+		{&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "foo/bar+"}}}, Version311, "MQTT-4.7.1-3"},
+		{&Subscribe{ID: 1, Subscriptions: []Subscription{{Topic: "$share/g/foo", NoLocal: true}}}, Version5, "MQTT-3.8.3-4"},
+		{&Unsubscribe{ID: 1}, Version311, "MQTT-3.10.3-2"},
+		{&Unsubscribe{ID: 1, Topics: []string{"+/bar"}}, Version311, ""},
+		{&Connect{ClientID: "foo", Version: Version31}, Version31, ""},
+		{&Connect{ClientID: strings.Repeat("x", 24), Version: Version31}, Version31, "MQTT-3.1.3-5"},
+		{&Connect{ClientID: strings.Repeat("x", 24), Version: Version311}, Version311, ""},
+		{&Connect{Version: Version311}, Version311, "MQTT-3.1.3-7"},
+		{&Connect{Version: Version311, Will: &Message{Topic: "foo/+"}}, Version311, "MQTT-3.3.2-2"},
+		{&Puback{ID: 1}, Version311, ""},
+	}
+
+	for _, entry := range table {
+		err := Validate(entry.pkt, entry.version)
+		if entry.statement == "" {
+			assert.NoError(t, err, entry.pkt.String())
+		} else if assert.Error(t, err, entry.pkt.String()) {
+			assert.Contains(t, err.Error(), entry.statement)
+			assert.Equal(t, entry.pkt.Type(), err.(*Error).Type)
+		}
+	}
+}
+
+func TestLenientProfile(t *testing.T) {
+	pkt := &Publish{Message: Message{Topic: "foo\xff"}, Dup: true}
+	assert.Error(t, StrictProfile.Validate(pkt, Version311))
+	assert.NoError(t, LenientProfile.Validate(pkt, Version311))
+
+	pkt = &Publish{Message: Message{Topic: "foo/+"}}
+	assert.Error(t, LenientProfile.Validate(pkt, Version311))
+}
+
+func TestRuleCheck(t *testing.T) {
+	assert.True(t, RuleUnsubscribeFilters.Check(&Unsubscribe{Topics: []string{"foo"}}, Version311))
+	assert.False(t, RuleUnsubscribeFilters.Check(&Unsubscribe{}, Version311))
+}
@@ -0,0 +1,66 @@
+package packet
+
+// returns the byte length of a variable byte integer
+func varintLen(n int) int {
+	if n <= 127 {
+		return 1
+	} else if n <= 16383 {
+		return 2
+	} else if n <= 2097151 {
+		return 3
+	}
+
+	return 4
+}
+
+// read variable byte integer
+func readVarint(buf []byte, t Type) (int, int, error) {
+	// prepare value
+	value := 0
+	multiplier := 1
+
+	for i := 0; i < 4; i++ {
+		// check buffer
+		if len(buf) < i+1 {
+			return 0, i, makeError(t, "insufficient buffer size, expected %d, got %d", i+1, len(buf))
+		}
+
+		// add value
+		value += int(buf[i]&0x7f) * multiplier
+		multiplier *= 128
+
+		// check continuation bit
+		if buf[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+
+	return 0, 4, makeError(t, "malformed variable byte integer")
+}
+
+// write variable byte integer
+func writeVarint(buf []byte, n int, t Type) (int, error) {
+	// check value
+	if n < 0 || n > maxRemainingLength {
+		return 0, makeError(t, "variable byte integer (%d) out of bound (max %d, min 0)", n, maxRemainingLength)
+	}
+
+	// check buffer
+	l := varintLen(n)
+	if len(buf) < l {
+		return 0, makeError(t, "insufficient buffer size, expected %d, got %d", l, len(buf))
+	}
+
+	// write bytes
+	for i := 0; i < l; i++ {
+		buf[i] = byte(n % 128)
+		n /= 128
+
+		// set continuation bit
+		if i < l-1 {
+			buf[i] |= 0x80
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,51 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarint(t *testing.T) {
+	table := map[int][]byte{
+		0:         {0x00},
+		127:       {0x7f},
+		128:       {0x80, 0x01},
+		16383:     {0xff, 0x7f},
+		16384:     {0x80, 0x80, 0x01},
+		2097151:   {0xff, 0xff, 0x7f},
+		2097152:   {0x80, 0x80, 0x80, 0x01},
+		268435455: {0xff, 0xff, 0xff, 0x7f},
+	}
+
+	for value, bytes := range table {
+		assert.Equal(t, len(bytes), varintLen(value))
+
+		buf := make([]byte, varintLen(value))
+		n, err := writeVarint(buf, value, PUBLISH)
+		assert.NoError(t, err)
+		assert.Equal(t, len(bytes), n)
+		assert.Equal(t, bytes, buf)
+
+		v, n, err := readVarint(bytes, PUBLISH)
+		assert.NoError(t, err)
+		assert.Equal(t, len(bytes), n)
+		assert.Equal(t, value, v)
+	}
+}
+
+func TestReadVarintError(t *testing.T) {
+	_, _, err := readVarint([]byte{0x80, 0x80}, PUBLISH) // < insufficient buffer
+	assert.Error(t, err)
+
+	_, _, err = readVarint([]byte{0xff, 0xff, 0xff, 0xff, 0x7f}, PUBLISH) // < too long
+	assert.Error(t, err)
+}
+
+func TestWriteVarintError(t *testing.T) {
+	_, err := writeVarint(make([]byte, 4), 268435456, PUBLISH) // < too big
+	assert.Error(t, err)
+
+	_, err = writeVarint(make([]byte, 1), 128, PUBLISH) // < insufficient buffer
+	assert.Error(t, err)
+}
@@ -0,0 +1,465 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// ErrSessionClosed is returned by a FileSession that has been closed.
+var ErrSessionClosed = errors.New("session closed")
+
+// SyncPolicy defines when a FileSession syncs its log to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log after every write.
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval syncs the log after a write if the last sync happened
+	// longer ago than the configured sync interval.
+	SyncInterval
+
+	// SyncNever leaves syncing the log to the operating system.
+	SyncNever
+)
+
+// A FileSession stores packets in memory and persists all changes to an
+// append-only log file. The log is compacted once enough obsolete records have
+// accumulated. Every record includes the position of the id counter, which is
+// restored together with the packets when the session is opened again. A torn
+// last write is detected using checksums and removed during recovery.
+type FileSession struct {
+	// SyncPolicy defines when the log is synced to stable storage.
+	//
+	// Will default to SyncAlways.
+	SyncPolicy SyncPolicy
+
+	// SyncInterval defines the maximum time between syncs when using the
+	// SyncInterval policy.
+	//
+	// Will default to one second.
+	SyncInterval time.Duration
+
+	// CompactionThreshold defines the number of obsolete records after which
+	// the log is compacted.
+	//
+	// Will default to 1000.
+	CompactionThreshold int
+
+	sessionLog
+
+	path     string
+	file     *os.File
+	lastSync time.Time
+	buf      []byte
+	mutex    sync.Mutex
+}
+
+// OpenFileSession opens or creates the log at the specified path and returns a
+// FileSession that has been restored from it.
+func OpenFileSession(path string) (*FileSession, error) {
+	// open file
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare session
+	s := &FileSession{
+		sessionLog: newSessionLog(),
+		path:       path,
+		file:       file,
+		lastSync:   time.Now(),
+	}
+
+	// recover session
+	err = s.recover()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NextID will return the next id for outgoing packets that is not in use. It
+// will block until an id becomes free if all ids are in use.
+func (s *FileSession) NextID() packet.ID {
+	id, _ := NewIDAllocator(s.counter, s.outgoing).AllocateWait(nil)
+	return id
+}
+
+// AllocateID will return the next id for outgoing packets that is not in use.
+// It will return ErrNoFreeID if all ids are in use.
+func (s *FileSession) AllocateID() (packet.ID, error) {
+	return NewIDAllocator(s.counter, s.outgoing).Allocate()
+}
+
+// SavePacket will store a packet in the session. An eventual existing
+// packet with the same id gets quietly overwritten. Publish packets with a
+// PayloadReader are rejected with ErrStreamedPacket.
+func (s *FileSession) SavePacket(dir Direction, pkt packet.Generic) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// check packet
+	if isStreamed(pkt) {
+		return ErrStreamedPacket
+	}
+
+	// get id
+	id, ok := packet.GetID(pkt)
+	if !ok {
+		return nil
+	}
+
+	// get store
+	store := s.storeForDirection(dir)
+
+	// encode packet
+	data := make([]byte, pkt.Len(packet.Version5))
+	_, err := pkt.Encode(packet.Version5, data)
+	if err != nil {
+		return err
+	}
+
+	// write record
+	err = s.write(recordSave, byte(dir), data)
+	if err != nil {
+		return err
+	}
+
+	// count overwritten packets
+	if store.Lookup(id) != nil {
+		s.obsolete++
+	}
+
+	// save packet
+	store.Save(pkt)
+
+	return s.compact()
+}
+
+// LookupPacket will retrieve a packet from the session using a packet id.
+func (s *FileSession) LookupPacket(dir Direction, id packet.ID) (packet.Generic, error) {
+	return s.storeForDirection(dir).Lookup(id), nil
+}
+
+// DeletePacket will remove a packet from the session. The method must not
+// return an error if no packet with the specified id does exists.
+func (s *FileSession) DeletePacket(dir Direction, id packet.ID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// get store
+	store := s.storeForDirection(dir)
+
+	// check packet
+	if store.Lookup(id) == nil {
+		return nil
+	}
+
+	// write record
+	err := s.write(recordDelete, byte(dir), []byte{byte(id >> 8), byte(id)})
+	if err != nil {
+		return err
+	}
+
+	// delete packet
+	store.Delete(id)
+
+	// the saved packet and the delete record are obsolete
+	s.obsolete += 2
+
+	return s.compact()
+}
+
+// AllPackets will return all packets currently saved in the session in the
+// order they have been saved.
+func (s *FileSession) AllPackets(dir Direction) ([]packet.Generic, error) {
+	return s.storeForDirection(dir).All(), nil
+}
+
+// Reset will completely reset the session and truncate the log.
+func (s *FileSession) Reset() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// truncate file
+	err := s.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	// sync file
+	err = s.file.Sync()
+	if err != nil {
+		return err
+	}
+
+	// reset counter and stores
+	s.counter.Reset()
+	s.incoming.Reset()
+	s.outgoing.Reset()
+	s.obsolete = 0
+
+	return nil
+}
+
+// Compact will rewrite the log to only contain the currently saved packets.
+func (s *FileSession) Compact() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.rewrite()
+}
+
+// Export will write the id counter position and all packets of the session in
+// order to the writer. The exported data can be imported by any session that
+// supports Import.
+func (s *FileSession) Export(w io.Writer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.export(w)
+}
+
+// Import will replace the id counter position and all packets of the session
+// with the data read from the reader that has been written using Export. The
+// log is rewritten afterwards.
+func (s *FileSession) Import(r io.Reader) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// load log
+	err := s.load(r)
+	if err != nil {
+		return err
+	}
+
+	return s.rewrite()
+}
+
+// Close will sync and close the log. The session must not be used afterwards.
+func (s *FileSession) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// sync file
+	err := s.file.Sync()
+	if err != nil {
+		_ = s.file.Close()
+		s.file = nil
+		return err
+	}
+
+	// close file
+	err = s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+func (s *FileSession) recover() error {
+	// read log
+	data, err := ioutil.ReadAll(s.file)
+	if err != nil {
+		return err
+	}
+
+	// replay records
+	offset, err := s.replay(data)
+	if err != nil {
+		return err
+	}
+
+	// remove torn or corrupted tail
+	if offset < len(data) {
+		err = s.file.Truncate(int64(offset))
+		if err != nil {
+			return err
+		}
+
+		// sync file
+		err = s.file.Sync()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FileSession) write(kind byte, dir byte, body []byte) error {
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// encode record
+	s.buf = appendRecord(s.buf[:0], kind, s.counter.peek(), dir, body)
+
+	// write record
+	_, err := s.file.Write(s.buf)
+	if err != nil {
+		return err
+	}
+
+	return s.sync()
+}
+
+func (s *FileSession) sync() error {
+	switch s.SyncPolicy {
+	case SyncAlways:
+		return s.file.Sync()
+	case SyncInterval:
+		// get interval
+		interval := s.SyncInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+
+		// sync if interval has passed
+		if time.Since(s.lastSync) >= interval {
+			s.lastSync = time.Now()
+			return s.file.Sync()
+		}
+	}
+
+	return nil
+}
+
+func (s *FileSession) compact() error {
+	// get threshold
+	threshold := s.CompactionThreshold
+	if threshold <= 0 {
+		threshold = 1000
+	}
+
+	// check threshold
+	if s.obsolete < threshold {
+		return nil
+	}
+
+	return s.rewrite()
+}
+
+func (s *FileSession) rewrite() error {
+	// check file
+	if s.file == nil {
+		return ErrSessionClosed
+	}
+
+	// create temporary file
+	tmp, err := os.OpenFile(s.path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	// prepare cleanup
+	cleanup := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(s.path + ".tmp")
+		return err
+	}
+
+	// encode counter position and packets
+	buf, err := s.encode(nil)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	// write records
+	_, err = tmp.Write(buf)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	// sync file
+	err = tmp.Sync()
+	if err != nil {
+		return cleanup(err)
+	}
+
+	// close file
+	err = tmp.Close()
+	if err != nil {
+		return cleanup(err)
+	}
+
+	// replace log
+	err = os.Rename(s.path+".tmp", s.path)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	// open new log
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	// replace file
+	_ = s.file.Close()
+	s.file = file
+	s.obsolete = 0
+
+	// sync directory to persist the rename
+	err = syncDir(filepath.Dir(s.path))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// syncDir syncs the specified directory to stable storage
+func syncDir(path string) error {
+	// directories cannot be synced on windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	// open directory
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	// sync directory
+	err = dir.Sync()
+	if err != nil {
+		_ = dir.Close()
+		return err
+	}
+
+	return dir.Close()
+}
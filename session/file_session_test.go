@@ -0,0 +1,373 @@
+package session
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempSessionPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gomqtt")
+	assert.NoError(t, err)
+
+	return filepath.Join(dir, "session.log"), func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestFileSessionNextID(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, packet.ID(1), session.NextID())
+	assert.Equal(t, packet.ID(2), session.NextID())
+
+	for i := 0; i < math.MaxUint16-3; i++ {
+		session.NextID()
+	}
+
+	assert.Equal(t, packet.ID(math.MaxUint16), session.NextID())
+	assert.Equal(t, packet.ID(1), session.NextID())
+
+	err = session.Reset()
+	assert.NoError(t, err)
+
+	assert.Equal(t, packet.ID(1), session.NextID())
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionPacketStore(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.ID = 1
+	publish.Message.Topic = "foo"
+
+	pkt, err := session.LookupPacket(Incoming, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, pkt)
+
+	err = session.SavePacket(Incoming, publish)
+	assert.NoError(t, err)
+
+	pkt, err = session.LookupPacket(Incoming, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, publish, pkt)
+
+	list, err := session.AllPackets(Incoming)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list))
+
+	err = session.DeletePacket(Incoming, 1)
+	assert.NoError(t, err)
+
+	pkt, err = session.LookupPacket(Incoming, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, pkt)
+
+	list, err = session.AllPackets(Incoming)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+
+	err = session.SavePacket(Outgoing, publish)
+	assert.NoError(t, err)
+
+	list, err = session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list))
+
+	err = session.Reset()
+	assert.NoError(t, err)
+
+	list, err = session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	err = session.SavePacket(Outgoing, publish)
+	assert.Equal(t, ErrSessionClosed, err)
+}
+
+func TestFileSessionStreamedPacket(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.ID = 1
+	publish.PayloadReader = bytes.NewReader([]byte("foo"))
+	publish.PayloadLength = 3
+
+	err = session.SavePacket(Outgoing, publish)
+	assert.Equal(t, ErrStreamedPacket, err)
+
+	pkt, err := session.LookupPacket(Outgoing, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, pkt)
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionRecovery(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.ID = session.NextID()
+	publish.Message.Topic = "foo"
+	publish.Message.Payload = []byte("bar")
+	publish.Message.QOS = 1
+
+	err = session.SavePacket(Outgoing, publish)
+	assert.NoError(t, err)
+
+	pubrel := packet.NewPubrel()
+	pubrel.ID = session.NextID()
+
+	err = session.SavePacket(Outgoing, pubrel)
+	assert.NoError(t, err)
+
+	pubrec := packet.NewPublish()
+	pubrec.ID = 7
+	pubrec.Message.Topic = "baz"
+	pubrec.Message.QOS = 2
+
+	err = session.SavePacket(Incoming, pubrec)
+	assert.NoError(t, err)
+
+	err = session.DeletePacket(Incoming, 7)
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	pkt, err := session.LookupPacket(Outgoing, publish.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, publish, pkt)
+
+	pkt, err = session.LookupPacket(Outgoing, pubrel.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, pubrel, pkt)
+
+	list, err := session.AllPackets(Incoming)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	assert.Equal(t, packet.ID(3), session.NextID())
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionTornWrite(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	err = session.SavePacket(Outgoing, &packet.Pubrel{ID: 1})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	size := info.Size()
+
+	err = session.SavePacket(Outgoing, &packet.Pubrel{ID: 2})
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	err = os.Truncate(path, size+5)
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	list, err := session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []packet.Generic{&packet.Pubrel{ID: 1}}, list)
+
+	info, err = os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, size, info.Size())
+
+	err = session.SavePacket(Outgoing, &packet.Pubrel{ID: 3})
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	list, err = session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionCompaction(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	session.CompactionThreshold = 10
+	session.SyncPolicy = SyncNever
+
+	for i := 0; i < 100; i++ {
+		id := session.NextID()
+
+		err = session.SavePacket(Outgoing, &packet.Pubrel{ID: id})
+		assert.NoError(t, err)
+
+		err = session.DeletePacket(Outgoing, id)
+		assert.NoError(t, err)
+	}
+
+	err = session.SavePacket(Outgoing, &packet.Pubrel{ID: 1})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() < 200)
+
+	err = session.Compact()
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	list, err := session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []packet.Generic{&packet.Pubrel{ID: 1}}, list)
+	assert.Equal(t, packet.ID(101), session.NextID())
+
+	err = session.DeletePacket(Outgoing, 1)
+	assert.NoError(t, err)
+
+	err = session.Compact()
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	list, err = session.AllPackets(Outgoing)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Equal(t, packet.ID(102), session.NextID())
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionOrder(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	saveOrderedPackets(t, session)
+	verifyOrderedPackets(t, session)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	verifyOrderedPackets(t, session)
+
+	err = session.Compact()
+	assert.NoError(t, err)
+
+	err = session.Close()
+	assert.NoError(t, err)
+
+	session, err = OpenFileSession(path)
+	assert.NoError(t, err)
+
+	verifyOrderedPackets(t, session)
+
+	err = session.Close()
+	assert.NoError(t, err)
+}
+
+func TestFileSessionExportImport(t *testing.T) {
+	path, cleanup := tempSessionPath(t)
+	defer cleanup()
+
+	session1 := NewMemorySession()
+	saveOrderedPackets(t, session1)
+	assert.Equal(t, packet.ID(2), session1.NextID())
+
+	var buf bytes.Buffer
+	err := session1.Export(&buf)
+	assert.NoError(t, err)
+
+	session2, err := OpenFileSession(path)
+	assert.NoError(t, err)
+
+	err = session2.Import(&buf)
+	assert.NoError(t, err)
+	verifyOrderedPackets(t, session2)
+
+	err = session2.Close()
+	assert.NoError(t, err)
+
+	session2, err = OpenFileSession(path)
+	assert.NoError(t, err)
+	verifyOrderedPackets(t, session2)
+	assert.Equal(t, packet.ID(4), session2.NextID())
+
+	err = session2.Export(&buf)
+	assert.NoError(t, err)
+
+	session3 := NewMemorySession()
+	err = session3.Import(&buf)
+	assert.NoError(t, err)
+	verifyOrderedPackets(t, session3)
+	assert.Equal(t, packet.ID(6), session3.NextID())
+
+	err = session2.Close()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"errors"
+	"math"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// ErrNoFreeID is returned by the IDAllocator if all packet ids are in use.
+var ErrNoFreeID = errors.New("no free packet id")
+
+// An IDAllocator allocates packet ids using a counter while skipping ids that
+// are still in use by packets in a store.
+type IDAllocator struct {
+	counter *IDCounter
+	store   *PacketStore
+}
+
+// NewIDAllocator returns a new allocator that uses the specified counter and
+// skips ids that are in use by packets in the specified store.
+func NewIDAllocator(counter *IDCounter, store *PacketStore) *IDAllocator {
+	return &IDAllocator{
+		counter: counter,
+		store:   store,
+	}
+}
+
+// Allocate will return the next id that is not in use. It will return
+// ErrNoFreeID if all ids are in use.
+func (a *IDAllocator) Allocate() (packet.ID, error) {
+	// check if all ids are in use
+	if a.store.len() >= math.MaxUint16 {
+		return 0, ErrNoFreeID
+	}
+
+	// find next free id
+	for i := 0; i < math.MaxUint16; i++ {
+		id := a.counter.NextID()
+		if a.store.Lookup(id) == nil {
+			return id, nil
+		}
+	}
+
+	return 0, ErrNoFreeID
+}
+
+// AllocateWait behaves like Allocate but will block until an id becomes free
+// if all ids are in use. It will return ErrNoFreeID if the cancel channel is
+// closed before that happens.
+func (a *IDAllocator) AllocateWait(cancel <-chan struct{}) (packet.ID, error) {
+	for {
+		// get signal before allocating to not miss deletions
+		freed := a.store.freed()
+
+		// allocate id
+		id, err := a.Allocate()
+		if err != ErrNoFreeID {
+			return id, err
+		}
+
+		// wait for a deletion
+		select {
+		case <-freed:
+		case <-cancel:
+			return 0, ErrNoFreeID
+		}
+	}
+}
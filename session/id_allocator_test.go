@@ -0,0 +1,69 @@
+package session
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDAllocator(t *testing.T) {
+	counter := NewIDCounter()
+	store := NewPacketStore()
+	allocator := NewIDAllocator(counter, store)
+
+	store.Save(&packet.Pubrel{ID: 2})
+	store.Save(&packet.Pubrel{ID: 3})
+
+	id, err := allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(1), id)
+
+	id, err = allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(4), id)
+
+	counter.Reset()
+	store.Delete(2)
+
+	id, err = allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(1), id)
+
+	id, err = allocator.Allocate()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(2), id)
+}
+
+func TestIDAllocatorExhaustion(t *testing.T) {
+	counter := NewIDCounter()
+	store := NewPacketStore()
+	allocator := NewIDAllocator(counter, store)
+
+	for i := 1; i <= math.MaxUint16; i++ {
+		store.Save(&packet.Pubrel{ID: packet.ID(i)})
+	}
+
+	id, err := allocator.Allocate()
+	assert.Equal(t, ErrNoFreeID, err)
+	assert.Equal(t, packet.ID(0), id)
+
+	cancel := make(chan struct{})
+	close(cancel)
+
+	id, err = allocator.AllocateWait(cancel)
+	assert.Equal(t, ErrNoFreeID, err)
+	assert.Equal(t, packet.ID(0), id)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		store.Delete(42)
+	}()
+
+	id, err = allocator.AllocateWait(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(42), id)
+}
@@ -52,3 +52,19 @@ func (c *IDCounter) Reset() {
 	// reset counter
 	c.next = 1
 }
+
+// returns the next id without incrementing the counter
+func (c *IDCounter) peek() packet.ID {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.next
+}
+
+// sets the next id
+func (c *IDCounter) set(next packet.ID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.next = next
+}
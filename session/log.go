@@ -0,0 +1,265 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// ErrInvalidRecord is returned if a log record is invalid.
+var ErrInvalidRecord = errors.New("invalid record")
+
+const (
+	recordSave byte = iota + 1
+	recordDelete
+	recordReset
+)
+
+// the size of the record header (length and checksum)
+const recordHeaderLen = 8
+
+// the state of a session that is encoded as log records
+type sessionLog struct {
+	counter  *IDCounter
+	incoming *PacketStore
+	outgoing *PacketStore
+	obsolete int
+}
+
+func newSessionLog() sessionLog {
+	return sessionLog{
+		counter:  NewIDCounter(),
+		incoming: NewPacketStore(),
+		outgoing: NewPacketStore(),
+	}
+}
+
+// replay applies all complete records and returns the offset of the first
+// incomplete or corrupted record
+func (l *sessionLog) replay(data []byte) (int, error) {
+	offset := 0
+	for offset < len(data) {
+		// read record
+		kind, next, dir, body, n := readRecord(data[offset:])
+		if n == 0 {
+			break
+		}
+
+		// apply record
+		err := l.apply(kind, next, dir, body)
+		if err != nil {
+			return offset, err
+		}
+
+		offset += n
+	}
+
+	return offset, nil
+}
+
+func (l *sessionLog) apply(kind byte, next packet.ID, dir Direction, body []byte) error {
+	// handle reset
+	if kind == recordReset {
+		l.counter.set(next)
+		l.incoming.Reset()
+		l.outgoing.Reset()
+		l.obsolete = 0
+		return nil
+	}
+
+	// check direction
+	if dir != Incoming && dir != Outgoing {
+		return ErrInvalidRecord
+	}
+
+	// get store
+	store := l.storeForDirection(dir)
+
+	// restore counter
+	l.counter.set(next)
+
+	switch kind {
+	case recordSave:
+		// detect packet
+		n, t := packet.DetectPacket(body)
+		if n != len(body) {
+			return ErrInvalidRecord
+		}
+
+		// create packet
+		pkt, err := t.New()
+		if err != nil {
+			return err
+		}
+
+		// decode packet
+		_, err = pkt.Decode(packet.Version5, body)
+		if err != nil {
+			return err
+		}
+
+		// get id
+		id, ok := packet.GetID(pkt)
+		if !ok {
+			return ErrInvalidRecord
+		}
+
+		// count overwritten packets
+		if store.Lookup(id) != nil {
+			l.obsolete++
+		}
+
+		// save packet
+		store.Save(pkt)
+	case recordDelete:
+		// check body
+		if len(body) != 2 {
+			return ErrInvalidRecord
+		}
+
+		// delete packet
+		store.Delete(packet.ID(binary.BigEndian.Uint16(body)))
+		l.obsolete += 2
+	default:
+		return ErrInvalidRecord
+	}
+
+	return nil
+}
+
+// encode appends a reset record with the counter position and a save record
+// for every packet in order
+func (l *sessionLog) encode(buf []byte) ([]byte, error) {
+	// add reset record to persist counter position
+	next := l.counter.peek()
+	buf = appendRecord(buf, recordReset, next, 0, nil)
+
+	// add packets
+	for _, dir := range []Direction{Incoming, Outgoing} {
+		for _, pkt := range l.storeForDirection(dir).All() {
+			// encode packet
+			data := make([]byte, pkt.Len(packet.Version5))
+			_, err := pkt.Encode(packet.Version5, data)
+			if err != nil {
+				return nil, err
+			}
+
+			// add record
+			buf = appendRecord(buf, recordSave, next, byte(dir), data)
+		}
+	}
+
+	return buf, nil
+}
+
+// export writes the counter position and all packets to the writer
+func (l *sessionLog) export(w io.Writer) error {
+	// encode log
+	buf, err := l.encode(nil)
+	if err != nil {
+		return err
+	}
+
+	// write log
+	_, err = w.Write(buf)
+
+	return err
+}
+
+// load replaces the counter position and all packets with the log read from
+// the reader that has been written using export
+func (l *sessionLog) load(r io.Reader) error {
+	// read log
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// replay log
+	log := newSessionLog()
+	offset, err := log.replay(data)
+	if err != nil {
+		return err
+	} else if offset < len(data) {
+		return ErrInvalidRecord
+	}
+
+	// replace counter position
+	l.counter.set(log.counter.peek())
+
+	// replace packets
+	for _, dir := range []Direction{Incoming, Outgoing} {
+		store := l.storeForDirection(dir)
+		store.Reset()
+		for _, pkt := range log.storeForDirection(dir).All() {
+			store.Save(pkt)
+		}
+	}
+
+	// reset obsolete records
+	l.obsolete = 0
+
+	return nil
+}
+
+func (l *sessionLog) storeForDirection(dir Direction) *PacketStore {
+	// check direction
+	if dir == Incoming {
+		return l.incoming
+	} else if dir == Outgoing {
+		return l.outgoing
+	}
+
+	panic("unknown direction")
+}
+
+func appendRecord(buf []byte, kind byte, next packet.ID, dir byte, body []byte) []byte {
+	// get offset
+	offset := len(buf)
+
+	// add header and body
+	buf = append(buf, make([]byte, recordHeaderLen)...)
+	buf = append(buf, kind, byte(next>>8), byte(next), dir)
+	buf = append(buf, body...)
+
+	// write length and checksum
+	binary.BigEndian.PutUint32(buf[offset:], uint32(len(buf)-offset-recordHeaderLen))
+	binary.BigEndian.PutUint32(buf[offset+4:], crc32.ChecksumIEEE(buf[offset+recordHeaderLen:]))
+
+	return buf
+}
+
+func readRecord(data []byte) (byte, packet.ID, Direction, []byte, int) {
+	// check header
+	if len(data) < recordHeaderLen {
+		return 0, 0, 0, nil, 0
+	}
+
+	// get length and checksum
+	length := int(binary.BigEndian.Uint32(data))
+	checksum := binary.BigEndian.Uint32(data[4:])
+
+	// check length
+	if length < 4 || len(data)-recordHeaderLen < length {
+		return 0, 0, 0, nil, 0
+	}
+
+	// get record
+	record := data[recordHeaderLen : recordHeaderLen+length]
+
+	// verify checksum
+	if crc32.ChecksumIEEE(record) != checksum {
+		return 0, 0, 0, nil, 0
+	}
+
+	// get fields
+	kind := record[0]
+	next := packet.ID(binary.BigEndian.Uint16(record[1:]))
+	dir := Direction(record[3])
+
+	return kind, next, dir, record[4:], recordHeaderLen + length
+}
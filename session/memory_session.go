@@ -3,9 +3,17 @@
 package session
 
 import (
+	"errors"
+	"io"
+
 	"github.com/256dpi/gomqtt/packet"
 )
 
+// ErrStreamedPacket is returned when a Publish packet with a PayloadReader is
+// saved. The payload can only be read once and the packet could therefore not
+// be resent.
+var ErrStreamedPacket = errors.New("streamed packet")
+
 // Direction denotes a packets direction.
 type Direction int
 
@@ -33,14 +41,28 @@ func NewMemorySession() *MemorySession {
 	}
 }
 
-// NextID will return the next id for outgoing packets.
+// NextID will return the next id for outgoing packets that is not in use. It
+// will block until an id becomes free if all ids are in use.
 func (s *MemorySession) NextID() packet.ID {
-	return s.Counter.NextID()
+	id, _ := NewIDAllocator(s.Counter, s.Outgoing).AllocateWait(nil)
+	return id
+}
+
+// AllocateID will return the next id for outgoing packets that is not in use.
+// It will return ErrNoFreeID if all ids are in use.
+func (s *MemorySession) AllocateID() (packet.ID, error) {
+	return NewIDAllocator(s.Counter, s.Outgoing).Allocate()
 }
 
 // SavePacket will store a packet in the session. An eventual existing
-// packet with the same id gets quietly overwritten.
+// packet with the same id gets quietly overwritten. Publish packets with a
+// PayloadReader are rejected with ErrStreamedPacket.
 func (s *MemorySession) SavePacket(dir Direction, pkt packet.Generic) error {
+	// check packet
+	if isStreamed(pkt) {
+		return ErrStreamedPacket
+	}
+
 	s.storeForDirection(dir).Save(pkt)
 	return nil
 }
@@ -57,7 +79,8 @@ func (s *MemorySession) DeletePacket(dir Direction, id packet.ID) error {
 	return nil
 }
 
-// AllPackets will return all packets currently saved in the session.
+// AllPackets will return all packets currently saved in the session in the
+// order they have been saved.
 func (s *MemorySession) AllPackets(dir Direction) ([]packet.Generic, error) {
 	return s.storeForDirection(dir).All(), nil
 }
@@ -72,6 +95,26 @@ func (s *MemorySession) Reset() error {
 	return nil
 }
 
+// Export will write the id counter position and all packets of the session in
+// order to the writer.
+func (s *MemorySession) Export(w io.Writer) error {
+	return s.log().export(w)
+}
+
+// Import will replace the id counter position and all packets of the session
+// with the data read from the reader that has been written using Export.
+func (s *MemorySession) Import(r io.Reader) error {
+	return s.log().load(r)
+}
+
+func (s *MemorySession) log() *sessionLog {
+	return &sessionLog{
+		counter:  s.Counter,
+		incoming: s.Incoming,
+		outgoing: s.Outgoing,
+	}
+}
+
 func (s *MemorySession) storeForDirection(dir Direction) *PacketStore {
 	// check direction
 	if dir == Incoming {
@@ -82,3 +125,9 @@ func (s *MemorySession) storeForDirection(dir Direction) *PacketStore {
 
 	panic("unknown direction")
 }
+
+// returns whether the packet is a publish packet with a streamed payload
+func isStreamed(pkt packet.Generic) bool {
+	publish, ok := pkt.(*packet.Publish)
+	return ok && publish.PayloadReader != nil
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"bytes"
 	"math"
 	"testing"
 
@@ -28,6 +29,25 @@ func TestMemorySessionNextID(t *testing.T) {
 	assert.Equal(t, packet.ID(1), session.NextID())
 }
 
+func TestMemorySessionNextIDInUse(t *testing.T) {
+	session := NewMemorySession()
+
+	err := session.SavePacket(Outgoing, &packet.Pubrel{ID: 1})
+	assert.NoError(t, err)
+
+	err = session.SavePacket(Outgoing, &packet.Pubrel{ID: 2})
+	assert.NoError(t, err)
+
+	err = session.SavePacket(Incoming, &packet.Pubrel{ID: 3})
+	assert.NoError(t, err)
+
+	assert.Equal(t, packet.ID(3), session.NextID())
+
+	id, err := session.AllocateID()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.ID(4), id)
+}
+
 func TestMemorySessionPacketStore(t *testing.T) {
 	session := NewMemorySession()
 
@@ -74,3 +94,118 @@ func TestMemorySessionPacketStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(list))
 }
+
+type orderedSession interface {
+	SavePacket(Direction, packet.Generic) error
+	DeletePacket(Direction, packet.ID) error
+	AllPackets(Direction) ([]packet.Generic, error)
+}
+
+func saveOrderedPackets(t *testing.T, session orderedSession) {
+	for _, dir := range []Direction{Incoming, Outgoing} {
+		for _, id := range []packet.ID{5, 3, 9, 1, 7} {
+			publish := packet.NewPublish()
+			publish.ID = id
+			publish.Message.Topic = "foo"
+			publish.Message.QOS = 2
+
+			err := session.SavePacket(dir, publish)
+			assert.NoError(t, err)
+		}
+
+		err := session.SavePacket(dir, &packet.Pubrel{ID: 9})
+		assert.NoError(t, err)
+
+		err = session.DeletePacket(dir, 3)
+		assert.NoError(t, err)
+
+		err = session.SavePacket(dir, &packet.Pubrel{ID: 3})
+		assert.NoError(t, err)
+	}
+}
+
+func verifyOrderedPackets(t *testing.T, session orderedSession) {
+	for _, dir := range []Direction{Incoming, Outgoing} {
+		pkts, err := session.AllPackets(dir)
+		assert.NoError(t, err)
+
+		var ids []packet.ID
+		var types []packet.Type
+		for _, pkt := range pkts {
+			id, _ := packet.GetID(pkt)
+			ids = append(ids, id)
+			types = append(types, pkt.Type())
+		}
+
+		assert.Equal(t, []packet.ID{5, 9, 1, 7, 3}, ids)
+		assert.Equal(t, []packet.Type{packet.PUBLISH, packet.PUBREL, packet.PUBLISH, packet.PUBLISH, packet.PUBREL}, types)
+	}
+}
+
+func TestMemorySessionOrder(t *testing.T) {
+	session := NewMemorySession()
+
+	saveOrderedPackets(t, session)
+	verifyOrderedPackets(t, session)
+}
+
+func TestMemorySessionStreamedPacket(t *testing.T) {
+	session := NewMemorySession()
+
+	publish := packet.NewPublish()
+	publish.ID = 1
+	publish.PayloadReader = bytes.NewReader([]byte("foo"))
+	publish.PayloadLength = 3
+
+	err := session.SavePacket(Outgoing, publish)
+	assert.Equal(t, ErrStreamedPacket, err)
+
+	pkt, err := session.LookupPacket(Outgoing, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, pkt)
+}
+
+func TestMemorySessionExportImport(t *testing.T) {
+	session1 := NewMemorySession()
+	saveOrderedPackets(t, session1)
+
+	assert.Equal(t, packet.ID(2), session1.NextID())
+	assert.Equal(t, packet.ID(4), session1.NextID())
+
+	var buf bytes.Buffer
+	err := session1.Export(&buf)
+	assert.NoError(t, err)
+
+	session2 := NewMemorySession()
+	err = session2.SavePacket(Outgoing, &packet.Pubrel{ID: 42})
+	assert.NoError(t, err)
+
+	err = session2.Import(&buf)
+	assert.NoError(t, err)
+	verifyOrderedPackets(t, session2)
+	assert.Equal(t, packet.ID(6), session2.NextID())
+
+	pkt, err := session2.LookupPacket(Outgoing, 42)
+	assert.NoError(t, err)
+	assert.Nil(t, pkt)
+}
+
+func TestMemorySessionImportInvalid(t *testing.T) {
+	session1 := NewMemorySession()
+	saveOrderedPackets(t, session1)
+
+	var buf bytes.Buffer
+	err := session1.Export(&buf)
+	assert.NoError(t, err)
+
+	session2 := NewMemorySession()
+	err = session2.SavePacket(Outgoing, &packet.Pubrel{ID: 42})
+	assert.NoError(t, err)
+
+	err = session2.Import(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Equal(t, ErrInvalidRecord, err)
+
+	pkt, err := session2.LookupPacket(Outgoing, 42)
+	assert.NoError(t, err)
+	assert.NotNil(t, pkt)
+}
@@ -1,30 +1,33 @@
 package session
 
 import (
+	"container/list"
 	"sync"
 
 	"github.com/256dpi/gomqtt/packet"
 )
 
-// PacketStore is a goroutine safe packet store.
+// PacketStore is a goroutine safe packet store that retains the order in which
+// packets have been added.
 type PacketStore struct {
-	packets map[packet.ID]packet.Generic
+	packets map[packet.ID]*list.Element
+	order   *list.List
+	signal  chan struct{}
 	mutex   sync.RWMutex
 }
 
 // NewPacketStore returns a new PacketStore.
 func NewPacketStore() *PacketStore {
 	return &PacketStore{
-		packets: make(map[packet.ID]packet.Generic),
+		packets: make(map[packet.ID]*list.Element),
+		order:   list.New(),
 	}
 }
 
 // NewPacketStoreWithPackets returns a new PacketStore with the provided packets.
 func NewPacketStoreWithPackets(packets []packet.Generic) *PacketStore {
 	// prepare store
-	store := &PacketStore{
-		packets: make(map[packet.ID]packet.Generic),
-	}
+	store := NewPacketStore()
 
 	// add packets
 	for _, pkt := range packets {
@@ -35,15 +38,25 @@ func NewPacketStoreWithPackets(packets []packet.Generic) *PacketStore {
 }
 
 // Save will store a packet in the store. An eventual existing packet with the
-// same id gets quietly overwritten.
+// same id gets quietly overwritten and retains its position.
 func (s *PacketStore) Save(pkt packet.Generic) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	// get id
 	id, ok := packet.GetID(pkt)
-	if ok {
-		s.packets[id] = pkt
+	if !ok {
+		return
+	}
+
+	// overwrite existing packet
+	if element, ok := s.packets[id]; ok {
+		element.Value = pkt
+		return
 	}
+
+	// add packet
+	s.packets[id] = s.order.PushBack(pkt)
 }
 
 // Lookup will retrieve a packet from the store.
@@ -52,7 +65,11 @@ func (s *PacketStore) Lookup(id packet.ID) packet.Generic {
 	defer s.mutex.RUnlock()
 
 	// get packet
-	return s.packets[id]
+	if element, ok := s.packets[id]; ok {
+		return element.Value.(packet.Generic)
+	}
+
+	return nil
 }
 
 // Delete will remove a packet from the store.
@@ -61,18 +78,23 @@ func (s *PacketStore) Delete(id packet.ID) {
 	defer s.mutex.Unlock()
 
 	// delete packet
-	delete(s.packets, id)
+	if element, ok := s.packets[id]; ok {
+		s.order.Remove(element)
+		delete(s.packets, id)
+		s.notify()
+	}
 }
 
-// All will return all packets currently saved in the store.
+// All will return all packets currently saved in the store in the order they
+// have been added.
 func (s *PacketStore) All() []packet.Generic {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	// collect packets
 	var all []packet.Generic
-	for _, pkt := range s.packets {
-		all = append(all, pkt)
+	for element := s.order.Front(); element != nil; element = element.Next() {
+		all = append(all, element.Value.(packet.Generic))
 	}
 
 	return all
@@ -84,5 +106,36 @@ func (s *PacketStore) Reset() {
 	defer s.mutex.Unlock()
 
 	// reset packets
-	s.packets = make(map[packet.ID]packet.Generic)
+	s.packets = make(map[packet.ID]*list.Element)
+	s.order = list.New()
+	s.notify()
+}
+
+// returns the number of stored packets
+func (s *PacketStore) len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.packets)
+}
+
+// returns a channel that is closed when packets are deleted
+func (s *PacketStore) freed() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// create signal
+	if s.signal == nil {
+		s.signal = make(chan struct{})
+	}
+
+	return s.signal
+}
+
+// closes the signal channel, must be called with the lock held
+func (s *PacketStore) notify() {
+	if s.signal != nil {
+		close(s.signal)
+		s.signal = nil
+	}
 }
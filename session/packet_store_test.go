@@ -36,3 +36,33 @@ func TestPacketStore(t *testing.T) {
 	store = NewPacketStoreWithPackets([]packet.Generic{&packet.Subscribe{ID: 7}})
 	assert.Equal(t, []packet.Generic{&packet.Subscribe{ID: 7}}, store.All())
 }
+
+func TestPacketStoreOrder(t *testing.T) {
+	store := NewPacketStore()
+
+	for i := 1; i <= 100; i++ {
+		store.Save(&packet.Publish{ID: packet.ID(101 - i)})
+	}
+
+	store.Save(&packet.Pubrel{ID: 50})
+	store.Delete(10)
+	store.Save(&packet.Publish{ID: 10})
+
+	pkts := store.All()
+	assert.Len(t, pkts, 100)
+
+	for i, pkt := range pkts[:99] {
+		id := packet.ID(100 - i)
+		if id <= 10 {
+			id--
+		}
+
+		if id == 50 {
+			assert.Equal(t, &packet.Pubrel{ID: 50}, pkt)
+		} else {
+			assert.Equal(t, &packet.Publish{ID: id}, pkt)
+		}
+	}
+
+	assert.Equal(t, &packet.Publish{ID: 10}, pkts[99])
+}
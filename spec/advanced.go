@@ -1,6 +1,8 @@
 package spec
 
 import (
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -152,3 +154,69 @@ func RootSlashDistinctionTest(t *testing.T, config *Config, topic string) {
 	err = c.Disconnect()
 	assert.NoError(t, err)
 }
+
+// ReservedTopicsTest tests the broker for not matching topics that start with
+// a "$" against subscriptions that start with a wildcard.
+func ReservedTopicsTest(t *testing.T, config *Config, topic string) {
+	c := client.New()
+	wait := make(chan struct{})
+
+	// brokers may deliver a copy per overlapping subscription
+	var once sync.Once
+
+	c.Callback = func(msg *packet.Message, err error) error {
+		assert.NoError(t, err)
+
+		// ignore retained messages from other tests
+		if msg.Retain {
+			return nil
+		}
+
+		// reserved topics must not match
+		if strings.HasPrefix(msg.Topic, "$") {
+			assert.Fail(t, "received message on reserved topic", msg.Topic)
+			return nil
+		}
+
+		assert.Equal(t, topic+"/foo", msg.Topic)
+		assert.Equal(t, testPayload, msg.Payload)
+		assert.Equal(t, packet.QOS(0), msg.QOS)
+
+		once.Do(func() {
+			close(wait)
+		})
+
+		return nil
+	}
+
+	cf, err := c.Connect(client.NewConfig(config.URL))
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+	assert.Equal(t, packet.ConnectionAccepted, cf.ReturnCode())
+	assert.False(t, cf.SessionPresent())
+
+	sf, err := c.Subscribe("#", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sf.Wait(10*time.Second))
+	assert.Equal(t, []packet.QOS{0}, sf.ReturnCodes())
+
+	sf, err = c.Subscribe("+/foo", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, sf.Wait(10*time.Second))
+	assert.Equal(t, []packet.QOS{0}, sf.ReturnCodes())
+
+	pf, err := c.Publish("$"+topic+"/foo", testPayload, 0, false)
+	assert.NoError(t, err)
+	assert.NoError(t, pf.Wait(10*time.Second))
+
+	pf, err = c.Publish(topic+"/foo", testPayload, 0, false)
+	assert.NoError(t, err)
+	assert.NoError(t, pf.Wait(10*time.Second))
+
+	safeReceive(wait)
+
+	time.Sleep(config.NoMessageWait)
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+}
@@ -23,6 +23,7 @@ type Config struct {
 	Authentication       bool
 	UniqueClientIDs      bool
 	RootSlashDistinction bool
+	ReservedTopics       bool
 
 	// ProcessWait defines the time some tests should wait and let the broker
 	// finish processing (e.g. properly terminating a connection)
@@ -49,6 +50,7 @@ func AllFeatures() *Config {
 		Authentication:       true,
 		UniqueClientIDs:      true,
 		RootSlashDistinction: true,
+		ReservedTopics:       true,
 	}
 }
 
@@ -349,4 +351,10 @@ func Run(t *testing.T, config *Config) {
 			RootSlashDistinctionTest(t, config, "rootslash")
 		})
 	}
+
+	if config.ReservedTopics {
+		t.Run("ReservedTopics", func(t *testing.T) {
+			ReservedTopicsTest(t, config, "reserved")
+		})
+	}
 }
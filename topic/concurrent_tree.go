@@ -0,0 +1,537 @@
+package topic
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// A ConcurrentTree implements a thread-safe topic tree that is optimized for
+// concurrent reads. Modifications copy the changed path of the tree and publish
+// a new immutable version, which allows reads to proceed without locking but
+// makes modifications more expensive than with Tree. Results of Match are
+// cached in a LRU cache that is invalidated precisely on modifications.
+//
+// Note: Slices returned by Match and Get are shared and must not be modified.
+type ConcurrentTree struct {
+	snapshot atomic.Value
+	cache    *matchCache
+	mutex    sync.Mutex
+}
+
+// NewConcurrentTree returns a new ConcurrentTree using the specified separator
+// and wildcards that caches the matches of up to cacheSize topics.
+func NewConcurrentTree(separator, wildcardOne, wildcardSome string, cacheSize int) *ConcurrentTree {
+	// prepare tree
+	t := &ConcurrentTree{
+		cache: newMatchCache(cacheSize),
+	}
+
+	// store initial snapshot
+	t.snapshot.Store(NewTree(separator, wildcardOne, wildcardSome))
+
+	return t
+}
+
+// NewStandardConcurrentTree returns a new ConcurrentTree using the standard
+// MQTT separator and wildcards. Topics starting with "$" are reserved.
+func NewStandardConcurrentTree(cacheSize int) *ConcurrentTree {
+	tree := NewConcurrentTree("/", "+", "#", cacheSize)
+	tree.Reserve("$")
+	return tree
+}
+
+// Reserve sets the prefixes of reserved topics. Reserved topics are not
+// matched by filters that start with a wildcard.
+func (t *ConcurrentTree) Reserve(prefixes ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// store snapshot with new prefixes
+	snapshot := t.load().derive(t.load().root)
+	snapshot.reserved = prefixes
+	t.snapshot.Store(snapshot)
+
+	// reset cache
+	t.cache.reset()
+}
+
+// Add registers the value for the supplied topic. This function will
+// automatically grow the tree. If value already exists for the given topic it
+// will not be added again.
+func (t *ConcurrentTree) Add(topic string, value interface{}) {
+	t.update(topic, func(values []interface{}) []interface{} {
+		// check if duplicate
+		if contains(values, value) {
+			return values
+		}
+
+		// add value to a copy
+		return append(append(make([]interface{}, 0, len(values)+1), values...), value)
+	})
+}
+
+// Set sets the supplied value as the only value for the supplied topic. This
+// function will automatically grow the tree.
+func (t *ConcurrentTree) Set(topic string, value interface{}) {
+	t.update(topic, func([]interface{}) []interface{} {
+		return []interface{}{value}
+	})
+}
+
+// Get gets the values from the topic that exactly matches the supplied topics.
+func (t *ConcurrentTree) Get(topic string) []interface{} {
+	// get snapshot
+	snapshot := t.load()
+
+	return snapshot.get(topic, snapshot.root)
+}
+
+// Remove un-registers the value from the supplied topic. This function will
+// automatically shrink the tree.
+func (t *ConcurrentTree) Remove(topic string, value interface{}) {
+	t.update(topic, func(values []interface{}) []interface{} {
+		return without(values, value)
+	})
+}
+
+// Empty will unregister all values from the supplied topic. This function will
+// automatically shrink the tree.
+func (t *ConcurrentTree) Empty(topic string) {
+	t.update(topic, func([]interface{}) []interface{} {
+		return nil
+	})
+}
+
+// Clear will unregister the supplied value from all topics. This function will
+// automatically shrink the tree.
+func (t *ConcurrentTree) Clear(value interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get snapshot
+	snapshot := t.load()
+
+	// clear value
+	root, changed := t.clear(value, snapshot.root)
+	if !changed {
+		return
+	}
+
+	// store snapshot
+	t.snapshot.Store(snapshot.derive(root))
+
+	// invalidate matches that contain the value
+	t.cache.invalidate(func(_ string, values []interface{}) bool {
+		return contains(values, value)
+	})
+}
+
+func (t *ConcurrentTree) clear(value interface{}, n *node) (*node, bool) {
+	// prepare copy
+	var cpy *node
+
+	// remove value
+	if contains(n.values, value) {
+		cpy = n.copy()
+		cpy.values = without(n.values, value)
+	}
+
+	// remove value from all children and remove empty nodes
+	for segment, child := range n.children {
+		// clear child
+		child, changed := t.clear(value, child)
+		if !changed {
+			continue
+		}
+
+		// copy node
+		if cpy == nil {
+			cpy = n.copy()
+		}
+
+		// update or remove child
+		if child.empty() {
+			delete(cpy.children, segment)
+		} else {
+			cpy.children[segment] = child
+		}
+	}
+
+	// check copy
+	if cpy == nil {
+		return n, false
+	}
+
+	return cpy, true
+}
+
+// Match will return a set of values from topics that match the supplied topic.
+// The result set will be cleared from duplicate values.
+//
+// Note: In contrast to Search, Match does not respect wildcards in the query but
+// in the stored tree. Reserved topics are not matched by stored topics that
+// start with a wildcard.
+func (t *ConcurrentTree) Match(topic string) []interface{} {
+	// check cache
+	values, epoch, ok := t.cache.get(topic)
+	if ok {
+		return values
+	}
+
+	// get snapshot
+	snapshot := t.load()
+
+	// match values
+	var list []interface{}
+	snapshot.match(topic, snapshot.root, func(values []interface{}) bool {
+		list = append(list, values...)
+		return true
+	})
+
+	// clean values
+	list = snapshot.clean(list)
+
+	// cache values
+	t.cache.put(topic, list, epoch)
+
+	return list
+}
+
+// MatchFirst behaves similar to Match but only returns the first found value.
+func (t *ConcurrentTree) MatchFirst(topic string) interface{} {
+	// check cache
+	values, _, ok := t.cache.get(topic)
+	if ok {
+		if len(values) > 0 {
+			return values[0]
+		}
+
+		return nil
+	}
+
+	// get snapshot
+	snapshot := t.load()
+
+	// match values
+	var value interface{}
+	snapshot.match(topic, snapshot.root, func(values []interface{}) bool {
+		value = values[0]
+		return false
+	})
+
+	return value
+}
+
+// MatchReduce folds all values from topics that match the supplied topic using
+// the reducer and returns the result. The reducer is called with the current
+// result, starting with the initial value, and a matched value. Duplicate
+// values are omitted.
+func (t *ConcurrentTree) MatchReduce(topic string, initial interface{}, fn func(result, value interface{}) interface{}) interface{} {
+	// reduce values
+	result := initial
+	for _, value := range t.Match(topic) {
+		result = fn(result, value)
+	}
+
+	return result
+}
+
+// MatchEach will call the callback with every value from topics that match the
+// supplied topic until it returns false. Duplicate values are omitted. Matches
+// that are cached are iterated without allocating.
+func (t *ConcurrentTree) MatchEach(topic string, fn func(interface{}) bool) {
+	for _, value := range t.Match(topic) {
+		if !fn(value) {
+			return
+		}
+	}
+}
+
+// Search will return a set of values from topics that match the supplied topic.
+// The result set will be cleared from duplicate values.
+//
+// Note: In contrast to Match, Search respects wildcards in the query but not in
+// the stored tree. Queries that start with a wildcard do not return reserved
+// topics.
+func (t *ConcurrentTree) Search(topic string) []interface{} {
+	// get snapshot
+	snapshot := t.load()
+
+	// search values
+	var list []interface{}
+	snapshot.search(topic, snapshot.root, func(values []interface{}) bool {
+		list = append(list, values...)
+		return true
+	})
+
+	return snapshot.clean(list)
+}
+
+// SearchFirst behaves similar to Search but only returns the first found value.
+func (t *ConcurrentTree) SearchFirst(topic string) interface{} {
+	// get snapshot
+	snapshot := t.load()
+
+	// search values
+	var value interface{}
+	snapshot.search(topic, snapshot.root, func(values []interface{}) bool {
+		value = values[0]
+		return false
+	})
+
+	return value
+}
+
+// Count will count all stored values in the tree. It will not filter out
+// duplicate values and thus might return a different result to `len(All())`.
+func (t *ConcurrentTree) Count() int {
+	// get snapshot
+	snapshot := t.load()
+
+	return snapshot.count(snapshot.root)
+}
+
+// All will return all stored values in the tree.
+func (t *ConcurrentTree) All() []interface{} {
+	// get snapshot
+	snapshot := t.load()
+
+	return snapshot.clean(snapshot.all([]interface{}{}, snapshot.root))
+}
+
+// Reset will completely clear the tree.
+func (t *ConcurrentTree) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// store empty snapshot
+	t.snapshot.Store(t.load().derive(newNode()))
+
+	// reset cache
+	t.cache.reset()
+}
+
+// String will return a string representation of the tree structure. The number
+// following the nodes show the number of stored values at that level.
+func (t *ConcurrentTree) String() string {
+	return fmt.Sprintf("topic.ConcurrentTree:%s", t.load().root.string(0))
+}
+
+func (t *ConcurrentTree) load() *Tree {
+	return t.snapshot.Load().(*Tree)
+}
+
+func (t *ConcurrentTree) update(topic string, fn func([]interface{}) []interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get snapshot
+	snapshot := t.load()
+
+	// store snapshot with updated copy of the path
+	t.snapshot.Store(snapshot.derive(t.copyPath(snapshot, topic, snapshot.root, fn)))
+
+	// invalidate matches of the topic
+	t.cache.invalidate(func(cached string, _ []interface{}) bool {
+		return snapshot.matches(topic, cached)
+	})
+}
+
+func (t *ConcurrentTree) copyPath(snapshot *Tree, topic string, n *node, fn func([]interface{}) []interface{}) *node {
+	// copy node
+	cpy := n.copy()
+
+	// update values of leaf
+	if topic == topicEnd {
+		cpy.values = fn(cpy.values)
+		return cpy
+	}
+
+	// get segment
+	segment := topicSegment(topic, snapshot.separator)
+
+	// get child
+	child, ok := n.children[segment]
+	if !ok {
+		child = newNode()
+	}
+
+	// descend
+	child = t.copyPath(snapshot, topicShorten(topic, snapshot.separator), child, fn)
+
+	// update or remove child
+	if child.empty() {
+		delete(cpy.children, segment)
+	} else {
+		cpy.children[segment] = child
+	}
+
+	return cpy
+}
+
+// derive returns a tree with the same configuration and the supplied root
+func (t *Tree) derive(root *node) *Tree {
+	return &Tree{
+		separator:    t.separator,
+		wildcardOne:  t.wildcardOne,
+		wildcardSome: t.wildcardSome,
+		reserved:     t.reserved,
+		root:         root,
+	}
+}
+
+// matches returns whether the filter matches the topic
+func (t *Tree) matches(filter, topic string) bool {
+	// check reserved
+	reserved := t.isReserved(topic)
+
+	for first := true; ; first = false {
+		// get segment
+		segment := topicSegment(filter, t.separator)
+
+		// the multi level wildcard matches all remaining levels
+		if filter != topicEnd && segment == t.wildcardSome {
+			return !first || !reserved
+		}
+
+		// check end
+		if filter == topicEnd || topic == topicEnd {
+			return filter == topicEnd && topic == topicEnd
+		}
+
+		// check segment
+		if segment == t.wildcardOne {
+			if first && reserved {
+				return false
+			}
+		} else if segment != topicSegment(topic, t.separator) {
+			return false
+		}
+
+		// advance
+		filter = topicShorten(filter, t.separator)
+		topic = topicShorten(topic, t.separator)
+	}
+}
+
+func (n *node) copy() *node {
+	// copy children
+	cpy := newNode()
+	for segment, child := range n.children {
+		cpy.children[segment] = child
+	}
+
+	// share values as they are never modified in place
+	cpy.values = n.values
+	cpy.shared = n.shared
+
+	return cpy
+}
+
+func without(values []interface{}, value interface{}) []interface{} {
+	// prepare result
+	var result []interface{}
+
+	// copy other values
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+type matchEntry struct {
+	topic  string
+	values []interface{}
+}
+
+type matchCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	epoch   uint64
+	mutex   sync.Mutex
+}
+
+func newMatchCache(size int) *matchCache {
+	return &matchCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *matchCache) get(topic string) ([]interface{}, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get entry
+	element, ok := c.entries[topic]
+	if !ok {
+		return nil, c.epoch, false
+	}
+
+	// mark as recently used
+	c.order.MoveToFront(element)
+
+	return element.Value.(*matchEntry).values, c.epoch, true
+}
+
+func (c *matchCache) put(topic string, values []interface{}, epoch uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// skip if disabled or invalidated since the lookup
+	if c.size <= 0 || c.epoch != epoch {
+		return
+	}
+
+	// update existing entry
+	if element, ok := c.entries[topic]; ok {
+		element.Value.(*matchEntry).values = values
+		c.order.MoveToFront(element)
+		return
+	}
+
+	// evict least recently used entry
+	if c.order.Len() >= c.size {
+		element := c.order.Back()
+		c.order.Remove(element)
+		delete(c.entries, element.Value.(*matchEntry).topic)
+	}
+
+	// add entry
+	c.entries[topic] = c.order.PushFront(&matchEntry{topic: topic, values: values})
+}
+
+func (c *matchCache) invalidate(fn func(string, []interface{}) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// remove matching entries
+	for topic, element := range c.entries {
+		if fn(topic, element.Value.(*matchEntry).values) {
+			c.order.Remove(element)
+			delete(c.entries, topic)
+		}
+	}
+
+	// increment epoch
+	c.epoch++
+}
+
+func (c *matchCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// remove all entries
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+
+	// increment epoch
+	c.epoch++
+}
@@ -0,0 +1,385 @@
+package topic
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentTreeAdd(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+
+	assert.Equal(t, []interface{}{1, 2}, tree.Get("foo/bar"))
+	assert.Equal(t, 2, tree.Count())
+}
+
+func TestConcurrentTreeSet(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Set("foo/bar", 1)
+	tree.Set("foo/bar", 2)
+
+	assert.Equal(t, []interface{}{2}, tree.Get("foo/bar"))
+}
+
+func TestConcurrentTreeRemove(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+	tree.Remove("foo/bar", 1)
+
+	assert.Equal(t, []interface{}{2}, tree.Get("foo/bar"))
+
+	tree.Remove("foo/bar", 2)
+	tree.Remove("bar/baz", 2)
+
+	assert.Equal(t, 0, len(tree.load().root.children))
+}
+
+func TestConcurrentTreeEmpty(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+	tree.Empty("foo/bar")
+
+	assert.Equal(t, 0, len(tree.load().root.children))
+}
+
+func TestConcurrentTreeClear(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar/baz", 1)
+	tree.Add("foo/baz", 2)
+	tree.Clear(1)
+
+	assert.Equal(t, []interface{}{2}, tree.All())
+	assert.Equal(t, "topic.ConcurrentTree:\n| 'foo' => 0\n|   'baz' => 1", tree.String())
+}
+
+func TestConcurrentTreeSnapshot(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+
+	snapshot := tree.load()
+
+	tree.Add("foo/bar", 2)
+	tree.Add("foo/baz", 3)
+	tree.Clear(1)
+
+	assert.Equal(t, []interface{}{1}, snapshot.get("foo/bar", snapshot.root))
+	assert.Equal(t, 1, snapshot.count(snapshot.root))
+	assert.Equal(t, []interface{}{2}, tree.Get("foo/bar"))
+}
+
+func TestConcurrentTreeMatch(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/+", 2)
+	tree.Add("foo/#", 3)
+	tree.Add("#", 3)
+	tree.Add("$SYS/#", 4)
+
+	assert.ElementsMatch(t, []interface{}{1, 2, 3}, tree.Match("foo/bar"))
+	assert.ElementsMatch(t, []interface{}{3}, tree.Match("foo"))
+	assert.ElementsMatch(t, []interface{}{4}, tree.Match("$SYS/foo"))
+	assert.Equal(t, []interface{}{3}, tree.Match("bar/baz/qux/$SYS"))
+	assert.Nil(t, tree.MatchFirst("$OTHER/foo"))
+	assert.NotNil(t, tree.MatchFirst("foo/bar"))
+	assert.Equal(t, 4, tree.MatchFirst("$SYS/foo"))
+}
+
+func TestConcurrentTreeMatchCache(t *testing.T) {
+	tree := NewStandardConcurrentTree(2)
+
+	tree.Add("foo/+", 1)
+
+	assert.Equal(t, []interface{}{1}, tree.Match("foo/bar"))
+	assert.Equal(t, []interface{}{1}, tree.Match("foo/baz"))
+	assert.Len(t, tree.cache.entries, 2)
+
+	tree.Add("bar", 2)
+	assert.Len(t, tree.cache.entries, 2)
+
+	tree.Add("foo/bar", 3)
+	assert.Len(t, tree.cache.entries, 1)
+	assert.ElementsMatch(t, []interface{}{1, 3}, tree.Match("foo/bar"))
+
+	tree.Remove("foo/#", 4)
+	assert.Len(t, tree.cache.entries, 0)
+
+	assert.Equal(t, []interface{}{1}, tree.Match("foo/baz"))
+	assert.Equal(t, []interface{}{2}, tree.Match("bar"))
+	assert.ElementsMatch(t, []interface{}{1, 3}, tree.Match("foo/bar"))
+	assert.Len(t, tree.cache.entries, 2)
+	assert.Contains(t, tree.cache.entries, "foo/bar")
+	assert.Contains(t, tree.cache.entries, "bar")
+
+	tree.Clear(2)
+	assert.Len(t, tree.cache.entries, 1)
+	assert.Contains(t, tree.cache.entries, "foo/bar")
+	assert.Empty(t, tree.Match("bar"))
+
+	tree.Reset()
+	assert.Len(t, tree.cache.entries, 0)
+	assert.Empty(t, tree.Match("foo/bar"))
+}
+
+func TestConcurrentTreeMatchNoCache(t *testing.T) {
+	tree := NewStandardConcurrentTree(0)
+
+	tree.Add("foo/+", 1)
+
+	assert.Equal(t, []interface{}{1}, tree.Match("foo/bar"))
+	assert.Len(t, tree.cache.entries, 0)
+}
+
+func TestConcurrentTreeMatchEach(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/+", 1)
+	tree.Add("foo/#", 1)
+	tree.Add("foo/bar", 2)
+
+	var values []interface{}
+	tree.MatchEach("foo/bar", func(value interface{}) bool {
+		values = append(values, value)
+		return true
+	})
+	assert.ElementsMatch(t, []interface{}{1, 2}, values)
+
+	counter := 0
+	tree.MatchEach("foo/bar", func(value interface{}) bool {
+		counter++
+		return false
+	})
+	assert.Equal(t, 1, counter)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		tree.MatchEach("foo/bar", func(value interface{}) bool {
+			return true
+		})
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestConcurrentTreeMatchReduce(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("a/#", 1)
+	tree.Add("a/+/c", 2)
+	tree.Add("a/b/c", 1)
+
+	sum := func(result, value interface{}) interface{} {
+		return result.(int) + value.(int)
+	}
+
+	assert.Equal(t, 3, tree.MatchReduce("a/b/c", 0, sum))
+	assert.Equal(t, 3, tree.MatchReduce("a/b/c", 0, sum))
+	assert.Equal(t, 0, tree.MatchReduce("b", 0, sum))
+}
+
+func TestConcurrentTreeSearch(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/baz", 2)
+	tree.Add("$SYS/foo", 3)
+
+	assert.ElementsMatch(t, []interface{}{1, 2}, tree.Search("foo/+"))
+	assert.ElementsMatch(t, []interface{}{1, 2}, tree.Search("#"))
+	assert.ElementsMatch(t, []interface{}{3}, tree.Search("$SYS/#"))
+	assert.NotNil(t, tree.SearchFirst("foo/#"))
+	assert.Nil(t, tree.SearchFirst("bar/#"))
+}
+
+func TestConcurrentTreeReserve(t *testing.T) {
+	tree := NewConcurrentTree("/", "+", "#", 10)
+
+	tree.Add("#", 1)
+
+	assert.Equal(t, []interface{}{1}, tree.Match("_internal/foo"))
+
+	tree.Reserve("_")
+
+	assert.Empty(t, tree.Match("_internal/foo"))
+}
+
+func TestConcurrentTreeParity(t *testing.T) {
+	tree1 := NewStandardTree()
+	tree2 := NewStandardConcurrentTree(100)
+
+	segments := []string{"a", "b", "+", "#", "$c"}
+	topics := []string{"a", "b", "a/b", "b/a", "a/a/a", "$c/a", "a/$c"}
+
+	filter := func() string {
+		n := 1 + rand.Intn(3)
+		str := segments[rand.Intn(len(segments))]
+		for i := 1; i < n && str != "#"; i++ {
+			str += "/" + segments[rand.Intn(len(segments))]
+		}
+		return str
+	}
+
+	for i := 0; i < 2000; i++ {
+		f := filter()
+		v := rand.Intn(5)
+
+		switch rand.Intn(5) {
+		case 0, 1:
+			tree1.Add(f, v)
+			tree2.Add(f, v)
+		case 2:
+			tree1.Set(f, v)
+			tree2.Set(f, v)
+		case 3:
+			tree1.Remove(f, v)
+			tree2.Remove(f, v)
+		case 4:
+			tree1.Clear(v)
+			tree2.Clear(v)
+		}
+
+		for _, topic := range topics {
+			assert.ElementsMatch(t, tree1.Match(topic), tree2.Match(topic), topic)
+		}
+	}
+
+	assert.Equal(t, tree1.Count(), tree2.Count())
+}
+
+func TestConcurrentTreeConcurrency(t *testing.T) {
+	tree := NewStandardConcurrentTree(10)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				tree.Add(fmt.Sprintf("foo/%d", j%10), i)
+				tree.Remove(fmt.Sprintf("foo/%d", j%10), i)
+			}
+
+			tree.Add("foo/+", i)
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				tree.Match(fmt.Sprintf("foo/%d", j%10))
+				tree.MatchFirst("foo/bar")
+				tree.Search("foo/#")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.ElementsMatch(t, []interface{}{0, 1, 2, 3}, tree.Match("foo/1"))
+}
+
+func benchmarkTrees(b *testing.B, fn func(b *testing.B, add func(string, interface{}), match func(string) []interface{})) {
+	b.Run("Tree", func(b *testing.B) {
+		tree := NewStandardTree()
+		fn(b, tree.Add, tree.Match)
+	})
+
+	b.Run("ConcurrentTree", func(b *testing.B) {
+		tree := NewStandardConcurrentTree(1000)
+		fn(b, tree.Add, tree.Match)
+	})
+}
+
+func BenchmarkConcurrentTreeMatchExact(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, add func(string, interface{}), match func(string) []interface{}) {
+		add("foo/bar", 1)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			match("foo/bar")
+		}
+	})
+}
+
+func BenchmarkConcurrentTreeMatchWildcardSome(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, add func(string, interface{}), match func(string) []interface{}) {
+		add("#", 1)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			match("foo/bar")
+		}
+	})
+}
+
+func BenchmarkConcurrentTreeMatchLarge(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, add func(string, interface{}), match func(string) []interface{}) {
+		for i := 0; i < 100000; i++ {
+			add(fmt.Sprintf("%d/%d/+", i%100, i%1000), i)
+			add(fmt.Sprintf("%d/#", i%100), i%10)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			match(fmt.Sprintf("%d/%d/foo", i%10, i%10))
+		}
+	})
+}
+
+func BenchmarkConcurrentTreeMatchParallel(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, add func(string, interface{}), match func(string) []interface{}) {
+		for i := 0; i < 100000; i++ {
+			add(fmt.Sprintf("%d/%d/+", i%100, i%1000), i)
+			add(fmt.Sprintf("%d/#", i%100), i%10)
+		}
+
+		topics := make([]string, 100)
+		for i := range topics {
+			topics[i] = fmt.Sprintf("%d/%d/foo", i%10, i%10)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				match(topics[i%len(topics)])
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkConcurrentTreeAdd(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, add func(string, interface{}), match func(string) []interface{}) {
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			add(fmt.Sprintf("foo/%d", i%1000), i)
+		}
+	})
+}
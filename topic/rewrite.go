@@ -0,0 +1,288 @@
+package topic
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrRule is returned by NewRewriter if a rule is invalid.
+var ErrRule = errors.New("invalid rewrite rule")
+
+// A Rule maps topics that match the From filter to the To template. The levels
+// matched by wildcards in the From filter are captured and can be referenced
+// in order of appearance using "$1", "$2" etc. as whole levels in the To
+// template. Every capture must be referenced exactly once and captures of
+// multi-level wildcards must be referenced by the last level of the template.
+// This ensures that every rule can be reversed.
+//
+// For example, the rule "dev/+/temp" → "sensors/$1/temperature" rewrites
+// "dev/1/temp" to "sensors/1/temperature" and the reversed rule rewrites
+// "sensors/1/temperature" back to "dev/1/temp".
+type Rule struct {
+	From string
+	To   string
+}
+
+// MountPoint returns a rule that prefixes all topics with the specified prefix.
+func MountPoint(prefix string) Rule {
+	return Rule{
+		From: "#",
+		To:   strings.TrimRight(prefix, "/") + "/$1",
+	}
+}
+
+type rewrite struct {
+	from []string
+	to   []string
+	refs []int
+}
+
+// A Rewriter applies a list of compiled rules to topics and filters.
+type Rewriter struct {
+	forward []rewrite
+	reverse []rewrite
+}
+
+// NewRewriter compiles the specified rules and returns a Rewriter.
+func NewRewriter(rules ...Rule) (*Rewriter, error) {
+	// prepare rewriter
+	r := &Rewriter{
+		forward: make([]rewrite, 0, len(rules)),
+		reverse: make([]rewrite, 0, len(rules)),
+	}
+
+	// compile rules
+	for _, rule := range rules {
+		forward, reverse, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		// add rewrites
+		r.forward = append(r.forward, forward)
+		r.reverse = append(r.reverse, reverse)
+	}
+
+	return r, nil
+}
+
+// Rewrite applies the first rule that matches the supplied topic or filter and
+// returns the rewritten topic. Filters are only rewritten if their levels are
+// matched by the rule as if they were plain topic levels. Single-level
+// wildcards in filters are matched by single-level wildcards in the rule and
+// multi-level wildcards are only matched by multi-level wildcards. The topic is
+// returned unchanged if no rule matches.
+func (r *Rewriter) Rewrite(topic string) string {
+	return applyRewrites(r.forward, topic)
+}
+
+// Reverse applies the first reversed rule that matches the supplied topic or
+// filter and returns the rewritten topic. See Rewrite for details.
+func (r *Rewriter) Reverse(topic string) string {
+	return applyRewrites(r.reverse, topic)
+}
+
+func compileRule(rule Rule) (rewrite, rewrite, error) {
+	// parse from
+	from, err := Parse(rule.From, true)
+	if err != nil {
+		return rewrite{}, rewrite{}, err
+	}
+
+	// parse to
+	to, err := Parse(rule.To, false)
+	if err != nil {
+		return rewrite{}, rewrite{}, err
+	}
+
+	// check shared subscriptions
+	if _, _, ok := SplitShare(from); ok {
+		return rewrite{}, rewrite{}, ErrRule
+	}
+
+	// prepare forward rewrite
+	forward := rewrite{
+		from: strings.Split(from, "/"),
+		to:   strings.Split(to, "/"),
+	}
+
+	// collect captures
+	var captures []string
+	for _, segment := range forward.from {
+		if segment == "+" || segment == "#" {
+			captures = append(captures, segment)
+		}
+	}
+
+	// resolve references
+	used := make([]bool, len(captures))
+	forward.refs = make([]int, len(forward.to))
+	for i, segment := range forward.to {
+		// check reference
+		ref, ok := parseRef(segment)
+		if !ok {
+			forward.refs[i] = -1
+			continue
+		}
+
+		// check capture
+		if ref >= len(captures) || used[ref] {
+			return rewrite{}, rewrite{}, ErrRule
+		}
+
+		// check position of multi-level captures
+		if captures[ref] == "#" && i != len(forward.to)-1 {
+			return rewrite{}, rewrite{}, ErrRule
+		}
+
+		// set reference
+		forward.refs[i] = ref
+		used[ref] = true
+	}
+
+	// check that all captures are used
+	for _, ok := range used {
+		if !ok {
+			return rewrite{}, rewrite{}, ErrRule
+		}
+	}
+
+	// prepare reverse rewrite
+	reverse := rewrite{
+		from: make([]string, len(forward.to)),
+		to:   make([]string, len(forward.from)),
+		refs: make([]int, len(forward.from)),
+	}
+
+	// build reverse filter and map forward captures to reverse captures
+	mapping := make([]int, len(captures))
+	count := 0
+	for i, segment := range forward.to {
+		if forward.refs[i] >= 0 {
+			reverse.from[i] = captures[forward.refs[i]]
+			mapping[forward.refs[i]] = count
+			count++
+		} else {
+			reverse.from[i] = segment
+		}
+	}
+
+	// build reverse template
+	count = 0
+	for i, segment := range forward.from {
+		if segment == "+" || segment == "#" {
+			reverse.to[i] = segment
+			reverse.refs[i] = mapping[count]
+			count++
+		} else {
+			reverse.to[i] = segment
+			reverse.refs[i] = -1
+		}
+	}
+
+	return forward, reverse, nil
+}
+
+func parseRef(segment string) (int, bool) {
+	// check prefix
+	if len(segment) < 2 || segment[0] != '$' {
+		return 0, false
+	}
+
+	// parse number
+	n, err := strconv.Atoi(segment[1:])
+	if err != nil || n < 1 || segment[1] == '0' {
+		return 0, false
+	}
+
+	return n - 1, true
+}
+
+func applyRewrites(rewrites []rewrite, topic string) string {
+	// check rewrites
+	if len(rewrites) == 0 {
+		return topic
+	}
+
+	// split topic
+	segments := strings.Split(topic, "/")
+
+	// find first matching rewrite
+	for _, rw := range rewrites {
+		if result, ok := rw.apply(segments); ok {
+			return result
+		}
+	}
+
+	return topic
+}
+
+func (r *rewrite) apply(segments []string) (string, bool) {
+	// match segments
+	var captures []string
+	i := 0
+	for _, segment := range r.from {
+		// capture remaining levels
+		if segment == "#" {
+			captures = append(captures, strings.Join(segments[i:], "/"))
+			i = len(segments)
+			break
+		}
+
+		// check length
+		if i >= len(segments) {
+			return "", false
+		}
+
+		// capture single level
+		if segment == "+" {
+			if segments[i] == "#" {
+				return "", false
+			}
+
+			captures = append(captures, segments[i])
+			i++
+			continue
+		}
+
+		// check level
+		if segment != segments[i] {
+			return "", false
+		}
+
+		i++
+	}
+
+	// check remaining levels
+	if i != len(segments) {
+		return "", false
+	}
+
+	// build result
+	var b strings.Builder
+	for j, segment := range r.to {
+		// get capture
+		if r.refs[j] >= 0 {
+			segment = captures[r.refs[j]]
+
+			// skip empty multi-level captures
+			if segment == "" && j == len(r.to)-1 && j > 0 {
+				break
+			}
+		}
+
+		// add segment
+		if j > 0 {
+			b.WriteString("/")
+		}
+		b.WriteString(segment)
+	}
+
+	// check result
+	if b.Len() == 0 {
+		return "", false
+	}
+
+	return b.String(), true
+}
@@ -0,0 +1,97 @@
+package topic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriter(t *testing.T) {
+	r, err := NewRewriter(Rule{
+		From: "dev/+/temp",
+		To:   "sensors/$1/temperature",
+	}, Rule{
+		From: "dev/+/+/log/#",
+		To:   "logs/$2/$1/$3",
+	})
+	assert.NoError(t, err)
+
+	table := []struct {
+		topic   string
+		rewrite string
+	}{
+		{"dev/1/temp", "sensors/1/temperature"},
+		{"dev/+/temp", "sensors/+/temperature"},
+		{"dev/a/b/log/foo/bar", "logs/b/a/foo/bar"},
+		{"dev/a/b/log", "logs/b/a"},
+		{"dev/+/+/log/#", "logs/+/+/#"},
+		{"dev/1/humidity", "dev/1/humidity"},
+		{"dev/1/temp/foo", "dev/1/temp/foo"},
+		{"dev/#", "dev/#"},
+		{"foo", "foo"},
+	}
+
+	for _, item := range table {
+		assert.Equal(t, item.rewrite, r.Rewrite(item.topic), item.topic)
+
+		if item.rewrite != item.topic {
+			assert.Equal(t, item.topic, r.Reverse(item.rewrite), item.rewrite)
+		}
+	}
+}
+
+func TestRewriterMountPoint(t *testing.T) {
+	r, err := NewRewriter(MountPoint("tenant42/"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "tenant42/foo/bar", r.Rewrite("foo/bar"))
+	assert.Equal(t, "tenant42/#", r.Rewrite("#"))
+	assert.Equal(t, "tenant42/+/bar", r.Rewrite("+/bar"))
+	assert.Equal(t, "foo/bar", r.Reverse("tenant42/foo/bar"))
+	assert.Equal(t, "#", r.Reverse("tenant42/#"))
+	assert.Equal(t, "tenant43/foo", r.Reverse("tenant43/foo"))
+}
+
+func TestRewriterFirstMatch(t *testing.T) {
+	r, err := NewRewriter(Rule{
+		From: "foo/bar",
+		To:   "baz",
+	}, MountPoint("tenant"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "baz", r.Rewrite("foo/bar"))
+	assert.Equal(t, "tenant/foo/baz", r.Rewrite("foo/baz"))
+	assert.Equal(t, "foo/bar", r.Reverse("baz"))
+	assert.Equal(t, "foo/baz", r.Reverse("tenant/foo/baz"))
+}
+
+func TestRewriterEmpty(t *testing.T) {
+	r, err := NewRewriter()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "foo/bar", r.Rewrite("foo/bar"))
+	assert.Equal(t, "foo/bar", r.Reverse("foo/bar"))
+}
+
+func TestRewriterErrors(t *testing.T) {
+	table := []struct {
+		rule Rule
+		err  error
+	}{
+		{Rule{From: "", To: "foo"}, ErrZeroLength},
+		{Rule{From: "foo", To: ""}, ErrZeroLength},
+		{Rule{From: "foo/#/bar", To: "foo"}, ErrWildcards},
+		{Rule{From: "foo", To: "bar/+"}, ErrWildcards},
+		{Rule{From: "$share/group/foo", To: "bar"}, ErrRule},
+		{Rule{From: "foo/+", To: "bar"}, ErrRule},
+		{Rule{From: "foo/+", To: "bar/$2"}, ErrRule},
+		{Rule{From: "foo/+", To: "bar/$1/$1"}, ErrRule},
+		{Rule{From: "foo/#", To: "bar/$1/baz"}, ErrRule},
+	}
+
+	for _, item := range table {
+		r, err := NewRewriter(item.rule)
+		assert.Nil(t, r)
+		assert.Equal(t, item.err, err, item.rule)
+	}
+}
@@ -0,0 +1,234 @@
+package topic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrInvalidSnapshot is returned by Restore if the snapshot is malformed.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// the maximum length of a topic or value in a snapshot
+const maxSnapshotLength = 256 * 1024 * 1024 // 256MB
+
+// Snapshot writes all topics and their values to the writer using the encode
+// function to serialize the values. Every topic is written as a record that
+// consists of a marker byte, the length prefixed topic, the number of values
+// and the length prefixed encoded values. The snapshot is terminated by a zero
+// marker byte. Shared subscription groups are not included. The topics and
+// values are collected first and encoded and written without holding the
+// tree lock.
+func (t *Tree) Snapshot(w io.Writer, encode func(interface{}) ([]byte, error)) error {
+	// collect records
+	var records []snapshotRecord
+	t.Walk(func(topic string, values []interface{}) bool {
+		records = append(records, snapshotRecord{
+			topic:  topic,
+			values: append([]interface{}(nil), values...),
+		})
+		return true
+	})
+
+	// prepare writer
+	bw := bufio.NewWriter(w)
+
+	// prepare buffer
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	// write uvarint
+	writeUvarint := func(n int) error {
+		_, err := bw.Write(buf[:binary.PutUvarint(buf, uint64(n))])
+		return err
+	}
+
+	// write length prefixed bytes
+	writeBytes := func(data []byte) error {
+		err := writeUvarint(len(data))
+		if err != nil {
+			return err
+		}
+
+		_, err = bw.Write(data)
+		return err
+	}
+
+	// write records
+	for _, record := range records {
+		// write marker
+		err := bw.WriteByte(1)
+		if err != nil {
+			return err
+		}
+
+		// write topic
+		err = writeBytes([]byte(record.topic))
+		if err != nil {
+			return err
+		}
+
+		// write count
+		err = writeUvarint(len(record.values))
+		if err != nil {
+			return err
+		}
+
+		// write values
+		for _, value := range record.values {
+			// encode value
+			data, err := encode(value)
+			if err != nil {
+				return err
+			}
+
+			// write value
+			err = writeBytes(data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// write end marker
+	err := bw.WriteByte(0)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Restore replaces the content of the tree with the topics and values read
+// from the reader that has been written using Snapshot. The decode function
+// is used to deserialize the values. Shared subscription groups are removed.
+// The tree is left unchanged if an error is returned. The reader is not read
+// beyond the end of the snapshot, which allows snapshots to be embedded in
+// larger streams.
+func (t *Tree) Restore(r io.Reader, decode func([]byte) (interface{}, error)) error {
+	// prepare reader
+	br, ok := r.(snapshotReader)
+	if !ok {
+		br = &byteReader{Reader: r}
+	}
+
+	// read uvarint
+	readUvarint := func() (int, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return 0, err
+		} else if n > maxSnapshotLength {
+			return 0, ErrInvalidSnapshot
+		}
+
+		return int(n), nil
+	}
+
+	// read length prefixed bytes
+	readBytes := func() ([]byte, error) {
+		// read length
+		n, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		// read data, the buffer grows with the data that is actually read
+		var data bytes.Buffer
+		_, err = io.CopyN(&data, br, int64(n))
+		if err != nil {
+			return nil, err
+		}
+
+		return data.Bytes(), nil
+	}
+
+	// prepare tree
+	tree := NewTree(t.separator, t.wildcardOne, t.wildcardSome)
+
+	for {
+		// read marker
+		marker, err := br.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		} else if marker == 0 {
+			break
+		} else if marker != 1 {
+			return ErrInvalidSnapshot
+		}
+
+		// read topic
+		topic, err := readBytes()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		// read count
+		count, err := readUvarint()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		// read values
+		for i := 0; i < count; i++ {
+			// read value
+			data, err := readBytes()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+
+			// decode value
+			value, err := decode(data)
+			if err != nil {
+				return err
+			}
+
+			// add value
+			tree.add(value, string(topic), tree.root)
+		}
+	}
+
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// replace root
+	t.root = tree.root
+
+	return nil
+}
+
+// a snapshotRecord holds a topic and its values collected for a snapshot
+type snapshotRecord struct {
+	topic  string
+	values []interface{}
+}
+
+// a snapshotReader is a reader that can read single bytes
+type snapshotReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// a byteReader reads single bytes from a reader without buffering
+type byteReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(r.Reader, r.buf[:])
+	if err != nil {
+		return 0, err
+	}
+
+	return r.buf[0], nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
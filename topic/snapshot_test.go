@@ -0,0 +1,148 @@
+package topic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeInt(value interface{}) ([]byte, error) {
+	return []byte(strconv.Itoa(value.(int))), nil
+}
+
+func decodeInt(data []byte) (interface{}, error) {
+	return strconv.Atoi(string(data))
+}
+
+func TestTreeSnapshotRestore(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+	tree.Add("foo/#", 3)
+	tree.Add("$SYS/foo", 4)
+	tree.Add("foo", 5)
+
+	var buf bytes.Buffer
+	err := tree.Snapshot(&buf, encodeInt)
+	assert.NoError(t, err)
+
+	tree2 := NewStandardTree()
+	tree2.Add("bar", 6)
+
+	err = tree2.Restore(&buf, decodeInt)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.Count(), tree2.Count())
+	assert.Equal(t, []interface{}{1, 2}, tree2.Get("foo/bar"))
+	assert.Empty(t, tree2.Get("bar"))
+	assert.ElementsMatch(t, []interface{}{1, 2, 3}, tree2.Match("foo/bar"))
+	assert.Equal(t, []interface{}{4}, tree2.Match("$SYS/foo"))
+}
+
+func TestTreeSnapshotRestoreEmpty(t *testing.T) {
+	tree := NewStandardTree()
+
+	var buf bytes.Buffer
+	err := tree.Snapshot(&buf, encodeInt)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0}, buf.Bytes())
+
+	tree2 := NewStandardTree()
+	tree2.Add("foo", 1)
+
+	err = tree2.Restore(&buf, decodeInt)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tree2.Count())
+}
+
+func TestTreeSnapshotEncodeError(t *testing.T) {
+	tree := NewStandardTree()
+	tree.Add("foo", 1)
+
+	err := tree.Snapshot(&bytes.Buffer{}, func(interface{}) ([]byte, error) {
+		return nil, errors.New("foo")
+	})
+	assert.EqualError(t, err, "foo")
+}
+
+func TestTreeRestoreErrors(t *testing.T) {
+	tree := NewStandardTree()
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/baz", 2)
+
+	var buf bytes.Buffer
+	err := tree.Snapshot(&buf, encodeInt)
+	assert.NoError(t, err)
+
+	tree2 := NewStandardTree()
+	tree2.Add("bar", 3)
+
+	for i := 0; i < buf.Len(); i++ {
+		err = tree2.Restore(bytes.NewReader(buf.Bytes()[:i]), decodeInt)
+		assert.Equal(t, io.ErrUnexpectedEOF, err, i)
+	}
+
+	err = tree2.Restore(bytes.NewReader(buf.Bytes()), func([]byte) (interface{}, error) {
+		return nil, errors.New("foo")
+	})
+	assert.EqualError(t, err, "foo")
+
+	err = tree2.Restore(bytes.NewReader([]byte{1, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}), decodeInt)
+	assert.Equal(t, ErrInvalidSnapshot, err)
+
+	err = tree2.Restore(bytes.NewReader([]byte{2}), decodeInt)
+	assert.Equal(t, ErrInvalidSnapshot, err)
+
+	assert.Equal(t, []interface{}{3}, tree2.All())
+}
+
+func TestTreeSnapshotWithoutLock(t *testing.T) {
+	tree := NewStandardTree()
+	tree.Add("foo", 1)
+
+	var buf bytes.Buffer
+	err := tree.Snapshot(&buf, func(value interface{}) ([]byte, error) {
+		tree.Add("bar", 2)
+		return encodeInt(value)
+	})
+	assert.NoError(t, err)
+
+	tree2 := NewStandardTree()
+	err = tree2.Restore(&buf, decodeInt)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1}, tree2.All())
+}
+
+func TestTreeRestoreEmbedded(t *testing.T) {
+	tree := NewStandardTree()
+	tree.Add("foo", 1)
+
+	var buf bytes.Buffer
+	err := tree.Snapshot(&buf, encodeInt)
+	assert.NoError(t, err)
+
+	buf.WriteString("rest")
+
+	r := struct{ io.Reader }{&buf}
+
+	tree2 := NewStandardTree()
+	err = tree2.Restore(r, decodeInt)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1}, tree2.All())
+
+	rest, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "rest", string(rest))
+}
+
+func TestTreeRestoreLargeLength(t *testing.T) {
+	tree := NewStandardTree()
+
+	err := tree.Restore(bytes.NewReader([]byte{1, 0x80, 0x80, 0x80, 0x80, 0x01, 'f', 'o', 'o'}), decodeInt)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -13,10 +13,17 @@ var ErrZeroLength = errors.New("zero length topic")
 // ErrWildcards is returned by Parse if a topic contains invalid wildcards.
 var ErrWildcards = errors.New("invalid use of wildcards")
 
+// ErrShare is returned by Parse if a shared subscription is invalid.
+var ErrShare = errors.New("invalid shared subscription")
+
+// SharePrefix is the first segment of a shared subscription.
+const SharePrefix = "$share"
+
 var multiSlashRegex = regexp.MustCompile(`/+`)
 
 // Parse removes duplicate and trailing slashes from the supplied
-// string and returns the normalized topic.
+// string and returns the normalized topic. If wildcards are allowed, shared
+// subscriptions in the form "$share/<group>/<filter>" are accepted as well.
 func Parse(topic string, allowWildcards bool) (string, error) {
 	// check for zero length
 	if topic == "" {
@@ -38,6 +45,27 @@ func Parse(topic string, allowWildcards bool) (string, error) {
 	remainder := topic
 	segment := topicSegment(topic, "/")
 
+	// check shared subscription
+	if allowWildcards && segment == SharePrefix {
+		// get group
+		remainder = topicShorten(remainder, "/")
+		group := topicSegment(remainder, "/")
+
+		// check group
+		if remainder == topicEnd || strings.ContainsAny(group, "+#") {
+			return "", ErrShare
+		}
+
+		// check filter
+		remainder = topicShorten(remainder, "/")
+		if remainder == topicEnd {
+			return "", ErrShare
+		}
+
+		// continue with filter
+		segment = topicSegment(remainder, "/")
+	}
+
 	// check all segments
 	for segment != topicEnd {
 		// check use of wildcards
@@ -68,3 +96,21 @@ func Parse(topic string, allowWildcards bool) (string, error) {
 func ContainsWildcards(topic string) bool {
 	return strings.Contains(topic, "+") || strings.Contains(topic, "#")
 }
+
+// SplitShare splits a shared subscription into its group and filter. The
+// topic is expected to be tested and normalized using Parse beforehand.
+func SplitShare(topic string) (string, string, bool) {
+	// check prefix
+	if !strings.HasPrefix(topic, SharePrefix+"/") {
+		return "", "", false
+	}
+
+	// get group and filter
+	group := topicSegment(topic[len(SharePrefix)+1:], "/")
+	filter := topicShorten(topic[len(SharePrefix)+1:], "/")
+	if group == "" || filter == topicEnd {
+		return "", "", false
+	}
+
+	return group, filter, true
+}
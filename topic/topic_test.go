@@ -80,6 +80,15 @@ func TestTopicAllowWildcards(t *testing.T) {
 		"##":               false,
 		"#/+":              false,
 		"#/#":              false,
+		"$share/g/topic":   true,
+		"$share/g/+/#":     true,
+		"$share/g/#":       true,
+		"$share":           false,
+		"$share/g":         false,
+		"$share/+/topic":   false,
+		"$share/g+/topic":  false,
+		"$share/#/topic":   false,
+		"$share/g/#/topic": false,
 	}
 
 	for str, result := range tests {
@@ -98,3 +107,32 @@ func TestTopicContainsWildcards(t *testing.T) {
 	assert.True(t, ContainsWildcards("topic/#"))
 	assert.False(t, ContainsWildcards("topic/hello"))
 }
+
+func TestTopicShare(t *testing.T) {
+	str, err := Parse("$share//group//topic/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "$share/group/topic", str)
+
+	str, err = Parse("$share/group", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "$share/group", str)
+
+	_, err = Parse("$share/group", true)
+	assert.Equal(t, ErrShare, err)
+}
+
+func TestSplitShare(t *testing.T) {
+	group, filter, ok := SplitShare("$share/group/foo/#")
+	assert.True(t, ok)
+	assert.Equal(t, "group", group)
+	assert.Equal(t, "foo/#", filter)
+
+	_, _, ok = SplitShare("foo/#")
+	assert.False(t, ok)
+
+	_, _, ok = SplitShare("$share/group")
+	assert.False(t, ok)
+
+	_, _, ok = SplitShare("$shared/group/foo")
+	assert.False(t, ok)
+}
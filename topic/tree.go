@@ -2,6 +2,7 @@ package topic
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -9,6 +10,7 @@ import (
 type node struct {
 	children map[string]*node
 	values   []interface{}
+	shared   map[string][]interface{}
 }
 
 func newNode() *node {
@@ -33,6 +35,32 @@ func (n *node) clearValues() {
 	n.values = []interface{}{}
 }
 
+func (n *node) removeShared(group string, value interface{}) {
+	// check membership
+	if !contains(n.shared[group], value) {
+		return
+	}
+
+	// copy remaining values as the current slice may have been handed out
+	values := make([]interface{}, 0, len(n.shared[group])-1)
+	for _, v := range n.shared[group] {
+		if v != value {
+			values = append(values, v)
+		}
+	}
+
+	// update or delete group
+	if len(values) > 0 {
+		n.shared[group] = values
+	} else {
+		delete(n.shared, group)
+	}
+}
+
+func (n *node) empty() bool {
+	return len(n.values) == 0 && len(n.children) == 0 && len(n.shared) == 0
+}
+
 func (n *node) string(level int) string {
 	// print node length unless on root level
 	str := ""
@@ -53,6 +81,7 @@ type Tree struct {
 	separator    string
 	wildcardOne  string
 	wildcardSome string
+	reserved     []string
 	root         *node
 	mutex        sync.RWMutex
 }
@@ -68,9 +97,31 @@ func NewTree(separator, wildcardOne, wildcardSome string) *Tree {
 }
 
 // NewStandardTree returns a new Tree using the standard MQTT separator and
-// wildcards.
+// wildcards. Topics starting with "$" are reserved.
 func NewStandardTree() *Tree {
-	return NewTree("/", "+", "#")
+	tree := NewTree("/", "+", "#")
+	tree.Reserve("$")
+	return tree
+}
+
+// Reserve sets the prefixes of reserved topics. Reserved topics are not
+// matched by filters that start with a wildcard.
+func (t *Tree) Reserve(prefixes ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// set prefixes
+	t.reserved = prefixes
+}
+
+func (t *Tree) isReserved(topic string) bool {
+	for _, prefix := range t.reserved {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Add registers the value for the supplied topic. This function will
@@ -202,7 +253,7 @@ func (t *Tree) remove(value interface{}, topic string, node *node) bool {
 			node.removeValue(value)
 		}
 
-		return len(node.values) == 0 && len(node.children) == 0
+		return node.empty()
 	}
 
 	// get segment
@@ -219,7 +270,7 @@ func (t *Tree) remove(value interface{}, topic string, node *node) bool {
 		delete(node.children, segment)
 	}
 
-	return len(node.values) == 0 && len(node.children) == 0
+	return node.empty()
 }
 
 // Clear will unregister the supplied value from all topics. This function will
@@ -236,6 +287,11 @@ func (t *Tree) clear(value interface{}, node *node) bool {
 	// remove value
 	node.removeValue(value)
 
+	// remove value from shared groups
+	for group := range node.shared {
+		node.removeShared(group, value)
+	}
+
 	// remove value from all children and remove empty nodes
 	for segment, child := range node.children {
 		if t.clear(value, child) {
@@ -243,14 +299,15 @@ func (t *Tree) clear(value interface{}, node *node) bool {
 		}
 	}
 
-	return len(node.values) == 0 && len(node.children) == 0
+	return node.empty()
 }
 
 // Match will return a set of values from topics that match the supplied topic.
 // The result set will be cleared from duplicate values.
 //
 // Note: In contrast to Search, Match does not respect wildcards in the query but
-// in the stored tree.
+// in the stored tree. Reserved topics are not matched by stored topics that
+// start with a wildcard.
 func (t *Tree) Match(topic string) []interface{} {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
@@ -280,9 +337,33 @@ func (t *Tree) MatchFirst(topic string) interface{} {
 	return value
 }
 
+// MatchReduce folds all values from topics that match the supplied topic using
+// the reducer and returns the result. The reducer is called with the current
+// result, starting with the initial value, and a matched value. In contrast to
+// Match, no intermediary result set is allocated. Values that are stored for
+// multiple matching topics are passed multiple times.
+func (t *Tree) MatchReduce(topic string, initial interface{}, fn func(result, value interface{}) interface{}) interface{} {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// reduce values
+	result := initial
+	t.match(topic, t.root, func(values []interface{}) bool {
+		for _, value := range values {
+			result = fn(result, value)
+		}
+		return true
+	})
+
+	return result
+}
+
 func (t *Tree) match(topic string, node *node, fn func([]interface{}) bool) {
+	// reserved topics are not matched by wildcards on the first level
+	wildcards := node != t.root || !t.isReserved(topic)
+
 	// add all values to the result set that match multiple levels
-	if child, ok := node.children[t.wildcardSome]; ok && len(child.values) > 0 {
+	if child, ok := node.children[t.wildcardSome]; ok && wildcards && len(child.values) > 0 {
 		if !fn(child.values) {
 			return
 		}
@@ -298,7 +379,7 @@ func (t *Tree) match(topic string, node *node, fn func([]interface{}) bool) {
 	}
 
 	// advance children that match a single level
-	if child, ok := node.children[t.wildcardOne]; ok {
+	if child, ok := node.children[t.wildcardOne]; ok && wildcards {
 		t.match(topicShorten(topic, t.separator), child, fn)
 	}
 
@@ -317,7 +398,8 @@ func (t *Tree) match(topic string, node *node, fn func([]interface{}) bool) {
 // The result set will be cleared from duplicate values.
 //
 // Note: In contrast to Match, Search respects wildcards in the query but not in
-// the stored tree.
+// the stored tree. Queries that start with a wildcard do not return reserved
+// topics.
 func (t *Tree) Search(topic string) []interface{} {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
@@ -368,8 +450,10 @@ func (t *Tree) search(topic string, node *node, fn func([]interface{}) bool) {
 			}
 		}
 
-		for _, child := range node.children {
-			t.search(topic, child, fn)
+		for segment, child := range node.children {
+			if node != t.root || !t.isReserved(segment) {
+				t.search(topic, child, fn)
+			}
 		}
 	}
 
@@ -381,8 +465,10 @@ func (t *Tree) search(topic string, node *node, fn func([]interface{}) bool) {
 			}
 		}
 
-		for _, child := range node.children {
-			t.search(topicShorten(topic, t.separator), child, fn)
+		for segment, child := range node.children {
+			if node != t.root || !t.isReserved(segment) {
+				t.search(topicShorten(topic, t.separator), child, fn)
+			}
 		}
 	}
 
@@ -409,6 +495,171 @@ func (t *Tree) clean(values []interface{}) []interface{} {
 	return result
 }
 
+// AddShared registers the value as a member of the shared subscription group
+// for the supplied topic. This function will automatically grow the tree. If
+// the value already is a member it will not be added again.
+func (t *Tree) AddShared(group, topic string, value interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get node
+	node := t.root
+	for topic != topicEnd {
+		// get segment
+		segment := topicSegment(topic, t.separator)
+
+		// get child
+		child, ok := node.children[segment]
+		if !ok {
+			child = newNode()
+			node.children[segment] = child
+		}
+
+		// descend
+		node = child
+		topic = topicShorten(topic, t.separator)
+	}
+
+	// check if duplicate
+	if contains(node.shared[group], value) {
+		return
+	}
+
+	// prepare map
+	if node.shared == nil {
+		node.shared = make(map[string][]interface{})
+	}
+
+	// add value to a copy as the current slice may have been handed out
+	values := make([]interface{}, 0, len(node.shared[group])+1)
+	values = append(values, node.shared[group]...)
+	node.shared[group] = append(values, value)
+}
+
+// GetShared gets the members of the shared subscription group for the topic
+// that exactly matches the supplied topic. The returned slice must not be
+// modified.
+func (t *Tree) GetShared(group, topic string) []interface{} {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// get node
+	node := t.root
+	for topic != topicEnd {
+		child, ok := node.children[topicSegment(topic, t.separator)]
+		if !ok {
+			return nil
+		}
+
+		node = child
+		topic = topicShorten(topic, t.separator)
+	}
+
+	return node.shared[group]
+}
+
+// RemoveShared un-registers the value from the shared subscription group for
+// the supplied topic. This function will automatically shrink the tree.
+func (t *Tree) RemoveShared(group, topic string, value interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// remove value
+	t.removeShared(group, value, topic, t.root)
+}
+
+func (t *Tree) removeShared(group string, value interface{}, topic string, node *node) bool {
+	// remove value from leaf node
+	if topic == topicEnd {
+		node.removeShared(group, value)
+		return node.empty()
+	}
+
+	// get segment
+	segment := topicSegment(topic, t.separator)
+
+	// get child
+	child, ok := node.children[segment]
+	if !ok {
+		return false
+	}
+
+	// descend and remove node if empty
+	if t.removeShared(group, value, topicShorten(topic, t.separator), child) {
+		delete(node.children, segment)
+	}
+
+	return node.empty()
+}
+
+// A Share describes the members of a shared subscription group.
+type Share struct {
+	// The group name and topic filter of the shared subscription.
+	Group  string
+	Filter string
+
+	// The registered members of the group. The slice must not be modified.
+	Values []interface{}
+}
+
+// MatchShared will return all shared subscription groups that have topic
+// filters matching the supplied topic.
+//
+// Note: Like Match, MatchShared does not respect wildcards in the query but in
+// the stored tree.
+func (t *Tree) MatchShared(topic string) []Share {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// match shares
+	var list []Share
+	t.matchShared(topic, t.root, nil, func(filter []string, shared map[string][]interface{}) {
+		for group, values := range shared {
+			list = append(list, Share{
+				Group:  group,
+				Filter: strings.Join(filter, t.separator),
+				Values: values,
+			})
+		}
+	})
+
+	return list
+}
+
+func (t *Tree) matchShared(topic string, node *node, filter []string, fn func([]string, map[string][]interface{})) {
+	// reserved topics are not matched by wildcards on the first level
+	wildcards := node != t.root || !t.isReserved(topic)
+
+	// add all groups that match multiple levels
+	if child, ok := node.children[t.wildcardSome]; ok && wildcards && len(child.shared) > 0 {
+		fn(append(filter, t.wildcardSome), child.shared)
+	}
+
+	// when finished add all groups
+	if topic == topicEnd {
+		if len(node.shared) > 0 {
+			fn(filter, node.shared)
+		}
+
+		return
+	}
+
+	// advance children that match a single level
+	if child, ok := node.children[t.wildcardOne]; ok && wildcards {
+		t.matchShared(topicShorten(topic, t.separator), child, append(filter, t.wildcardOne), fn)
+	}
+
+	// get segment
+	segment := topicSegment(topic, t.separator)
+
+	// match segments and get children
+	if segment != t.wildcardOne && segment != t.wildcardSome {
+		if child, ok := node.children[segment]; ok {
+			t.matchShared(topicShorten(topic, t.separator), child, append(filter, segment), fn)
+		}
+	}
+}
+
 // Count will count all stored values in the tree. It will not filter out
 // duplicate values and thus might return a different result to `len(All())`.
 func (t *Tree) Count() int {
@@ -427,6 +678,11 @@ func (t *Tree) count(node *node) int {
 		total += t.count(child)
 	}
 
+	// add shared values to result
+	for _, values := range node.shared {
+		total += len(values)
+	}
+
 	// add values to result
 	return total + len(node.values)
 }
@@ -445,10 +701,53 @@ func (t *Tree) all(result []interface{}, node *node) []interface{} {
 		result = t.all(result, child)
 	}
 
+	// add shared values to results
+	for _, values := range node.shared {
+		result = append(result, values...)
+	}
+
 	// add current node to results
 	return append(result, node.values...)
 }
 
+// Walk will call the callback for every topic that has values stored in depth
+// first order until it returns false. Children are visited in lexical order.
+// Shared subscription groups are not visited.
+//
+// Note: The tree must not be modified from within the callback.
+func (t *Tree) Walk(fn func(topic string, values []interface{}) bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	// walk tree
+	t.walk(t.root, nil, fn)
+}
+
+func (t *Tree) walk(node *node, path []string, fn func(string, []interface{}) bool) bool {
+	// call callback if values are present
+	if len(node.values) > 0 && len(path) > 0 {
+		if !fn(strings.Join(path, t.separator), node.values) {
+			return false
+		}
+	}
+
+	// sort segments
+	segments := make([]string, 0, len(node.children))
+	for segment := range node.children {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+
+	// walk children
+	for _, segment := range segments {
+		if !t.walk(node.children[segment], append(path, segment), fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Reset will completely clear the tree.
 func (t *Tree) Reset() {
 	t.mutex.Lock()
@@ -171,6 +171,56 @@ func TestTreeMatchFirstNone(t *testing.T) {
 	assert.Nil(t, tree.MatchFirst("baz/qux"))
 }
 
+func TestTreeMatchReduce(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("a/#", 1)
+	tree.Add("a/+/c", 2)
+	tree.Add("a/b/c", 0)
+	tree.Add("b", 3)
+
+	highest := func(result, value interface{}) interface{} {
+		if value.(int) > result.(int) {
+			return value
+		}
+		return result
+	}
+
+	assert.Equal(t, 2, tree.MatchReduce("a/b/c", -1, highest))
+	assert.Equal(t, 1, tree.MatchReduce("a/b", -1, highest))
+	assert.Equal(t, -1, tree.MatchReduce("c", -1, highest))
+}
+
+func TestTreeMatchReserved(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("#", 1)
+	tree.Add("+/foo", 2)
+	tree.Add("$SYS/#", 3)
+	tree.Add("$SYS/+", 4)
+	tree.AddShared("g", "#", 5)
+
+	assert.ElementsMatch(t, []interface{}{3, 4}, tree.Match("$SYS/foo"))
+	assert.Equal(t, 3, tree.MatchFirst("$SYS/foo"))
+	assert.ElementsMatch(t, []interface{}{1, 2}, tree.Match("bar/foo"))
+	assert.Empty(t, tree.MatchShared("$SYS/foo"))
+	assert.Len(t, tree.MatchShared("bar/foo"), 1)
+}
+
+func TestTreeMatchReservedCustom(t *testing.T) {
+	tree := NewTree("/", "+", "#")
+
+	tree.Add("#", 1)
+
+	assert.Equal(t, []interface{}{1}, tree.Match("$SYS/foo"))
+
+	tree.Reserve("$", "_")
+
+	assert.Empty(t, tree.Match("$SYS/foo"))
+	assert.Empty(t, tree.Match("_internal/foo"))
+	assert.Equal(t, []interface{}{1}, tree.Match("foo/_internal"))
+}
+
 func TestTreeSearchExact(t *testing.T) {
 	tree := NewStandardTree()
 
@@ -255,6 +305,18 @@ func TestTreeSearchFirstNone(t *testing.T) {
 	assert.Nil(t, tree.SearchFirst("baz/qux"))
 }
 
+func TestTreeSearchReserved(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("$SYS/foo", 1)
+	tree.Add("bar/foo", 2)
+
+	assert.Equal(t, []interface{}{2}, tree.Search("#"))
+	assert.Equal(t, []interface{}{2}, tree.Search("+/foo"))
+	assert.Equal(t, []interface{}{1}, tree.Search("$SYS/#"))
+	assert.Equal(t, 2, tree.SearchFirst("+/foo"))
+}
+
 func TestTreeCount(t *testing.T) {
 	tree := NewStandardTree()
 
@@ -306,6 +368,114 @@ func TestTreeString(t *testing.T) {
 	assert.Equal(t, "topic.Tree:\n| '' => 1\n|   'foo' => 1\n|     'bar' => 1", tree.String())
 }
 
+func TestTreeWalk(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo", 2)
+	tree.Add("bar/#", 3)
+	tree.Add("foo/baz", 4)
+	tree.Add("foo/baz", 5)
+	tree.AddShared("group", "foo/qux", 6)
+
+	var topics []string
+	var values []interface{}
+	tree.Walk(func(topic string, v []interface{}) bool {
+		topics = append(topics, topic)
+		values = append(values, v...)
+		return true
+	})
+	assert.Equal(t, []string{"bar/#", "foo", "foo/bar", "foo/baz"}, topics)
+	assert.Equal(t, []interface{}{3, 2, 1, 4, 5}, values)
+
+	topics = nil
+	tree.Walk(func(topic string, v []interface{}) bool {
+		topics = append(topics, topic)
+		return len(topics) < 2
+	})
+	assert.Equal(t, []string{"bar/#", "foo"}, topics)
+}
+
+func TestTreeAddShared(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 2)
+	tree.AddShared("g2", "foo/bar", 3)
+
+	assert.Equal(t, []interface{}{1, 2}, tree.GetShared("g1", "foo/bar"))
+	assert.Equal(t, []interface{}{3}, tree.GetShared("g2", "foo/bar"))
+	assert.Nil(t, tree.GetShared("g3", "foo/bar"))
+	assert.Nil(t, tree.GetShared("g1", "bar"))
+	assert.Nil(t, tree.Get("foo/bar"))
+	assert.Equal(t, 3, tree.Count())
+	assert.Equal(t, 3, len(tree.All()))
+}
+
+func TestTreeRemoveShared(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 2)
+	tree.RemoveShared("g1", "foo/bar", 1)
+
+	assert.Equal(t, []interface{}{2}, tree.GetShared("g1", "foo/bar"))
+
+	tree.RemoveShared("g1", "foo/bar", 2)
+
+	assert.Equal(t, 0, len(tree.root.children))
+}
+
+func TestTreeRemoveSharedRetainedValues(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 2)
+	tree.AddShared("g1", "foo/bar", 3)
+
+	values := tree.GetShared("g1", "foo/bar")
+	shares := tree.MatchShared("foo/bar")
+
+	tree.RemoveShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 4)
+
+	assert.Equal(t, []interface{}{1, 2, 3}, values)
+	assert.Equal(t, []interface{}{1, 2, 3}, shares[0].Values)
+	assert.Equal(t, []interface{}{2, 3, 4}, tree.GetShared("g1", "foo/bar"))
+}
+
+func TestTreeClearShared(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("foo/bar", 1)
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g2", "foo/+", 1)
+	tree.Clear(1)
+
+	assert.Equal(t, 0, len(tree.root.children))
+}
+
+func TestTreeMatchShared(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.AddShared("g1", "foo/bar", 1)
+	tree.AddShared("g1", "foo/+", 2)
+	tree.AddShared("g2", "foo/#", 3)
+	tree.AddShared("g2", "foo/#", 4)
+	tree.AddShared("g3", "bar", 5)
+	tree.Add("foo/bar", 6)
+
+	shares := tree.MatchShared("foo/bar")
+	assert.ElementsMatch(t, []Share{
+		{Group: "g1", Filter: "foo/bar", Values: []interface{}{1}},
+		{Group: "g1", Filter: "foo/+", Values: []interface{}{2}},
+		{Group: "g2", Filter: "foo/#", Values: []interface{}{3, 4}},
+	}, shares)
+
+	assert.Empty(t, tree.MatchShared("baz"))
+}
+
 func BenchmarkTreeAddSame(b *testing.B) {
 	tree := NewStandardTree()
 
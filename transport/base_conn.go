@@ -3,6 +3,7 @@ package transport
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/256dpi/gomqtt/packet"
@@ -21,15 +22,29 @@ type BaseConn struct {
 	stream       *packet.Stream
 	sendMutex    sync.Mutex
 	receiveMutex sync.Mutex
-	readTimeout  time.Duration
+	readTimeout  int64
+	readBucket   *tokenBucket
+	writeBucket  *tokenBucket
+	packetBucket *tokenBucket
+	done         chan struct{}
+	doneOnce     sync.Once
 }
 
 // NewBaseConn creates a new BaseConn using the specified Carrier.
 func NewBaseConn(c Carrier) *BaseConn {
-	return &BaseConn{
-		carrier: c,
-		stream:  packet.NewStream(c, c),
+	// prepare connection
+	conn := &BaseConn{
+		carrier:      c,
+		readBucket:   newTokenBucket(),
+		writeBucket:  newTokenBucket(),
+		packetBucket: newTokenBucket(),
+		done:         make(chan struct{}),
 	}
+
+	// create stream
+	conn.stream = packet.NewStream(&shapedReader{conn: conn}, c)
+
+	return conn
 }
 
 // Send will write the packet to an internal buffer. It will either flush the
@@ -43,6 +58,9 @@ func (c *BaseConn) Send(pkt packet.Generic, async bool) error {
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
 
+	// wait for write tokens
+	c.writeBucket.wait(c.done)
+
 	// write packet
 	err := c.stream.Write(pkt, async)
 	if err != nil {
@@ -52,6 +70,40 @@ func (c *BaseConn) Send(pkt packet.Generic, async bool) error {
 		return err
 	}
 
+	// consume write tokens
+	c.writeBucket.take(pkt.Len(c.stream.Version()))
+
+	return nil
+}
+
+// SendBatch will write the packets together with any buffered data to the
+// underlying connection using a single vectored write if supported. Encoding
+// and network errors are directly returned.
+//
+// Note: Only one goroutine can send at the same time.
+func (c *BaseConn) SendBatch(pkts []packet.Generic) error {
+	// acquire mutex
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	// wait for write tokens
+	c.writeBucket.wait(c.done)
+
+	// write packets
+	err := c.stream.WriteBatch(pkts)
+	if err != nil {
+		// ensure carrier gets closed
+		_ = c.carrier.Close()
+
+		return err
+	}
+
+	// consume write tokens
+	version := c.stream.Version()
+	for _, pkt := range pkts {
+		c.writeBucket.take(pkt.Len(version))
+	}
+
 	return nil
 }
 
@@ -65,6 +117,21 @@ func (c *BaseConn) Receive() (packet.Generic, error) {
 	c.receiveMutex.Lock()
 	defer c.receiveMutex.Unlock()
 
+	// wait for packet token, the time spent waiting does not count towards
+	// the read timeout
+	if c.packetBucket.wait(c.done) {
+		err := c.resetTimeout()
+		if err != nil {
+			// ensure carrier is closed
+			_ = c.carrier.Close()
+
+			return nil, err
+		}
+	}
+
+	// consume packet token
+	c.packetBucket.take(1)
+
 	// read next packet
 	pkt, err := c.stream.Read()
 	if err != nil {
@@ -83,12 +150,25 @@ func (c *BaseConn) Receive() (packet.Generic, error) {
 		return nil, err
 	}
 
+	// reset timeout while a streamed payload is read
+	if publish, ok := pkt.(*packet.Publish); ok && publish.PayloadReader != nil {
+		publish.PayloadReader = &timeoutReader{
+			conn:   c,
+			reader: publish.PayloadReader,
+		}
+	}
+
 	return pkt, nil
 }
 
 // Close will close the underlying connection and cleanup resources. It will
 // return any error encountered while closing the underlying connection.
 func (c *BaseConn) Close() error {
+	// cancel waiting reads and writes
+	c.doneOnce.Do(func() {
+		close(c.done)
+	})
+
 	// acquire mutex
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
@@ -116,6 +196,29 @@ func (c *BaseConn) SetReadLimit(limit int64) {
 	c.stream.SetReadLimit(limit)
 }
 
+// SetReadPolicy sets the read policy that defines limits per packet type and
+// limits for topics. The policy is enforced in addition to the read limit and
+// violations will close the connection.
+func (c *BaseConn) SetReadPolicy(policy *packet.ReadPolicy) {
+	c.stream.SetReadPolicy(policy)
+}
+
+// SetPooled sets whether received packets are acquired from the packet pool.
+// If enabled, the payload of a received Publish packet references a pooled
+// buffer until the packet is released using packet.Release.
+func (c *BaseConn) SetPooled(pooled bool) {
+	c.stream.SetPooled(pooled)
+}
+
+// SetStreamingThreshold sets the packet size above which the payload of a
+// received Publish packet is streamed from the connection using the
+// PayloadReader of the packet instead of being read into memory. The payload
+// must be consumed before the next packet is received, otherwise it is
+// discarded. A threshold of zero disables streaming.
+func (c *BaseConn) SetStreamingThreshold(threshold int64) {
+	c.stream.SetStreamingThreshold(threshold)
+}
+
 // SetReadTimeout sets the maximum time that can pass between reads.
 // If no data is received in the set duration the connection will be closed
 // and Read returns an error.
@@ -125,7 +228,7 @@ func (c *BaseConn) SetReadTimeout(timeout time.Duration) {
 	defer c.receiveMutex.Unlock()
 
 	// set new timeout
-	c.readTimeout = timeout
+	atomic.StoreInt64(&c.readTimeout, int64(timeout))
 
 	// apply new timeout immediately
 	_ = c.resetTimeout()
@@ -137,11 +240,91 @@ func (c *BaseConn) SetMaxWriteDelay(delay time.Duration) {
 	c.stream.SetMaxWriteDelay(delay)
 }
 
+// SetReadRate sets the maximum number of bytes per second that are read from
+// the underlying connection. Reads are delayed once the limit is exceeded,
+// which applies backpressure to the sender. A rate of zero or less disables
+// the limit.
+func (c *BaseConn) SetReadRate(rate int64) {
+	c.readBucket.setRate(rate)
+}
+
+// SetWriteRate sets the maximum number of bytes per second that are written to
+// the underlying connection. Sends are delayed once the limit is exceeded. A
+// rate of zero or less disables the limit.
+func (c *BaseConn) SetWriteRate(rate int64) {
+	c.writeBucket.setRate(rate)
+}
+
+// SetPacketRate sets the maximum number of packets per second that are
+// received. Receives are delayed once the limit is exceeded. A rate of zero
+// or less disables the limit.
+func (c *BaseConn) SetPacketRate(rate int64) {
+	c.packetBucket.setRate(rate)
+}
+
 func (c *BaseConn) resetTimeout() error {
 	// check timeout
-	if c.readTimeout > 0 {
-		return c.carrier.SetReadDeadline(time.Now().Add(c.readTimeout))
+	if timeout := time.Duration(atomic.LoadInt64(&c.readTimeout)); timeout > 0 {
+		return c.carrier.SetReadDeadline(time.Now().Add(timeout))
 	}
 
 	return c.carrier.SetReadDeadline(time.Time{})
 }
+
+// a reader that limits the rate of reads from the carrier
+type shapedReader struct {
+	conn *BaseConn
+}
+
+func (r *shapedReader) Read(p []byte) (int, error) {
+	// wait for read tokens, the time spent waiting does not count towards the
+	// read timeout
+	if r.conn.readBucket.wait(r.conn.done) {
+		err := r.conn.resetTimeout()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// limit read to leave excess data in the underlying connection
+	if size := r.conn.readBucket.size(); size > 0 && len(p) > size {
+		p = p[:size]
+	}
+
+	// read data
+	n, err := r.conn.carrier.Read(p)
+
+	// consume read tokens
+	r.conn.readBucket.take(n)
+
+	return n, err
+}
+
+// a reader that resets the read timeout of the connection on every read
+type timeoutReader struct {
+	conn   *BaseConn
+	reader io.Reader
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	// read data
+	n, err := r.reader.Read(p)
+	if err != nil {
+		return n, err
+	}
+
+	// acquire mutex
+	r.conn.receiveMutex.Lock()
+	defer r.conn.receiveMutex.Unlock()
+
+	// reset timeout
+	err = r.conn.resetTimeout()
+	if err != nil {
+		// ensure carrier is closed
+		_ = r.conn.carrier.Close()
+
+		return n, err
+	}
+
+	return n, nil
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -18,6 +19,13 @@ type Conn interface {
 	// Note: Only one goroutine can send at the same time.
 	Send(pkt packet.Generic, async bool) error
 
+	// SendBatch will write the packets together with any buffered data to the
+	// underlying connection using a single vectored write if supported. Encoding
+	// and network errors are directly returned.
+	//
+	// Note: Only one goroutine can send at the same time.
+	SendBatch(pkts []packet.Generic) error
+
 	// Receive will read from the underlying connection and return a fully read
 	// packet. It will return any error encountered while decoding or reading
 	// from the underlying connection.
@@ -34,6 +42,23 @@ type Conn interface {
 	// return an error if receiving the next packet will exceed the limit.
 	SetReadLimit(limit int64)
 
+	// SetReadPolicy sets the read policy that defines limits per packet type
+	// and limits for topics. The policy is enforced in addition to the read
+	// limit and violations will close the connection.
+	SetReadPolicy(policy *packet.ReadPolicy)
+
+	// SetPooled sets whether received packets are acquired from the packet
+	// pool. If enabled, the payload of a received Publish packet references a
+	// pooled buffer until the packet is released using packet.Release.
+	SetPooled(pooled bool)
+
+	// SetStreamingThreshold sets the packet size above which the payload of a
+	// received Publish packet is streamed from the connection using the
+	// PayloadReader of the packet instead of being read into memory. The
+	// payload must be consumed before the next packet is received, otherwise
+	// it is discarded. A threshold of zero disables streaming.
+	SetStreamingThreshold(threshold int64)
+
 	// SetReadTimeout sets the maximum time that can pass between reads.
 	// If no data is received in the set duration the connection will be closed
 	// and Read returns an error.
@@ -43,9 +68,30 @@ type Conn interface {
 	// an asynchronous write is flushed.
 	SetMaxWriteDelay(delay time.Duration)
 
+	// SetReadRate sets the maximum number of bytes per second that are read
+	// from the underlying connection. Reads are delayed once the limit is
+	// exceeded, which applies backpressure to the sender. A rate of zero or
+	// less disables the limit.
+	SetReadRate(rate int64)
+
+	// SetWriteRate sets the maximum number of bytes per second that are
+	// written to the underlying connection. Sends are delayed once the limit
+	// is exceeded. A rate of zero or less disables the limit.
+	SetWriteRate(rate int64)
+
+	// SetPacketRate sets the maximum number of packets per second that are
+	// received. Receives are delayed once the limit is exceeded. A rate of
+	// zero or less disables the limit.
+	SetPacketRate(rate int64)
+
 	// LocalAddr will return the underlying connection's local net address.
 	LocalAddr() net.Addr
 
 	// RemoteAddr will return the underlying connection's remote net address.
 	RemoteAddr() net.Addr
+
+	// TLSState will return the state of the underlying TLS connection. It
+	// will return false if the connection is not secured using TLS or the
+	// handshake has not yet been completed.
+	TLSState() (*tls.ConnectionState, bool)
 }
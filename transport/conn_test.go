@@ -1,7 +1,9 @@
 package transport
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -77,6 +79,9 @@ func abstractConnDecodeErrorTest(t *testing.T, protocol string) {
 		} else if webSocketConn, ok := conn1.(*WebSocketConn); ok {
 			err := webSocketConn.conn.WriteMessage(websocket.BinaryMessage, buf)
 			assert.NoError(t, err)
+		} else if memoryConn, ok := conn1.(*MemoryConn); ok {
+			_, err := memoryConn.carrier.Write(buf)
+			assert.NoError(t, err)
 		}
 
 		pkt, err := conn1.Receive()
@@ -256,6 +261,93 @@ func abstractConnAsyncSendTest(t *testing.T, protocol string) {
 	safeReceive(done)
 }
 
+func abstractConnSendBatchTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Type(), packet.CONNECT)
+
+		err = conn1.Send(packet.NewConnack(), true)
+		assert.NoError(t, err)
+
+		err = conn1.SendBatch([]packet.Generic{packet.NewPingresp(), packet.NewPingresp()})
+		assert.NoError(t, err)
+
+		pkt, err = conn1.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, io.EOF, err)
+	})
+
+	err := conn2.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.Type(), packet.CONNACK)
+
+	pkt, err = conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.Type(), packet.PINGRESP)
+
+	pkt, err = conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.Type(), packet.PINGRESP)
+
+	err = conn2.Close()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
+func abstractConnStreamingTest(t *testing.T, protocol string) {
+	payload := bytes.Repeat([]byte("x"), 1<<20)
+
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		conn1.SetStreamingThreshold(1024)
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Type(), packet.CONNECT)
+
+		pkt, err = conn1.Receive()
+		assert.NoError(t, err)
+		publish := pkt.(*packet.Publish)
+		assert.Equal(t, "test", publish.Message.Topic)
+		assert.Equal(t, len(payload), publish.PayloadLength)
+
+		data, err := ioutil.ReadAll(publish.PayloadReader)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, data)
+
+		err = conn1.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		pkt, err = conn1.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, io.EOF, err)
+	})
+
+	err := conn2.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "test"
+	publish.PayloadReader = bytes.NewReader(payload)
+	publish.PayloadLength = len(payload)
+
+	err = conn2.Send(publish, false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.Type(), packet.CONNACK)
+
+	err = conn2.Close()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
 func abstractConnSendAfterAsyncSendTest(t *testing.T, protocol string) {
 	conn2, done := connectionPair(protocol, func(conn1 Conn) {
 		pkt, err := conn1.Receive()
@@ -350,3 +442,144 @@ func abstractConnBigAsyncSendAfterCloseTest(t *testing.T, protocol string) {
 
 	safeReceive(done)
 }
+
+func abstractConnTLSStateTest(t *testing.T, protocol string, secure bool) {
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Type(), packet.CONNECT)
+
+		state, ok := conn1.TLSState()
+		assert.Equal(t, secure, ok)
+		if secure {
+			assert.True(t, state.HandshakeComplete)
+		} else {
+			assert.Nil(t, state)
+		}
+
+		err = conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	err := conn2.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	state, ok := conn2.TLSState()
+	assert.Equal(t, secure, ok)
+	if secure {
+		assert.Equal(t, "localhost", state.PeerCertificates[0].Subject.CommonName)
+	} else {
+		assert.Nil(t, state)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Equal(t, io.EOF, err)
+
+	safeReceive(done)
+}
+
+func abstractConnWriteRateTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		for i := 0; i < 4; i++ {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, pkt.Type(), packet.PUBLISH)
+		}
+
+		err := conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	conn2.SetWriteRate(10000)
+
+	pub := packet.NewPublish()
+	pub.Message.Topic = "test"
+	pub.Message.Payload = make([]byte, 5000)
+
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		err := conn2.Send(pub, false)
+		assert.NoError(t, err)
+	}
+
+	assert.True(t, time.Since(start) > 400*time.Millisecond)
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func abstractConnReadRateTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		conn1.SetReadRate(10000)
+
+		start := time.Now()
+
+		for i := 0; i < 4; i++ {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, pkt.Type(), packet.PUBLISH)
+		}
+
+		assert.True(t, time.Since(start) > 400*time.Millisecond)
+
+		err := conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	pub := packet.NewPublish()
+	pub.Message.Topic = "test"
+	pub.Message.Payload = make([]byte, 5000)
+
+	for i := 0; i < 4; i++ {
+		err := conn2.Send(pub, false)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func abstractConnPacketRateTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+		conn1.SetPacketRate(10)
+		conn1.SetReadTimeout(100 * time.Millisecond)
+
+		start := time.Now()
+
+		for i := 0; i < 15; i++ {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, pkt.Type(), packet.PINGREQ)
+		}
+
+		assert.True(t, time.Since(start) > 300*time.Millisecond)
+
+		conn1.SetPacketRate(0)
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Type(), packet.PINGREQ)
+
+		err = conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	for i := 0; i < 16; i++ {
+		err := conn2.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
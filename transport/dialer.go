@@ -157,6 +157,16 @@ func (d *Dialer) Dial(address string) (Conn, error) {
 		}
 
 		return NewWebSocketConn(conn), nil
+	case "unix", "unix-abstract":
+		// make connection
+		conn, err := d.netDialer.Dial("unix", unixPath(addr))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewNetConn(conn), nil
+	case "mem":
+		return DialMemory(addr.Host + addr.Path)
 	default:
 		return nil, ErrUnsupportedProtocol
 	}
@@ -2,8 +2,10 @@ package transport
 
 import (
 	"crypto/tls"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // LaunchConfig is used to configure a launcher.
@@ -13,6 +15,22 @@ type LaunchConfig struct {
 
 	// The fallback to be used id a request is not a web socket upgrade.
 	WebSocketFallback http.Handler
+
+	// If enabled, TCP, TLS, WS and WSS servers expect connections from
+	// trusted proxies to start with a PROXY protocol v1 or v2 header. The
+	// reported addresses are returned by the connections LocalAddr and
+	// RemoteAddr methods.
+	ProxyProtocol bool
+
+	// The addresses or networks in CIDR notation of trusted proxies. PROXY
+	// protocol headers and X-Forwarded-For headers of WebSocket upgrade
+	// requests are only honoured if sent by a trusted proxy.
+	TrustedProxies []string
+
+	// The maximum time to wait for the PROXY protocol header.
+	//
+	// Will default to 5 seconds.
+	ProxyHeaderTimeout time.Duration
 }
 
 // The Launcher helps with launching a server and accepting connections.
@@ -42,17 +60,72 @@ func (l *Launcher) Launch(address string) (Server, error) {
 		return nil, err
 	}
 
+	// parse trusted proxies
+	trusted, err := parseNetworks(l.config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	// check scheme
 	switch addr.Scheme {
 	case "tcp", "mqtt":
-		return CreateNetServer(addr.Host)
+		listener, err := l.listen(addr.Host, false, trusted)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewNetServer(listener), nil
 	case "tls", "ssl", "mqtts":
-		return CreateSecureNetServer(addr.Host, l.config.TLSConfig)
-	case "ws":
-		return CreateWebSocketServer(addr.Host, l.config.WebSocketFallback)
-	case "wss":
-		return CreateSecureWebSocketServer(addr.Host, l.config.TLSConfig, l.config.WebSocketFallback)
+		listener, err := l.listen(addr.Host, true, trusted)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewNetServer(listener), nil
+	case "ws", "wss":
+		listener, err := l.listen(addr.Host, addr.Scheme == "wss", trusted)
+		if err != nil {
+			return nil, err
+		}
+
+		// create server
+		server := NewWebSocketServer(listener, l.config.WebSocketFallback)
+		server.Upgrader().SetTrustedProxies(trusted)
+
+		return server, nil
+	case "unix", "unix-abstract":
+		return CreateUnixServer(unixPath(addr))
+	case "mem":
+		return CreateMemoryServer(addr.Host + addr.Path)
 	default:
 		return nil, ErrUnsupportedProtocol
 	}
 }
+
+func (l *Launcher) listen(address string, secure bool, trusted []*net.IPNet) (net.Listener, error) {
+	// create standard listener if the proxy protocol is disabled
+	if !l.config.ProxyProtocol {
+		if secure {
+			return tls.Listen("tcp", address, l.config.TLSConfig)
+		}
+
+		return net.Listen("tcp", address)
+	}
+
+	// create listener
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// wrap listener, the proxy header precedes the TLS handshake
+	listener = NewProxyListener(listener, ProxyConfig{
+		TrustedSources: trusted,
+		HeaderTimeout:  l.config.ProxyHeaderTimeout,
+	})
+	if secure {
+		listener = tls.NewListener(listener, l.config.TLSConfig)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,306 @@
+package transport
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// A MemoryAddr is the address of a MemoryServer or MemoryConn.
+type MemoryAddr string
+
+// Network returns "mem".
+func (a MemoryAddr) Network() string {
+	return "mem"
+}
+
+// String returns the address.
+func (a MemoryAddr) String() string {
+	return string(a)
+}
+
+type memoryTimeoutError struct{}
+
+func (memoryTimeoutError) Error() string   { return "i/o timeout" }
+func (memoryTimeoutError) Timeout() bool   { return true }
+func (memoryTimeoutError) Temporary() bool { return true }
+
+// the number of bytes a memoryPipe buffers before writes block
+const memoryPipeSize = 64 * 1024
+
+// a memoryPipe buffers the data written by one end of a connection until it is
+// read by the other end
+type memoryPipe struct {
+	data     []byte
+	closed   bool
+	deadline time.Time
+	notify   chan struct{}
+	space    chan struct{}
+	mutex    sync.Mutex
+}
+
+func newMemoryPipe() *memoryPipe {
+	return &memoryPipe{
+		notify: make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+	}
+}
+
+func (p *memoryPipe) signal() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *memoryPipe) signalSpace() {
+	select {
+	case p.space <- struct{}{}:
+	default:
+	}
+}
+
+// a memoryCarrier is one end of a connection that reads from one pipe and
+// writes to the other pipe
+type memoryCarrier struct {
+	in            *memoryPipe
+	out           *memoryPipe
+	done          chan struct{}
+	closed        bool
+	writeDeadline time.Time
+	mutex         sync.Mutex
+}
+
+func (c *memoryCarrier) Read(buf []byte) (int, error) {
+	for {
+		// check if closed
+		select {
+		case <-c.done:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+
+		// read available data
+		c.in.mutex.Lock()
+		if len(c.in.data) > 0 {
+			n := copy(buf, c.in.data)
+			c.in.data = c.in.data[n:]
+			if len(c.in.data) == 0 {
+				c.in.data = nil
+			}
+			c.in.mutex.Unlock()
+
+			// notify writer
+			c.in.signalSpace()
+
+			return n, nil
+		} else if c.in.closed {
+			c.in.mutex.Unlock()
+			return 0, io.EOF
+		}
+		deadline := c.in.deadline
+		c.in.mutex.Unlock()
+
+		// prepare timeout
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, memoryTimeoutError{}
+			}
+
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		// wait for data, close or timeout
+		select {
+		case <-c.in.notify:
+		case <-c.done:
+		case <-timeout:
+		}
+
+		// stop timer
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *memoryCarrier) Write(buf []byte) (int, error) {
+	// write data in chunks that fit into the pipe
+	var total int
+	for {
+		// check if closed
+		select {
+		case <-c.done:
+			return total, io.ErrClosedPipe
+		default:
+		}
+
+		// append data that fits
+		c.out.mutex.Lock()
+		if c.out.closed {
+			c.out.mutex.Unlock()
+			return total, io.ErrClosedPipe
+		}
+		n := memoryPipeSize - len(c.out.data)
+		if n > len(buf) {
+			n = len(buf)
+		}
+		if n > 0 {
+			c.out.data = append(c.out.data, buf[:n]...)
+			buf = buf[n:]
+			total += n
+		}
+		c.out.mutex.Unlock()
+
+		// notify reader
+		if n > 0 {
+			c.out.signal()
+		}
+
+		// check if done
+		if len(buf) == 0 {
+			return total, nil
+		}
+
+		// get deadline
+		c.mutex.Lock()
+		deadline := c.writeDeadline
+		c.mutex.Unlock()
+
+		// prepare timeout
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return total, memoryTimeoutError{}
+			}
+
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		// wait for space, close or timeout
+		select {
+		case <-c.out.space:
+		case <-c.done:
+		case <-timeout:
+		}
+
+		// stop timer
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *memoryCarrier) Close() error {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// check if closed
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+
+	// set flag
+	c.closed = true
+	close(c.done)
+
+	// close pipes
+	for _, pipe := range []*memoryPipe{c.in, c.out} {
+		pipe.mutex.Lock()
+		pipe.closed = true
+		pipe.mutex.Unlock()
+		pipe.signal()
+		pipe.signalSpace()
+	}
+
+	return nil
+}
+
+func (c *memoryCarrier) SetReadDeadline(t time.Time) error {
+	// set deadline
+	c.in.mutex.Lock()
+	c.in.deadline = t
+	c.in.mutex.Unlock()
+
+	// wake up reader
+	c.in.signal()
+
+	return nil
+}
+
+func (c *memoryCarrier) SetWriteDeadline(t time.Time) error {
+	// set deadline
+	c.mutex.Lock()
+	c.writeDeadline = t
+	c.mutex.Unlock()
+
+	// wake up writer
+	c.out.signalSpace()
+
+	return nil
+}
+
+// A MemoryConn is one end of an in-memory connection. Written data is
+// buffered up to 64 KB until it is read by the other end. Writes block once
+// the buffer is full until the data is read or the connection is closed.
+type MemoryConn struct {
+	*BaseConn
+
+	carrier *memoryCarrier
+	local   net.Addr
+	remote  net.Addr
+}
+
+// NewMemoryConnPair returns two connected MemoryConn values with the specified
+// addresses.
+func NewMemoryConnPair(addr1, addr2 MemoryAddr) (*MemoryConn, *MemoryConn) {
+	// create pipes
+	pipe1 := newMemoryPipe()
+	pipe2 := newMemoryPipe()
+
+	// create carriers
+	carrier1 := &memoryCarrier{in: pipe1, out: pipe2, done: make(chan struct{})}
+	carrier2 := &memoryCarrier{in: pipe2, out: pipe1, done: make(chan struct{})}
+
+	// create connections
+	conn1 := &MemoryConn{
+		BaseConn: NewBaseConn(carrier1),
+		carrier:  carrier1,
+		local:    addr1,
+		remote:   addr2,
+	}
+	conn2 := &MemoryConn{
+		BaseConn: NewBaseConn(carrier2),
+		carrier:  carrier2,
+		local:    addr2,
+		remote:   addr1,
+	}
+
+	return conn1, conn2
+}
+
+// LocalAddr returns the local address.
+func (c *MemoryConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote address.
+func (c *MemoryConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// TLSState will always return false as memory connections are not secured
+// using TLS.
+func (c *MemoryConn) TLSState() (*tls.ConnectionState, bool) {
+	return nil, false
+}
@@ -0,0 +1,184 @@
+package transport
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryConnConnection(t *testing.T) {
+	abstractConnConnectTest(t, "mem")
+}
+
+func TestMemoryConnClose(t *testing.T) {
+	abstractConnCloseTest(t, "mem")
+}
+
+func TestMemoryConnEncodeError(t *testing.T) {
+	abstractConnEncodeErrorTest(t, "mem")
+}
+
+func TestMemoryConnDecodeError(t *testing.T) {
+	abstractConnDecodeErrorTest(t, "mem")
+}
+
+func TestMemoryConnSendAfterClose(t *testing.T) {
+	abstractConnSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnCloseWhileSend(t *testing.T) {
+	abstractConnCloseWhileSendTest(t, "mem")
+}
+
+func TestMemoryConnSendAndCloseTest(t *testing.T) {
+	abstractConnSendAndCloseTest(t, "mem")
+}
+
+func TestMemoryConnReadLimit(t *testing.T) {
+	abstractConnReadLimitTest(t, "mem")
+}
+
+func TestMemoryConnReadTimeout(t *testing.T) {
+	abstractConnReadTimeoutTest(t, "mem")
+}
+
+func TestMemoryConnCloseAfterClose(t *testing.T) {
+	abstractConnCloseAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnAddr(t *testing.T) {
+	abstractConnAddrTest(t, "mem")
+}
+
+func TestMemoryConnAsyncSend(t *testing.T) {
+	abstractConnAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnSendBatch(t *testing.T) {
+	abstractConnSendBatchTest(t, "mem")
+}
+
+func TestMemoryConnStreaming(t *testing.T) {
+	abstractConnStreamingTest(t, "mem")
+}
+
+func TestMemoryConnSendAfterAsyncSend(t *testing.T) {
+	abstractConnSendAfterAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnAsyncSendAfterClose(t *testing.T) {
+	abstractConnAsyncSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnCloseAfterAsyncSend(t *testing.T) {
+	abstractConnCloseAfterAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnBigAsyncSendAfterClose(t *testing.T) {
+	abstractConnBigAsyncSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnTLSState(t *testing.T) {
+	abstractConnTLSStateTest(t, "mem", false)
+}
+
+func TestMemoryConnPairAddr(t *testing.T) {
+	conn1, conn2 := NewMemoryConnPair("a", "b")
+
+	assert.Equal(t, MemoryAddr("a"), conn1.LocalAddr())
+	assert.Equal(t, MemoryAddr("b"), conn1.RemoteAddr())
+	assert.Equal(t, MemoryAddr("b"), conn2.LocalAddr())
+	assert.Equal(t, MemoryAddr("a"), conn2.RemoteAddr())
+	assert.Equal(t, "mem", conn1.LocalAddr().Network())
+
+	err := conn1.Close()
+	assert.NoError(t, err)
+
+	err = conn2.Close()
+	assert.NoError(t, err)
+}
+
+func TestMemoryConnWriteRate(t *testing.T) {
+	abstractConnWriteRateTest(t, "mem")
+}
+
+func TestMemoryConnReadRate(t *testing.T) {
+	abstractConnReadRateTest(t, "mem")
+}
+
+func TestMemoryConnPacketRate(t *testing.T) {
+	abstractConnPacketRateTest(t, "mem")
+}
+
+func TestMemoryCarrierBlockingWrite(t *testing.T) {
+	conn1, conn2 := NewMemoryConnPair("a", "b")
+
+	data := make([]byte, memoryPipeSize+100)
+
+	done := make(chan struct{})
+	go func() {
+		n, err := conn1.carrier.Write(data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write did not block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	buf := make([]byte, len(data))
+	_, err := io.ReadFull(conn2.carrier, buf)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
+func TestMemoryCarrierWriteDeadline(t *testing.T) {
+	conn1, _ := NewMemoryConnPair("a", "b")
+
+	err := conn1.carrier.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	assert.NoError(t, err)
+
+	n, err := conn1.carrier.Write(make([]byte, memoryPipeSize+100))
+	assert.Equal(t, memoryPipeSize, n)
+	assert.Equal(t, memoryTimeoutError{}, err)
+}
+
+func TestMemoryCarrierCloseWhileWrite(t *testing.T) {
+	conn1, conn2 := NewMemoryConnPair("a", "b")
+
+	done := make(chan struct{})
+	go func() {
+		_, err := conn1.carrier.Write(make([]byte, memoryPipeSize+100))
+		assert.Equal(t, io.ErrClosedPipe, err)
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	err := conn2.carrier.Close()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
+func TestMemoryConnNegativePacketRate(t *testing.T) {
+	conn1, conn2 := NewMemoryConnPair("a", "b")
+	conn1.SetPacketRate(-1)
+
+	for i := 0; i < 3; i++ {
+		err := conn2.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.NewPingreq(), pkt)
+	}
+}
@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMemoryServerNotFound is returned by DialMemory if no memory server is
+// listening on the specified name.
+var ErrMemoryServerNotFound = errors.New("memory server not found")
+
+// ErrMemoryServerExists is returned by CreateMemoryServer if a memory server is
+// already listening on the specified name.
+var ErrMemoryServerExists = errors.New("memory server already exists")
+
+// ErrMemoryServerClosed is returned by a closed MemoryServer.
+var ErrMemoryServerClosed = errors.New("memory server closed")
+
+// the number of connections that can be dialed without being accepted
+const memoryServerBacklog = 128
+
+var memoryServers = make(map[string]*MemoryServer)
+var memoryServersMutex sync.Mutex
+var memoryConnCounter uint64
+
+// A MemoryServer accepts in-memory connections that are dialed using its name
+// in the current process.
+type MemoryServer struct {
+	name     string
+	incoming chan *MemoryConn
+	done     chan struct{}
+	once     sync.Once
+	mutex    sync.Mutex
+}
+
+// CreateMemoryServer creates a new memory server that listens on the provided
+// name.
+func CreateMemoryServer(name string) (*MemoryServer, error) {
+	// acquire mutex
+	memoryServersMutex.Lock()
+	defer memoryServersMutex.Unlock()
+
+	// check name
+	if _, ok := memoryServers[name]; ok {
+		return nil, ErrMemoryServerExists
+	}
+
+	// create server
+	server := &MemoryServer{
+		name:     name,
+		incoming: make(chan *MemoryConn, memoryServerBacklog),
+		done:     make(chan struct{}),
+	}
+
+	// register server
+	memoryServers[name] = server
+
+	return server, nil
+}
+
+// DialMemory connects to the memory server with the provided name. It will
+// block if the backlog of the server is full.
+func DialMemory(name string) (*MemoryConn, error) {
+	// get server
+	memoryServersMutex.Lock()
+	server, ok := memoryServers[name]
+	memoryServersMutex.Unlock()
+	if !ok {
+		return nil, ErrMemoryServerNotFound
+	}
+
+	// create connections
+	id := atomic.AddUint64(&memoryConnCounter, 1)
+	clientConn, serverConn := NewMemoryConnPair(MemoryAddr(name+"#"+strconv.FormatUint(id, 10)), MemoryAddr(name))
+
+	// acquire mutex
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	// check if closed
+	select {
+	case <-server.done:
+		return nil, ErrMemoryServerNotFound
+	default:
+	}
+
+	// queue connection
+	select {
+	case server.incoming <- serverConn:
+		return clientConn, nil
+	case <-server.done:
+		return nil, ErrMemoryServerNotFound
+	}
+}
+
+// Accept will return the next available connection or block until a
+// connection becomes available, otherwise returns an error.
+func (s *MemoryServer) Accept() (Conn, error) {
+	// check if closed
+	select {
+	case <-s.done:
+		return nil, ErrMemoryServerClosed
+	default:
+	}
+
+	// await next connection
+	select {
+	case conn := <-s.incoming:
+		return conn, nil
+	case <-s.done:
+		return nil, ErrMemoryServerClosed
+	}
+}
+
+// Close will unregister the server and close all connections that have not
+// yet been accepted.
+func (s *MemoryServer) Close() error {
+	// signal close, this unblocks pending dials
+	closed := false
+	s.once.Do(func() {
+		close(s.done)
+		closed = true
+	})
+	if !closed {
+		return ErrMemoryServerClosed
+	}
+
+	// unregister server
+	memoryServersMutex.Lock()
+	if memoryServers[s.name] == s {
+		delete(memoryServers, s.name)
+	}
+	memoryServersMutex.Unlock()
+
+	// acquire mutex to wait for pending dials
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// close queued connections
+	for {
+		select {
+		case conn := <-s.incoming:
+			_ = conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Addr returns the server's name.
+func (s *MemoryServer) Addr() net.Addr {
+	return MemoryAddr(s.name)
+}
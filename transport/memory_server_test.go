@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryServer(t *testing.T) {
+	abstractServerTest(t, "mem")
+}
+
+func TestMemoryServerAcceptAfterClose(t *testing.T) {
+	abstractServerAcceptAfterCloseTest(t, "mem")
+}
+
+func TestMemoryServerCloseAfterClose(t *testing.T) {
+	abstractServerCloseAfterCloseTest(t, "mem")
+}
+
+func TestMemoryServerAddr(t *testing.T) {
+	server, err := testLauncher.Launch("mem://foo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "foo", server.Addr().String())
+	assert.Equal(t, "mem", server.Addr().Network())
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestMemoryServerLaunchError(t *testing.T) {
+	server1, err := testLauncher.Launch("mem://foo")
+	require.NoError(t, err)
+
+	server2, err := testLauncher.Launch("mem://foo")
+	assert.Nil(t, server2)
+	assert.Equal(t, ErrMemoryServerExists, err)
+
+	err = server1.Close()
+	assert.NoError(t, err)
+}
+
+func TestMemoryServerDialError(t *testing.T) {
+	conn, err := testDialer.Dial("mem://foo")
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrMemoryServerNotFound, err)
+}
+
+func TestMemoryServerCloseQueued(t *testing.T) {
+	server, err := testLauncher.Launch("mem://foo")
+	require.NoError(t, err)
+
+	conn, err := testDialer.Dial("mem://foo")
+	require.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+}
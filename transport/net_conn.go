@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"crypto/tls"
 	"net"
 )
 
@@ -29,6 +30,24 @@ func (c *NetConn) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// TLSState returns the state of the underlying TLS connection.
+func (c *NetConn) TLSState() (*tls.ConnectionState, bool) {
+	return tlsState(c.conn)
+}
+
+// PeerCredentials returns the credentials of the peer process if the
+// connection is a unix domain socket. It will return ErrNoPeerCredentials if
+// the connection or platform does not support peer credentials.
+func (c *NetConn) PeerCredentials() (*PeerCredentials, error) {
+	// check connection
+	unixConn, ok := c.conn.(*net.UnixConn)
+	if !ok {
+		return nil, ErrNoPeerCredentials
+	}
+
+	return peerCredentials(unixConn)
+}
+
 // UnderlyingConn returns the underlying net.Conn.
 func (c *NetConn) UnderlyingConn() net.Conn {
 	return c.conn
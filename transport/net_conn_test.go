@@ -57,6 +57,14 @@ func TestNetConnAsyncSend(t *testing.T) {
 	abstractConnAsyncSendTest(t, "tcp")
 }
 
+func TestNetConnSendBatch(t *testing.T) {
+	abstractConnSendBatchTest(t, "tcp")
+}
+
+func TestNetConnStreaming(t *testing.T) {
+	abstractConnStreamingTest(t, "tcp")
+}
+
 func TestNetConnSendAfterAsyncSend(t *testing.T) {
 	abstractConnSendAfterAsyncSendTest(t, "tcp")
 }
@@ -77,8 +85,8 @@ func TestNetConnCloseWhileReadError(t *testing.T) {
 	conn2, done := connectionPair("tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
-		buf := make([]byte, pkt.Len())
-		_, err := pkt.Encode(buf)
+		buf := make([]byte, pkt.Len(packet.Version311))
+		_, err := pkt.Encode(packet.Version311, buf)
 		assert.NoError(t, err)
 
 		netConn := conn1.(*NetConn)
@@ -100,8 +108,8 @@ func TestNetConnCloseWhileDetectError(t *testing.T) {
 	conn2, done := connectionPair("tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
-		buf := make([]byte, pkt.Len())
-		_, err := pkt.Encode(buf)
+		buf := make([]byte, pkt.Len(packet.Version311))
+		_, err := pkt.Encode(packet.Version311, buf)
 		assert.NoError(t, err)
 
 		netConn := conn1.(*NetConn)
@@ -123,8 +131,8 @@ func TestNetConnReadTimeoutAfterDetect(t *testing.T) {
 	conn2, done := connectionPair("tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
-		buf := make([]byte, pkt.Len())
-		_, err := pkt.Encode(buf)
+		buf := make([]byte, pkt.Len(packet.Version311))
+		_, err := pkt.Encode(packet.Version311, buf)
 		assert.NoError(t, err)
 
 		netConn := conn1.(*NetConn)
@@ -161,7 +169,7 @@ func BenchmarkNetConn(b *testing.B) {
 		}
 	}
 
-	b.SetBytes(int64(pkt.Len() * 2))
+	b.SetBytes(int64(pkt.Len(packet.Version311) * 2))
 
 	safeReceive(done)
 }
@@ -186,7 +194,24 @@ func BenchmarkNetConnAsync(b *testing.B) {
 		}
 	}
 
-	b.SetBytes(int64(pkt.Len() * 2))
+	b.SetBytes(int64(pkt.Len(packet.Version311) * 2))
 
 	safeReceive(done)
 }
+
+func TestNetConnTLSState(t *testing.T) {
+	abstractConnTLSStateTest(t, "tcp", false)
+	abstractConnTLSStateTest(t, "tls", true)
+}
+
+func TestNetConnWriteRate(t *testing.T) {
+	abstractConnWriteRateTest(t, "tcp")
+}
+
+func TestNetConnReadRate(t *testing.T) {
+	abstractConnReadRateTest(t, "tcp")
+}
+
+func TestNetConnPacketRate(t *testing.T) {
+	abstractConnPacketRateTest(t, "tcp")
+}
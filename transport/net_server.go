@@ -39,6 +39,18 @@ func CreateSecureNetServer(address string, config *tls.Config) (*NetServer, erro
 	return NewNetServer(listener), nil
 }
 
+// CreateUnixServer creates a new unix domain socket server that listens on the
+// provided path. Paths that start with "@" denote an abstract socket on Linux.
+func CreateUnixServer(path string) (*NetServer, error) {
+	// create listener
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNetServer(listener), nil
+}
+
 // Accept will return the next available connection or block until a
 // connection becomes available, otherwise returns an error.
 func (s *NetServer) Accept() (Conn, error) {
@@ -1,7 +1,17 @@
 package transport
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTCPServer(t *testing.T) {
@@ -31,3 +41,91 @@ func TestNetServerCloseAfterClose(t *testing.T) {
 func TestNetServerAddr(t *testing.T) {
 	abstractServerAddrTest(t, "tcp")
 }
+
+func TestUnixServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomqtt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	abstractUnixServerTest(t, "unix://"+filepath.Join(dir, "broker.sock"), func(conn Conn) {
+		if runtime.GOOS != "linux" {
+			return
+		}
+
+		creds, err := conn.(*NetConn).PeerCredentials()
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(os.Getuid()), creds.UID)
+		assert.Equal(t, uint32(os.Getgid()), creds.GID)
+		assert.Equal(t, int32(os.Getpid()), creds.PID)
+	})
+}
+
+func TestUnixAbstractServer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are only supported on linux")
+	}
+
+	abstractUnixServerTest(t, "unix-abstract://gomqtt-test", nil)
+}
+
+func TestNetConnPeerCredentialsUnsupported(t *testing.T) {
+	conn, done := connectionPair("tcp", func(conn Conn) {
+		creds, err := conn.(*NetConn).PeerCredentials()
+		assert.Nil(t, creds)
+		assert.Equal(t, ErrNoPeerCredentials, err)
+
+		_ = conn.Close()
+	})
+
+	_, err := conn.Receive()
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func abstractUnixServerTest(t *testing.T, url string, check func(Conn)) {
+	server, err := testLauncher.Launch(url)
+	require.NoError(t, err)
+
+	wait := make(chan struct{})
+
+	go func() {
+		conn1, err := server.Accept()
+		require.NoError(t, err)
+
+		if check != nil {
+			check(conn1)
+		}
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, pkt.Type(), packet.CONNECT)
+
+		err = conn1.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		pkt, err = conn1.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, io.EOF, err)
+
+		close(wait)
+	}()
+
+	conn2, err := testDialer.Dial(url)
+	require.NoError(t, err)
+
+	err = conn2.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, pkt.Type(), packet.CONNACK)
+
+	err = conn2.Close()
+	assert.NoError(t, err)
+
+	safeReceive(wait)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
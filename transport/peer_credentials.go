@@ -0,0 +1,9 @@
+package transport
+
+// PeerCredentials are the credentials of the process on the other side of a
+// unix domain socket.
+type PeerCredentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"net"
+	"syscall"
+)
+
+func peerCredentials(conn *net.UnixConn) (*PeerCredentials, error) {
+	// get raw connection
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	// get credentials
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	} else if credErr != nil {
+		return nil, credErr
+	}
+
+	return &PeerCredentials{
+		PID: cred.Pid,
+		UID: cred.Uid,
+		GID: cred.Gid,
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package transport
+
+import "net"
+
+func peerCredentials(*net.UnixConn) (*PeerCredentials, error) {
+	return nil, ErrNoPeerCredentials
+}
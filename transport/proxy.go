@@ -0,0 +1,477 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyHeader is returned if a connection from a trusted proxy does
+// not start with a valid PROXY protocol header.
+var ErrInvalidProxyHeader = errors.New("invalid proxy header")
+
+// the signature of PROXY protocol v2 headers
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// the maximum length of a PROXY protocol v1 header
+const maxProxyV1HeaderLen = 107
+
+// ProxyConfig configures the handling of PROXY protocol headers.
+type ProxyConfig struct {
+	// The networks of proxies that are trusted to send PROXY protocol headers.
+	// Connections from other sources are used as is.
+	TrustedSources []*net.IPNet
+
+	// The maximum time to wait for the PROXY protocol header.
+	//
+	// Will default to 5 seconds.
+	HeaderTimeout time.Duration
+}
+
+// A ProxyTLV is a type-length-value field of a PROXY protocol v2 header.
+type ProxyTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// A ProxyAddr is an address that has been reported by a proxy using a PROXY
+// protocol or X-Forwarded-For header.
+type ProxyAddr struct {
+	// The reported address.
+	net.Addr
+
+	// The address of the proxy.
+	Proxy net.Addr
+
+	// The TLVs of a PROXY protocol v2 header.
+	TLVs []ProxyTLV
+}
+
+// A ProxyListener wraps a listener and returns connections that read the PROXY
+// protocol v1 or v2 header sent by trusted proxies.
+type ProxyListener struct {
+	net.Listener
+
+	config ProxyConfig
+}
+
+// NewProxyListener wraps the provided listener.
+func NewProxyListener(listener net.Listener, config ProxyConfig) *ProxyListener {
+	// set default timeout
+	if config.HeaderTimeout <= 0 {
+		config.HeaderTimeout = 5 * time.Second
+	}
+
+	return &ProxyListener{
+		Listener: listener,
+		config:   config,
+	}
+}
+
+// Accept will return the next connection. Connections from trusted proxies are
+// wrapped by a ProxyConn.
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	// accept next connection
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// use connection as is if the source is not trusted
+	if !trustedAddr(conn.RemoteAddr(), l.config.TrustedSources) {
+		return conn, nil
+	}
+
+	return &ProxyConn{
+		Conn:    conn,
+		reader:  bufio.NewReaderSize(conn, 256),
+		timeout: l.config.HeaderTimeout,
+	}, nil
+}
+
+// A ProxyConn is a connection from a trusted proxy. The PROXY protocol header
+// is read when the connection is first read from or its addresses are
+// requested. The reported addresses are then returned by LocalAddr and
+// RemoteAddr as ProxyAddr values.
+type ProxyConn struct {
+	net.Conn
+
+	reader   *bufio.Reader
+	timeout  time.Duration
+	deadline time.Time
+	local    net.Addr
+	remote   net.Addr
+	err      error
+	once     sync.Once
+	mutex    sync.Mutex
+}
+
+// Read will read the header if necessary and then read from the connection.
+func (c *ProxyConn) Read(buf []byte) (int, error) {
+	// read header
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(buf)
+}
+
+// LocalAddr returns the destination address reported by the proxy or the
+// local address if none has been reported.
+func (c *ProxyConn) LocalAddr() net.Addr {
+	// read header
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+// RemoteAddr returns the source address reported by the proxy or the remote
+// address if none has been reported.
+func (c *ProxyConn) RemoteAddr() net.Addr {
+	// read header
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *ProxyConn) SetDeadline(t time.Time) error {
+	// save deadline
+	c.mutex.Lock()
+	c.deadline = t
+	c.mutex.Unlock()
+
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection.
+func (c *ProxyConn) SetReadDeadline(t time.Time) error {
+	// save deadline
+	c.mutex.Lock()
+	c.deadline = t
+	c.mutex.Unlock()
+
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *ProxyConn) readHeader() {
+	// set header deadline
+	c.err = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	if c.err != nil {
+		return
+	}
+
+	// read header
+	c.err = c.parseHeader()
+
+	// restore deadline
+	c.mutex.Lock()
+	err := c.Conn.SetReadDeadline(c.deadline)
+	c.mutex.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+
+	// close connection on error
+	if c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+func (c *ProxyConn) parseHeader() error {
+	// peek first byte
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	// check version
+	switch first[0] {
+	case 'P':
+		return c.parseV1()
+	case '\r':
+		return c.parseV2()
+	default:
+		return ErrInvalidProxyHeader
+	}
+}
+
+func (c *ProxyConn) parseV1() error {
+	// read line
+	var line []byte
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		// check length
+		line = append(line, b)
+		if len(line) > maxProxyV1HeaderLen {
+			return ErrInvalidProxyHeader
+		} else if b == '\n' {
+			break
+		}
+	}
+
+	// check line ending
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrInvalidProxyHeader
+	}
+
+	// split fields
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return ErrInvalidProxyHeader
+	}
+
+	// keep addresses of unknown connections
+	if fields[1] == "UNKNOWN" {
+		return nil
+	}
+
+	// check fields
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return ErrInvalidProxyHeader
+	}
+
+	// parse addresses
+	src := net.ParseIP(fields[2])
+	dst := net.ParseIP(fields[3])
+	if src == nil || dst == nil {
+		return ErrInvalidProxyHeader
+	}
+
+	// parse ports
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if err1 != nil || err2 != nil {
+		return ErrInvalidProxyHeader
+	}
+
+	// set addresses
+	c.setAddrs(&net.TCPAddr{IP: src, Port: int(srcPort)}, &net.TCPAddr{IP: dst, Port: int(dstPort)}, nil)
+
+	return nil
+}
+
+func (c *ProxyConn) parseV2() error {
+	// read fixed header
+	header := make([]byte, 16)
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	// check signature and version
+	if !bytes.Equal(header[:12], proxyV2Signature) || header[12]>>4 != 2 {
+		return ErrInvalidProxyHeader
+	}
+
+	// read address block
+	block := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	_, err = io.ReadFull(c.reader, block)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	// check command
+	switch header[12] & 0xF {
+	case 0x0:
+		// keep addresses of local connections
+		return nil
+	case 0x1:
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	// parse addresses
+	var src, dst net.Addr
+	var n int
+	switch header[13] >> 4 {
+	case 0x0:
+		// unspecified
+	case 0x1:
+		n = 12
+		if len(block) < n {
+			return ErrInvalidProxyHeader
+		}
+		src = &net.TCPAddr{IP: net.IP(block[0:4]), Port: int(binary.BigEndian.Uint16(block[8:]))}
+		dst = &net.TCPAddr{IP: net.IP(block[4:8]), Port: int(binary.BigEndian.Uint16(block[10:]))}
+	case 0x2:
+		n = 36
+		if len(block) < n {
+			return ErrInvalidProxyHeader
+		}
+		src = &net.TCPAddr{IP: net.IP(block[0:16]), Port: int(binary.BigEndian.Uint16(block[32:]))}
+		dst = &net.TCPAddr{IP: net.IP(block[16:32]), Port: int(binary.BigEndian.Uint16(block[34:]))}
+	case 0x3:
+		n = 216
+		if len(block) < n {
+			return ErrInvalidProxyHeader
+		}
+		src = &net.UnixAddr{Name: string(bytes.TrimRight(block[0:108], "\x00")), Net: "unix"}
+		dst = &net.UnixAddr{Name: string(bytes.TrimRight(block[108:216], "\x00")), Net: "unix"}
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	// parse TLVs
+	var tlvs []ProxyTLV
+	for data := block[n:]; len(data) > 0; {
+		// check length
+		if len(data) < 3 || len(data) < 3+int(binary.BigEndian.Uint16(data[1:])) {
+			return ErrInvalidProxyHeader
+		}
+
+		// add TLV
+		l := 3 + int(binary.BigEndian.Uint16(data[1:]))
+		tlvs = append(tlvs, ProxyTLV{
+			Type:  data[0],
+			Value: data[3:l],
+		})
+
+		data = data[l:]
+	}
+
+	// keep addresses if unspecified
+	if src == nil {
+		return nil
+	}
+
+	// set addresses
+	c.setAddrs(src, dst, tlvs)
+
+	return nil
+}
+
+func (c *ProxyConn) setAddrs(src, dst net.Addr, tlvs []ProxyTLV) {
+	c.remote = &ProxyAddr{
+		Addr:  src,
+		Proxy: c.Conn.RemoteAddr(),
+		TLVs:  tlvs,
+	}
+	c.local = &ProxyAddr{
+		Addr:  dst,
+		Proxy: c.Conn.LocalAddr(),
+		TLVs:  tlvs,
+	}
+}
+
+// returns the address reported by the X-Forwarded-For header if the request
+// has been sent by a trusted proxy
+func forwardedAddr(r *http.Request, peer net.Addr, trusted []*net.IPNet) net.Addr {
+	// check peer
+	if !trustedAddr(peer, trusted) {
+		return nil
+	}
+
+	// collect addresses
+	var list []string
+	for _, value := range r.Header["X-Forwarded-For"] {
+		list = append(list, strings.Split(value, ",")...)
+	}
+
+	// find the first address that is not a trusted proxy from the right
+	var ip net.IP
+	for i := len(list) - 1; i >= 0; i-- {
+		// parse address
+		addr := net.ParseIP(strings.TrimSpace(list[i]))
+		if addr == nil {
+			break
+		}
+
+		// set address
+		ip = addr
+		if !trustedIP(ip, trusted) {
+			break
+		}
+	}
+
+	// check address
+	if ip == nil {
+		return nil
+	}
+
+	return &ProxyAddr{
+		Addr:  &net.TCPAddr{IP: ip},
+		Proxy: peer,
+	}
+}
+
+func trustedAddr(addr net.Addr, trusted []*net.IPNet) bool {
+	// get reported address
+	if proxyAddr, ok := addr.(*ProxyAddr); ok {
+		addr = proxyAddr.Addr
+	}
+
+	// check address
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	return trustedIP(tcpAddr.IP, trusted)
+}
+
+func trustedIP(ip net.IP, trusted []*net.IPNet) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parses a list of networks in CIDR notation or single addresses
+func parseNetworks(list []string) ([]*net.IPNet, error) {
+	// parse networks
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		// parse single address
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		// parse network
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
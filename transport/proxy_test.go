@@ -0,0 +1,279 @@
+package transport
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyLauncher(trusted ...string) *Launcher {
+	return NewLauncher(LaunchConfig{
+		TLSConfig:          testTLSConfig,
+		ProxyProtocol:      true,
+		TrustedProxies:     trusted,
+		ProxyHeaderTimeout: time.Second,
+	})
+}
+
+func encodeConnect() []byte {
+	connect := packet.NewConnect()
+	buf := make([]byte, connect.Len(packet.Version311))
+	_, err := connect.Encode(packet.Version311, buf)
+	if err != nil {
+		panic(err)
+	}
+
+	return buf
+}
+
+func proxyV2Header(cmd byte, fam byte, block []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|cmd, fam, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(block)))
+	return append(header, block...)
+}
+
+func TestProxyProtocolV1(t *testing.T) {
+	server, err := proxyLauncher("127.0.0.1").Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	_, err = raw.Write([]byte("PROXY TCP4 1.2.3.4 5.6.7.8 1111 2222\r\n"))
+	assert.NoError(t, err)
+
+	_, err = raw.Write(encodeConnect())
+	assert.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.2.3.4:1111", conn.RemoteAddr().String())
+	assert.Equal(t, "5.6.7.8:2222", conn.LocalAddr().String())
+	assert.Equal(t, raw.LocalAddr().String(), conn.RemoteAddr().(*ProxyAddr).Proxy.String())
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	server, err := proxyLauncher("127.0.0.0/8").Launch("tls://localhost:0")
+	require.NoError(t, err)
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	block := []byte{1, 2, 3, 4, 5, 6, 7, 8, 0x04, 0x57, 0x08, 0xAE}
+	block = append(block, 0x05, 0x00, 0x02, 'i', 'd')
+	_, err = raw.Write(proxyV2Header(0x1, 0x11, block))
+	assert.NoError(t, err)
+
+	client := tls.Client(raw, &tls.Config{InsecureSkipVerify: true})
+
+	go func() {
+		_, err := client.Write(encodeConnect())
+		assert.NoError(t, err)
+	}()
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+
+	assert.Equal(t, "1.2.3.4:1111", conn.RemoteAddr().String())
+	assert.Equal(t, "5.6.7.8:2222", conn.LocalAddr().String())
+	assert.Equal(t, []ProxyTLV{{Type: 0x05, Value: []byte("id")}}, conn.RemoteAddr().(*ProxyAddr).TLVs)
+
+	err = client.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyProtocolV2Local(t *testing.T) {
+	server, err := proxyLauncher("127.0.0.1").Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	_, err = raw.Write(append(proxyV2Header(0x0, 0x00, nil), encodeConnect()...))
+	assert.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+
+	assert.Equal(t, raw.LocalAddr().String(), conn.RemoteAddr().String())
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyProtocolUntrustedSource(t *testing.T) {
+	server, err := proxyLauncher("10.0.0.0/8").Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		_, ok := conn.RemoteAddr().(*net.TCPAddr)
+		assert.True(t, ok)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		close(done)
+	}()
+
+	conn, err := testDialer.Dial(getURL(server, "tcp"))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyProtocolInvalidHeader(t *testing.T) {
+	server, err := proxyLauncher("127.0.0.1").Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	_, err = raw.Write(append([]byte("PROXY TCP4 foo\r\n"), encodeConnect()...))
+	assert.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyProtocolHeaderTimeout(t *testing.T) {
+	launcher := proxyLauncher("127.0.0.1")
+	launcher.config.ProxyHeaderTimeout = 10 * time.Millisecond
+
+	server, err := launcher.Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	raw, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyInvalidTrustedProxies(t *testing.T) {
+	server, err := proxyLauncher("foo").Launch("tcp://localhost:0")
+	assert.Error(t, err)
+	assert.Nil(t, server)
+}
+
+func TestWebSocketForwardedFor(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"},
+	})
+
+	server, err := launcher.Launch("ws://localhost:0")
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("X-Forwarded-For", "9.9.9.9, 10.0.0.1")
+
+	raw, _, err := websocket.DefaultDialer.Dial("ws://"+server.Addr().String(), header)
+	require.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	assert.Equal(t, "9.9.9.9:0", conn.RemoteAddr().String())
+	assert.Equal(t, raw.LocalAddr().String(), conn.RemoteAddr().(*ProxyAddr).Proxy.String())
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestWebSocketForwardedForUntrusted(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+
+	server, err := launcher.Launch("ws://localhost:0")
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("X-Forwarded-For", "9.9.9.9")
+
+	raw, _, err := websocket.DefaultDialer.Dial("ws://"+server.Addr().String(), header)
+	require.NoError(t, err)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	assert.Equal(t, raw.LocalAddr().String(), conn.RemoteAddr().String())
+
+	err = raw.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
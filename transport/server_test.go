@@ -12,7 +12,7 @@ import (
 )
 
 func abstractServerTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(protocol))
 	require.NoError(t, err)
 
 	wait := make(chan struct{})
@@ -61,7 +61,7 @@ func abstractServerLaunchErrorTest(t *testing.T, protocol string) {
 }
 
 func abstractServerAcceptAfterCloseTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(protocol))
 	require.NoError(t, err)
 
 	err = server.Close()
@@ -73,7 +73,7 @@ func abstractServerAcceptAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractServerCloseAfterCloseTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(protocol))
 	require.NoError(t, err)
 
 	err = server.Close()
@@ -84,7 +84,7 @@ func abstractServerCloseAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractServerAddrTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(protocol))
 	require.NoError(t, err)
 
 	assert.Equal(t, fmt.Sprintf("127.0.0.1:%s", getPort(server)), server.Addr().String())
@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// a tokenBucket limits the rate at which tokens are consumed. Consumers may
+// take more tokens than available and subsequent waits will block until the
+// debt has been paid off. The bucket holds at most one second worth of tokens.
+type tokenBucket struct {
+	rate    float64
+	tokens  float64
+	last    time.Time
+	changed chan struct{}
+	mutex   sync.Mutex
+}
+
+func newTokenBucket() *tokenBucket {
+	return &tokenBucket{
+		changed: make(chan struct{}),
+	}
+}
+
+// setRate sets the rate in tokens per second, a rate of zero or less disables
+// the bucket
+func (b *tokenBucket) setRate(rate int64) {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// disable bucket for negative rates
+	if rate < 0 {
+		rate = 0
+	}
+
+	// refill tokens using the old rate
+	now := time.Now()
+	b.refill(now)
+
+	// set rate and start with a full bucket
+	if b.rate == 0 {
+		b.tokens = float64(rate)
+	}
+	b.rate = float64(rate)
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	// wake up waiters
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// size returns the maximum number of tokens the bucket can hold or zero if the
+// bucket is disabled
+func (b *tokenBucket) size() int {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// check rate
+	if b.rate == 0 {
+		return 0
+	} else if b.rate < 1 {
+		return 1
+	}
+
+	return int(b.rate)
+}
+
+// take consumes the specified amount of tokens
+func (b *tokenBucket) take(n int) {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// check rate
+	if b.rate == 0 {
+		return
+	}
+
+	// refill and consume tokens
+	b.refill(time.Now())
+	b.tokens -= float64(n)
+}
+
+// wait blocks until the bucket is not in debt or the cancel channel is closed.
+// It returns whether it has been blocked.
+func (b *tokenBucket) wait(cancel <-chan struct{}) bool {
+	waited := false
+
+	for {
+		// acquire mutex
+		b.mutex.Lock()
+
+		// check rate
+		if b.rate == 0 {
+			b.mutex.Unlock()
+			return waited
+		}
+
+		// refill tokens
+		b.refill(time.Now())
+
+		// check debt
+		if b.tokens >= 0 {
+			b.mutex.Unlock()
+			return waited
+		}
+
+		// get delay and change signal
+		delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+		changed := b.changed
+
+		// release mutex
+		b.mutex.Unlock()
+
+		// wait for delay, change or cancel
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-changed:
+		case <-cancel:
+			timer.Stop()
+			return true
+		}
+		timer.Stop()
+
+		waited = true
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	// add tokens
+	if b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+
+	// set time
+	b.last = now
+}
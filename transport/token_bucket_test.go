@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketDisabled(t *testing.T) {
+	bucket := newTokenBucket()
+	assert.Equal(t, 0, bucket.size())
+
+	bucket.take(1000)
+	assert.False(t, bucket.wait(nil))
+}
+
+func TestTokenBucketNegativeRate(t *testing.T) {
+	bucket := newTokenBucket()
+	bucket.setRate(-1)
+	assert.Equal(t, 0, bucket.size())
+
+	bucket.take(1000)
+	assert.False(t, bucket.wait(nil))
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	bucket := newTokenBucket()
+	bucket.setRate(100)
+	assert.Equal(t, 100, bucket.size())
+
+	bucket.take(100)
+	assert.False(t, bucket.wait(nil))
+
+	bucket.take(10)
+
+	start := time.Now()
+	assert.True(t, bucket.wait(nil))
+	assert.True(t, time.Since(start) > 80*time.Millisecond)
+}
+
+func TestTokenBucketSetRate(t *testing.T) {
+	bucket := newTokenBucket()
+	bucket.setRate(1)
+	bucket.take(100)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		bucket.setRate(0)
+	}()
+
+	start := time.Now()
+	assert.True(t, bucket.wait(nil))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	bucket := newTokenBucket()
+	bucket.setRate(1)
+	bucket.take(100)
+
+	cancel := make(chan struct{})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(cancel)
+	}()
+
+	start := time.Now()
+	assert.True(t, bucket.wait(cancel))
+	assert.True(t, time.Since(start) < time.Second)
+}
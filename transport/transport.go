@@ -1,8 +1,49 @@
 // Package transport implements functionality for handling MQTT connections.
 package transport
 
-import "errors"
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+)
 
 // ErrUnsupportedProtocol is returned if either the launcher or dialer
 // couldn't infer the protocol from the URL.
 var ErrUnsupportedProtocol = errors.New("unsupported protocol")
+
+// ErrNoPeerCredentials is returned if the peer credentials of a connection are
+// not available.
+var ErrNoPeerCredentials = errors.New("no peer credentials")
+
+// returns the socket path of a "unix" or "unix-abstract" url, abstract sockets
+// are prefixed with an "@"
+func unixPath(addr *url.URL) string {
+	// get path
+	path := addr.Host + addr.Path
+
+	// prefix abstract sockets
+	if addr.Scheme == "unix-abstract" {
+		path = "@" + path
+	}
+
+	return path
+}
+
+// returns the state of the connection if it is a TLS connection that completed
+// the handshake
+func tlsState(conn net.Conn) (*tls.ConnectionState, bool) {
+	// check connection
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, false
+	}
+
+	// get state
+	state := tlsConn.ConnectionState()
+	if !state.HandshakeComplete {
+		return nil, false
+	}
+
+	return &state, true
+}
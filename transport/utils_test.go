@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,11 +37,23 @@ func init() {
 	})
 }
 
+var memoryServerCounter int32
+
+// returns an address to launch a server for the protocol
+func launchURL(protocol string) string {
+	// use unique name for memory servers
+	if protocol == "mem" {
+		return fmt.Sprintf("mem://test-%d", atomic.AddInt32(&memoryServerCounter, 1))
+	}
+
+	return protocol + "://localhost:0"
+}
+
 // returns a client-ish and server-ish pair of connections
 func connectionPair(protocol string, handler func(Conn)) (Conn, chan struct{}) {
 	done := make(chan struct{})
 
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(protocol))
 	if err != nil {
 		panic(err)
 	}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"crypto/tls"
 	"errors"
 	"io"
 	"net"
@@ -99,7 +100,8 @@ func (s *wsStream) SetReadDeadline(t time.Time) error {
 type WebSocketConn struct {
 	*BaseConn
 
-	conn *websocket.Conn
+	conn       *websocket.Conn
+	remoteAddr net.Addr
 }
 
 // NewWebSocketConn returns a new WebSocketConn.
@@ -115,11 +117,23 @@ func (c *WebSocketConn) LocalAddr() net.Addr {
 	return c.conn.LocalAddr()
 }
 
-// RemoteAddr returns the remote network address.
+// RemoteAddr returns the remote network address. If the connection has been
+// upgraded from a request of a trusted proxy, the address reported by the
+// proxy is returned.
 func (c *WebSocketConn) RemoteAddr() net.Addr {
+	// check reported address
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
 	return c.conn.RemoteAddr()
 }
 
+// TLSState returns the state of the underlying TLS connection.
+func (c *WebSocketConn) TLSState() (*tls.ConnectionState, bool) {
+	return tlsState(c.conn.UnderlyingConn())
+}
+
 // UnderlyingConn returns the underlying websocket.Conn.
 func (c *WebSocketConn) UnderlyingConn() *websocket.Conn {
 	return c.conn
@@ -58,6 +58,14 @@ func TestWebSocketConnAsyncSend(t *testing.T) {
 	abstractConnAsyncSendTest(t, "ws")
 }
 
+func TestWebSocketConnSendBatch(t *testing.T) {
+	abstractConnSendBatchTest(t, "ws")
+}
+
+func TestWebSocketConnStreaming(t *testing.T) {
+	abstractConnStreamingTest(t, "ws")
+}
+
 func TestWebSocketConnSendAfterAsyncSend(t *testing.T) {
 	abstractConnSendAfterAsyncSendTest(t, "ws")
 }
@@ -99,8 +107,8 @@ func TestWebSocketChunkedMessage(t *testing.T) {
 	pkt.Message.Payload = []byte("world")
 
 	conn2, done := connectionPair("ws", func(conn1 Conn) {
-		buf := make([]byte, pkt.Len())
-		_, err := pkt.Encode(buf)
+		buf := make([]byte, pkt.Len(packet.Version311))
+		_, err := pkt.Encode(packet.Version311, buf)
 		assert.NoError(t, err)
 
 		err = conn1.(*WebSocketConn).UnderlyingConn().WriteMessage(websocket.BinaryMessage, buf[:7])
@@ -130,12 +138,12 @@ func TestWebSocketCoalescedMessage(t *testing.T) {
 	pkt.Message.Payload = []byte("world")
 
 	conn2, done := connectionPair("ws", func(conn1 Conn) {
-		buf := make([]byte, pkt.Len()*2)
+		buf := make([]byte, pkt.Len(packet.Version311)*2)
 
-		_, err := pkt.Encode(buf)
+		_, err := pkt.Encode(packet.Version311, buf)
 		assert.NoError(t, err)
 
-		_, err = pkt.Encode(buf[pkt.Len():])
+		_, err = pkt.Encode(packet.Version311, buf[pkt.Len(packet.Version311):])
 		assert.NoError(t, err)
 
 		err = conn1.(*WebSocketConn).UnderlyingConn().WriteMessage(websocket.BinaryMessage, buf)
@@ -197,7 +205,7 @@ func BenchmarkWebSocketConn(b *testing.B) {
 		}
 	}
 
-	b.SetBytes(int64(pkt.Len() * 2))
+	b.SetBytes(int64(pkt.Len(packet.Version311) * 2))
 
 	safeReceive(done)
 }
@@ -222,7 +230,24 @@ func BenchmarkWebSocketConnAsync(b *testing.B) {
 		}
 	}
 
-	b.SetBytes(int64(pkt.Len() * 2))
+	b.SetBytes(int64(pkt.Len(packet.Version311) * 2))
 
 	safeReceive(done)
 }
+
+func TestWebSocketConnTLSState(t *testing.T) {
+	abstractConnTLSStateTest(t, "ws", false)
+	abstractConnTLSStateTest(t, "wss", true)
+}
+
+func TestWebSocketConnWriteRate(t *testing.T) {
+	abstractConnWriteRateTest(t, "ws")
+}
+
+func TestWebSocketConnReadRate(t *testing.T) {
+	abstractConnReadRateTest(t, "ws")
+}
+
+func TestWebSocketConnPacketRate(t *testing.T) {
+	abstractConnPacketRateTest(t, "ws")
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -12,6 +13,7 @@ import (
 type WebSocketUpgrader struct {
 	fallback http.Handler
 	upgrader *websocket.Upgrader
+	trusted  []*net.IPNet
 	mutex    sync.Mutex
 }
 
@@ -53,9 +55,20 @@ func (u *WebSocketUpgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*We
 	// create connection
 	webSocketConn := NewWebSocketConn(conn)
 
+	// use address reported by a trusted proxy
+	webSocketConn.remoteAddr = forwardedAddr(r, conn.RemoteAddr(), u.trusted)
+
 	return webSocketConn, nil
 }
 
+// SetTrustedProxies sets the networks of proxies that are trusted to report the
+// address of the client using the X-Forwarded-For header. The reported address
+// is returned by the connections RemoteAddr method. The method must be called
+// before the upgrader is used.
+func (u *WebSocketUpgrader) SetTrustedProxies(networks []*net.IPNet) {
+	u.trusted = networks
+}
+
 // UnderlyingUpgrader returns the underlying websocket.Upgrader.
 func (u *WebSocketUpgrader) UnderlyingUpgrader() *websocket.Upgrader {
 	return u.upgrader